@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"imagetool/internal/config"
+	"imagetool/internal/core"
+	"imagetool/internal/deps"
+	"imagetool/internal/logging"
+)
+
+// maxListedFailures limits the failures listed after a batch.
+const maxListedFailures = 5
+
+// batchFlags are the flags convert and compress share.
+type batchFlags struct {
+	name        *string
+	collision   *string
+	recursive   *bool
+	incremental *bool
+	cache       *string
+}
+
+// addBatchFlags defines the shared batch flags on fs.
+func addBatchFlags(fs *flag.FlagSet) batchFlags {
+	return batchFlags{
+		name:        fs.String("name", "", "output name template, e.g. {name}-web"),
+		collision:   fs.String("collision", "overwrite", "when an output exists: overwrite, skip, increment or fail"),
+		recursive:   fs.Bool("r", false, "include files in subfolders of folder arguments"),
+		incremental: fs.Bool("incremental", false, "skip inputs unchanged since an earlier run with the same options"),
+		cache:       fs.String("cache", "", "incremental cache file (default: "+core.CacheFileName+" in the config directory)"),
+	}
+}
+
+// collisionPolicy parses -collision.
+func (f batchFlags) collisionPolicy() (core.CollisionPolicy, error) {
+	for _, p := range core.CollisionPolicies {
+		if strings.EqualFold(*f.collision, p.String()) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown -collision %q", *f.collision)
+}
+
+// validateName checks -name, which may be left empty.
+func (f batchFlags) validateName() error {
+	if *f.name == "" {
+		return nil
+	}
+	if err := core.ValidateTemplate(*f.name); err != nil {
+		return fmt.Errorf("invalid -name: %w", err)
+	}
+	return nil
+}
+
+// inputs expands the paths on the command line: files are taken as they
+// are and folders are searched for images, and PDFs if pdfs is set.
+// Files in folders that the cache lists as outputs of an earlier run are
+// left out, so a run doesn't process its own outputs the next time. The
+// paths are made absolute so the cache matches them from any working
+// directory.
+func (f batchFlags) inputs(paths []string, pdfs bool, cache *core.Cache) ([]string, error) {
+	depth := 0
+	if *f.recursive {
+		depth = core.UnlimitedDepth
+	}
+
+	var inputs []string
+	for _, path := range paths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			inputs = append(inputs, path)
+			continue
+		}
+		entries, err := core.Walk(path, core.WalkOptions{
+			MaxDepth:      depth,
+			IncludeImages: true,
+			IncludePDFs:   pdfs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		outputs := cache.Outputs()
+		for _, e := range entries {
+			if !outputs[e.Path] {
+				inputs = append(inputs, e.Path)
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// openCache opens the incremental cache, or returns nil without
+// -incremental.
+func (f batchFlags) openCache() (*core.Cache, error) {
+	if !*f.incremental {
+		return nil, nil
+	}
+	path := *f.cache
+	if path == "" {
+		path = filepath.Join(config.GetConfigDir(), core.CacheFileName)
+	}
+	return core.OpenCache(path)
+}
+
+// runConvert converts images to another format.
+func runConvert(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	to := fs.String("to", "", "output format: "+formatList(core.SupportedImageFormats))
+	outDir := fs.String("o", "", "output folder mirroring a single input folder (default: next to each input)")
+	flags := addBatchFlags(fs)
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(paths) == 0 || *to == "" {
+		fmt.Fprintln(os.Stderr, "Usage: imagetool convert -to FORMAT [flags] file-or-folder...")
+		fs.PrintDefaults()
+		return 2
+	}
+
+	opts, err := convertOptions(cfg, flags, *to, *outDir, paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	cache, err := flags.openCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	inputs, err := flags.inputs(paths, false, cache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return runBatch(cfg, cache, core.ManifestHeader{
+		Operation: core.BatchOpConvert,
+		Title:     fmt.Sprintf("Convert %d files to %s", len(inputs), strings.ToUpper(string(opts.OutputFormat))),
+		InputDir:  opts.InputRoot,
+		Inputs:    inputs,
+		Convert:   &opts,
+	})
+}
+
+// convertOptions checks the convert flags and builds the batch options.
+func convertOptions(cfg *config.Config, flags batchFlags, to, outDir string, paths []string) (core.BatchConvertOptions, error) {
+	format := core.ImageFormat(strings.ToLower(to))
+	if !hasFormat(core.SupportedImageFormats, format) {
+		return core.BatchConvertOptions{}, fmt.Errorf("unsupported format %q; use %s", to, formatList(core.SupportedImageFormats))
+	}
+	collision, err := flags.collisionPolicy()
+	if err != nil {
+		return core.BatchConvertOptions{}, err
+	}
+	if err := flags.validateName(); err != nil {
+		return core.BatchConvertOptions{}, err
+	}
+
+	opts := core.BatchConvertOptions{
+		OutputFormat: format,
+		NameTemplate: *flags.name,
+		Collision:    collision,
+		Limits:       config.CoreLimits(cfg),
+		Timeout:      config.CoreTimeout(cfg),
+	}
+	if outDir != "" {
+		if len(paths) != 1 {
+			return opts, fmt.Errorf("-o needs a single input folder")
+		}
+		if info, err := os.Stat(paths[0]); err != nil || !info.IsDir() {
+			return opts, fmt.Errorf("-o needs a single input folder")
+		}
+		if opts.InputRoot, err = filepath.Abs(paths[0]); err != nil {
+			return opts, err
+		}
+		if opts.OutputRoot, err = filepath.Abs(outDir); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// runCompress compresses images and PDFs next to their inputs.
+func runCompress(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("compress", flag.ContinueOnError)
+	percent := fs.Int("percent", config.DefaultCompressPercent, "target size as a percentage of the input")
+	size := fs.String("size", "", "target size, e.g. 200KB or 1.5MB")
+	minSSIM := fs.Float64("min-ssim", 0, "smallest output that keeps SSIM at or above this (0-1)")
+	webp := fs.Bool("webp", false, "let -min-ssim choose WebP when it is smaller")
+	flags := addBatchFlags(fs)
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: imagetool compress [flags] file-or-folder...")
+		fs.PrintDefaults()
+		return 2
+	}
+
+	opts := core.BatchCompressOptions{
+		Method:        core.CompressMethodPercent,
+		TargetPercent: *percent,
+		Limits:        config.CoreLimits(cfg),
+		Timeout:       config.CoreTimeout(cfg),
+	}
+	switch {
+	case *size != "" && *minSSIM != 0:
+		err = fmt.Errorf("use either -size or -min-ssim")
+	case *size != "":
+		opts.Method = core.CompressMethodFixedSize
+		opts.TargetBytes, err = parseByteSize(*size)
+	case *minSSIM != 0:
+		opts.Method, opts.MinSSIM = core.CompressMethodQuality, *minSSIM
+		if *minSSIM < 0 || *minSSIM > 1 {
+			err = fmt.Errorf("-min-ssim must be 0-1")
+		}
+		if *webp {
+			opts.Formats = []core.ImageFormat{core.FormatJPG, core.FormatWebP}
+		}
+	case *percent < 1 || *percent > 100:
+		err = fmt.Errorf("-percent must be 1-100")
+	}
+	if err == nil {
+		opts.Collision, err = flags.collisionPolicy()
+	}
+	if err == nil {
+		err = flags.validateName()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	opts.NameTemplate = *flags.name
+
+	cache, err := flags.openCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	inputs, err := flags.inputs(paths, true, cache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return runBatch(cfg, cache, core.ManifestHeader{
+		Operation: core.BatchOpCompress,
+		Title:     fmt.Sprintf("Compress %d files", len(inputs)),
+		Inputs:    inputs,
+		Compress:  &opts,
+	})
+}
+
+// runBatch runs the batch a header describes, recording it in a manifest
+// so an interrupted run can be resumed, and reports what it did. A nil
+// cache runs every input.
+func runBatch(cfg *config.Config, cache *core.Cache, h core.ManifestHeader) int {
+	if len(h.Inputs) == 0 {
+		fmt.Println("No files to process.")
+		return 0
+	}
+	if result := checkDeps(cfg); result.ImageMagick.Status == deps.StatusNotFound {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", result.ImageMagick.Error)
+		return 1
+	}
+	manifest, err := core.CreateManifest(h)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: the run can't be resumed if interrupted: %v\n", err)
+	}
+
+	// Ctrl+C aborts the files in progress, whose partial outputs are
+	// removed; the manifest keeps the finished ones for --resume
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	progress := func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\r  %d/%d files", done, total)
+	}
+	var batch core.BatchResult
+	switch {
+	case h.Convert != nil:
+		opts := *h.Convert
+		opts.Cache, opts.Manifest, opts.Progress = cache, manifest, progress
+		batch = core.BatchConvert(ctx, h.Inputs, opts)
+	case h.Compress != nil:
+		opts := *h.Compress
+		opts.Cache, opts.Manifest, opts.Progress = cache, manifest, progress
+		batch = core.BatchCompress(ctx, h.Inputs, opts)
+	}
+	fmt.Fprintln(os.Stderr)
+
+	if err := manifest.Finish(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update %s: %v\n", manifest.Path(), err)
+	}
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save %s: %v\n", cache.Path(), err)
+	}
+	printBatchResult(h.Inputs, batch)
+	logging.Info("Batch finished", map[string]interface{}{
+		"title":     h.Title,
+		"processed": batch.SuccessCount,
+		"upToDate":  batch.UpToDateCount,
+		"skipped":   batch.SkippedCount,
+		"failed":    batch.FailCount,
+	})
+
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted. Run imagetool --resume to continue.")
+		return 1
+	}
+	if batch.FailCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printBatchResult summarizes a batch and lists its first failures.
+func printBatchResult(inputs []string, batch core.BatchResult) {
+	fmt.Printf("%d up to date, %d processed", batch.UpToDateCount, batch.SuccessCount)
+	if batch.SkippedCount > 0 {
+		fmt.Printf(", %d skipped", batch.SkippedCount)
+	}
+	if batch.FailCount > 0 {
+		fmt.Printf(", %d failed", batch.FailCount)
+	}
+	if notStarted := batch.TotalFiles - len(batch.Results); notStarted > 0 {
+		fmt.Printf(", %d not started", notStarted)
+	}
+	fmt.Println()
+
+	listed := 0
+	for i, r := range batch.Results {
+		if r.Success || i >= len(inputs) {
+			continue
+		}
+		if listed == maxListedFailures {
+			fmt.Printf("  ... and %d more (see logs)\n", batch.FailCount-listed)
+			break
+		}
+		fmt.Printf("  FAILED %s: %s\n", inputs[i], r.Message)
+		listed++
+	}
+}
+
+// parseByteSize parses a size like 500000, 200KB or 1.5MB.
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	unit := 1.0
+	for _, u := range []struct {
+		suffix string
+		bytes  float64
+	}{{"MB", 1024 * 1024}, {"KB", 1024}, {"B", 1}} {
+		if strings.HasSuffix(value, u.suffix) {
+			value, unit = strings.TrimSpace(strings.TrimSuffix(value, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q; use e.g. 200KB or 1.5MB", s)
+	}
+	return int64(n * unit), nil
+}
+
+// hasFormat reports whether formats contains f.
+func hasFormat(formats []core.ImageFormat, f core.ImageFormat) bool {
+	for _, format := range formats {
+		if format == f {
+			return true
+		}
+	}
+	return false
+}
+
+// formatList joins formats for flag help and errors.
+func formatList(formats []core.ImageFormat) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"time"
+
+	"imagetool/internal/config"
+	"imagetool/internal/core"
+	"imagetool/internal/deps"
+	"imagetool/internal/diag"
+	"imagetool/internal/logging"
+)
+
+// runCommand runs a command-line subcommand. It reports false when args
+// don't name a subcommand so the TUI should start instead.
+func runCommand(cfg *config.Config, args []string) (handled bool, exitCode int) {
+	if len(args) == 0 {
+		return false, 0
+	}
+
+	switch args[0] {
+	case "diagnose":
+		return true, runDiagnose(cfg, args[1:])
+	case "compare":
+		return true, runCompare(cfg, args[1:])
+	case "convert":
+		return true, runConvert(cfg, args[1:])
+	case "compress":
+		return true, runCompress(cfg, args[1:])
+	case "resume", "--resume":
+		return true, runResume(cfg, args[1:])
+	case "help", "-h", "--help":
+		printUsage()
+		return true, 0
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	printUsage()
+	return true, 2
+}
+
+// printUsage lists the available subcommands.
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  imagetool                 Start the interactive interface")
+	fmt.Fprintln(os.Stderr, "  imagetool diagnose [-o]   Write a diagnostics zip for bug reports")
+	fmt.Fprintln(os.Stderr, "  imagetool compare a b     Measure how much b differs from a")
+	fmt.Fprintln(os.Stderr, "      [-min-ssim N] [-threshold N] [-diff out.png] [-json]")
+	fmt.Fprintln(os.Stderr, "  imagetool convert -to FORMAT path...")
+	fmt.Fprintln(os.Stderr, "                            Convert images; folders are searched for images")
+	fmt.Fprintln(os.Stderr, "  imagetool compress path...")
+	fmt.Fprintln(os.Stderr, "      [-percent N | -size 200KB | -min-ssim N] Compress images and PDFs")
+	fmt.Fprintln(os.Stderr, "      (both take -incremental to skip inputs unchanged since the last run)")
+	fmt.Fprintln(os.Stderr, "  imagetool --resume [manifest...]")
+	fmt.Fprintln(os.Stderr, "                            Finish interrupted or partly failed folder runs")
+}
+
+// checkDeps runs the dependency check with configured executable paths.
+func checkDeps(cfg *config.Config) deps.CheckResult {
+	return deps.CheckWith(deps.Options{
+		MagickPath:      cfg.EffectiveMagickPath(),
+		GhostscriptPath: cfg.EffectiveGhostscriptPath(),
+	})
+}
+
+// runDiagnose writes a diagnostics bundle.
+func runDiagnose(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	output := fs.String("o", diag.DefaultFileName(time.Now()), "output zip file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	bundle := diag.Collect(diag.Options{
+		Config: cfg,
+		Deps:   checkDeps(cfg),
+		LogDir: logging.Dir(),
+	})
+	if err := bundle.WriteFile(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	logging.Info("Diagnostics written", map[string]interface{}{
+		"path": *output,
+	})
+	fmt.Printf("Diagnostics written to %s\n", *output)
+	return 0
+}
+
+// exitBelowMinimum is the exit code of compare when -min-ssim is not met,
+// so scripts can tell a failed gate from an error.
+const exitBelowMinimum = 3
+
+// compareReport is the JSON output of compare.
+type compareReport struct {
+	Original    string   `json:"original"`
+	Processed   string   `json:"processed"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	PSNR        *float64 `json:"psnr"` // null when the images are identical
+	SSIM        float64  `json:"ssim"`
+	MSSSIM      float64  `json:"ms_ssim"`
+	MAE         float64  `json:"mae"`
+	MaxDiff     int      `json:"max_diff"`
+	DiffPixels  int      `json:"diff_pixels"`
+	DiffPercent float64  `json:"diff_percent"`
+	DiffImage   string   `json:"diff_image,omitempty"`
+	MinSSIM     float64  `json:"min_ssim,omitempty"`
+	Pass        bool     `json:"pass"`
+}
+
+// runCompare measures the difference between two images.
+func runCompare(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	minSSIM := fs.Float64("min-ssim", 0, "exit with 3 if SSIM is below this (0-1)")
+	threshold := fs.Int("threshold", 0, "channel difference (0-255) still counted as unchanged")
+	diffPath := fs.String("diff", "", "write an image highlighting changed pixels")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(paths) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: imagetool compare [flags] original processed")
+		fs.PrintDefaults()
+		return 2
+	}
+	if *minSSIM < 0 || *minSSIM > 1 || *threshold < 0 || *threshold > 255 {
+		fmt.Fprintln(os.Stderr, "Error: -min-ssim must be 0-1 and -threshold 0-255")
+		return 2
+	}
+
+	if result := checkDeps(cfg); result.ImageMagick.Status == deps.StatusNotFound {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", result.ImageMagick.Error)
+		return 1
+	}
+
+	m, err := core.Compare(context.Background(), core.CompareOptions{
+		Original:  paths[0],
+		Processed: paths[1],
+		Threshold: *threshold,
+		DiffPath:  *diffPath,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	report := compareReport{
+		Original:    paths[0],
+		Processed:   paths[1],
+		Width:       m.Width,
+		Height:      m.Height,
+		SSIM:        m.SSIM,
+		MSSSIM:      m.MSSSIM,
+		MAE:         m.MAE,
+		MaxDiff:     m.MaxDiff,
+		DiffPixels:  m.DiffPixels,
+		DiffPercent: m.DiffPercent(),
+		DiffImage:   *diffPath,
+		MinSSIM:     *minSSIM,
+		Pass:        m.SSIM >= *minSSIM,
+	}
+	if !math.IsInf(m.PSNR, 1) {
+		report.PSNR = &m.PSNR
+	}
+
+	logging.Info("Images compared", map[string]interface{}{
+		"original":  paths[0],
+		"processed": paths[1],
+		"ssim":      m.SSIM,
+		"pass":      report.Pass,
+	})
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	} else {
+		printCompareReport(report)
+	}
+
+	if !report.Pass {
+		return exitBelowMinimum
+	}
+	return 0
+}
+
+// printCompareReport writes the comparison for people to read.
+func printCompareReport(r compareReport) {
+	psnr := "inf (identical)"
+	if r.PSNR != nil {
+		psnr = fmt.Sprintf("%.2f dB", *r.PSNR)
+	}
+	fmt.Printf("Compared at %dx%d\n", r.Width, r.Height)
+	fmt.Printf("PSNR:      %s\n", psnr)
+	fmt.Printf("SSIM:      %.4f\n", r.SSIM)
+	fmt.Printf("MS-SSIM:   %.4f\n", r.MSSSIM)
+	fmt.Printf("MAE:       %.2f\n", r.MAE)
+	fmt.Printf("Max diff:  %d/255\n", r.MaxDiff)
+	fmt.Printf("Changed:   %d pixels (%.2f%%)\n", r.DiffPixels, r.DiffPercent)
+	if r.DiffImage != "" {
+		fmt.Printf("Diff:      %s\n", r.DiffImage)
+	}
+	if r.MinSSIM > 0 {
+		if r.Pass {
+			fmt.Printf("PASS: SSIM %.4f >= %g\n", r.SSIM, r.MinSSIM)
+		} else {
+			fmt.Printf("FAIL: SSIM %.4f < %g\n", r.SSIM, r.MinSSIM)
+		}
+	}
+}
+
+// runResume finishes folder runs that were interrupted or had failures:
+// the given manifests, or every pending one. Finished files are skipped
+// and failed ones retried.
+func runResume(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("resume", flag.ContinueOnError)
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	var manifests []*core.Manifest
+	if len(paths) == 0 {
+		if manifests, err = core.PendingManifests(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	for _, path := range paths {
+		m, err := core.OpenManifest(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		manifests = append(manifests, m)
+	}
+	if len(manifests) == 0 {
+		fmt.Println("No unfinished folder runs to resume.")
+		return 0
+	}
+
+	if result := checkDeps(cfg); result.ImageMagick.Status == deps.StatusNotFound {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", result.ImageMagick.Error)
+		return 1
+	}
+
+	// Ctrl+C aborts the files in progress, whose partial outputs are
+	// removed; the manifest keeps the finished ones for --resume
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	code := 0
+	for _, m := range manifests {
+		h := m.Header()
+		finished, failed, total := m.Progress()
+		fmt.Printf("Resuming %s: %d of %d finished, %d failed\n", h.Title, finished, total, failed)
+
+		batch, err := core.ResumeBatch(ctx, m, func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\r  %d/%d files", done, total)
+		})
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not update %s: %v\n", m.Path(), err)
+		}
+		printBatchResult(h.Inputs, batch)
+		logging.Info("Folder run resumed", map[string]interface{}{
+			"manifest":  m.Path(),
+			"processed": batch.SuccessCount,
+			"upToDate":  batch.UpToDateCount,
+			"failed":    batch.FailCount,
+		})
+
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted. Run imagetool --resume to continue.")
+			return 1
+		}
+		if batch.FailCount > 0 {
+			code = 1
+		}
+	}
+	return code
+}
+
+// parseInterspersed parses flags that may come before, between or after
+// the positional arguments, which it returns in order. Everything after
+// "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"imagetool/internal/config"
+	"imagetool/internal/core"
+	"imagetool/internal/history"
 	"imagetool/internal/logging"
 	"imagetool/internal/ui"
 
@@ -17,12 +20,12 @@ import (
 )
 
 func main() {
-	// Set terminal title
-	fmt.Print("\033]0;Image-Tool\007")
+	// Load configuration first so it can configure logging
+	cfg, cfgErr := config.Load()
 
 	// Initialize logging
 	logDir := filepath.Join(config.GetConfigDir(), "logs")
-	if err := logging.Init(logDir); err != nil {
+	if err := logging.InitWithOptions(logDir, logOptions(cfg)); err != nil {
 		// Continue without logging if initialization fails
 		fmt.Fprintf(os.Stderr, "Warning: Could not initialize logging: %v\n", err)
 	}
@@ -30,18 +33,61 @@ func main() {
 
 	logging.Info("Application starting", nil)
 
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
+	if cfgErr != nil {
 		logging.Warn("Could not load config, using defaults", map[string]interface{}{
+			"error": cfgErr.Error(),
+		})
+	}
+
+	// Journal partial outputs and remove those left by crashed runs
+	if err := core.SetTempJournal(filepath.Join(config.GetConfigDir(), "journal")); err != nil {
+		logging.Warn("Could not create temp journal", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if removed := core.CleanStaleTemps(); removed > 0 {
+		logging.Info("Removed partial outputs from an earlier run", map[string]interface{}{
+			"count": removed,
+		})
+	}
+
+	if err := core.SetThumbnailDir(filepath.Join(config.GetConfigDir(), "thumbnails")); err != nil {
+		logging.Warn("Could not create thumbnail cache", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if err := core.SetManifestDir(filepath.Join(config.GetConfigDir(), "manifests")); err != nil {
+		logging.Warn("Could not create batch manifest folder", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if err := history.Init(filepath.Join(config.GetConfigDir(), history.FileName)); err != nil {
+		logging.Warn("Could not load history", map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
-	_ = cfg // Config available for future use
+
+	// Command-line subcommands run without the TUI
+	if handled, code := runCommand(cfg, os.Args[1:]); handled {
+		core.CloseTempJournal()
+		logging.Close()
+		os.Exit(code)
+	}
+
+	// Set terminal title
+	fmt.Print("\033]0;Image-Tool\007")
 
 	// Run TUI
-	p := tea.NewProgram(ui.NewApp(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	app := ui.NewApp(cfg)
+	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithOutput(app.Output()))
+	stopWatching := app.Output().WatchSize(p)
+	_, err := p.Run()
+	stopWatching()
+	// Stop background jobs so their partial outputs are cleaned up
+	app.Close()
+	if err != nil {
 		logging.Error("Application error", map[string]interface{}{
 			"error": err.Error(),
 		})
@@ -49,5 +95,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	core.CloseTempJournal()
 	logging.Info("Application exiting normally", nil)
 }
+
+// logOptions builds logger options from the config. An invalid
+// IMAGETOOL_LOG_LEVEL falls back to the configured level.
+func logOptions(cfg *config.Config) logging.Options {
+	opts := logging.DefaultOptions()
+
+	level, err := logging.ParseLevel(cfg.EffectiveLogLevel())
+	if err != nil {
+		level, _ = logging.ParseLevel(cfg.Logging.Level)
+		fmt.Fprintf(os.Stderr, "Warning: %v, using %s\n", err, level)
+	}
+	opts.Level = level
+
+	if format, err := logging.ParseFormat(cfg.Logging.Format); err == nil {
+		opts.Format = format
+	}
+	opts.MaxSize = int64(cfg.Logging.MaxSizeMB) * 1024 * 1024
+	opts.MaxFiles = cfg.Logging.MaxFiles
+	opts.MaxAge = time.Duration(cfg.Logging.MaxAgeDays) * 24 * time.Hour
+
+	return opts
+}
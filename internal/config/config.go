@@ -6,6 +6,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"imagetool/internal/core"
 )
 
 // Default configuration values
@@ -15,21 +20,221 @@ const (
 	DefaultQuality         = 90
 	DefaultPrefix          = "Page-"
 	DefaultCompressPercent = 75
+	DefaultMinSSIM         = 0.95 // Quality floor for compression
+
+	// Output name templates, see core.TemplateData for the tokens
+	DefaultConvertTemplate  = "{name}_conv"
+	DefaultCompressTemplate = "{name}_comp"
+
+	// What to do when an output file already exists
+	DefaultCollision = "overwrite"
+
+	// Folders remembered by the file picker
+	MaxRecentDirs = 10
+
+	// Image previews: auto, kitty, sixel, blocks or off
+	DefaultPreview = "auto"
 
 	// Density limits for PDF conversion
 	MinDensity = 72
 	MaxDensity = 600
 
+	// Logging defaults
+	DefaultLogLevel      = "info"
+	DefaultLogFormat     = "text"
+	DefaultLogMaxSizeMB  = 10
+	DefaultLogMaxFiles   = 30
+	DefaultLogMaxAgeDays = 30
+
+	// Timeout defaults
+	DefaultTimeoutBaseSeconds    = 120
+	DefaultTimeoutPerMBSeconds   = 2
+	DefaultTimeoutPerPageSeconds = 5
+	DefaultTimeoutMaxSeconds     = 1800
+
 	// Config file name
 	configFileName = "imagetool_config.json"
 )
 
+// Environment variables that override values from the config file
+const (
+	EnvMagickPath      = "IMAGETOOL_MAGICK"
+	EnvGhostscriptPath = "IMAGETOOL_GS"
+	EnvLogLevel        = "IMAGETOOL_LOG_LEVEL"
+)
+
 // SupportedImageFormats lists all formats for image conversion
 var SupportedImageFormats = []string{"png", "jpg", "jpeg", "webp", "avif", "bmp", "tiff", "gif"}
 
 // SupportedPDFOutputFormats lists formats for PDF export
 var SupportedPDFOutputFormats = []string{"png", "jpg", "jpeg", "bmp", "tiff", "gif"}
 
+// ResourceLimits caps ImageMagick resource usage per job via -limit.
+// Sizes use ImageMagick units (e.g. "256MiB", "2GB"); Time is in seconds.
+// Empty values keep ImageMagick's own limits.
+type ResourceLimits struct {
+	Memory string `json:"memory,omitempty"`
+	Map    string `json:"map,omitempty"`
+	Disk   string `json:"disk,omitempty"`
+	Time   string `json:"time,omitempty"`
+}
+
+var (
+	limitSizeRe = regexp.MustCompile(`^(?i)\d+(\.\d+)?\s*([KMGTPE]i?B?|B)?$`)
+	limitTimeRe = regexp.MustCompile(`^\d+$`)
+)
+
+// validate clears limits that ImageMagick would reject.
+func (l *ResourceLimits) validate() {
+	for _, v := range []*string{&l.Memory, &l.Map, &l.Disk} {
+		if *v != "" && !limitSizeRe.MatchString(*v) {
+			*v = ""
+		}
+	}
+	if l.Time != "" && !limitTimeRe.MatchString(l.Time) {
+		l.Time = ""
+	}
+}
+
+// TimeoutConfig limits how long a single ImageMagick run may take. The
+// limit is BaseSeconds plus PerMBSeconds per MB of input plus
+// PerPageSeconds per PDF page, capped at MaxSeconds. A BaseSeconds of 0
+// disables timeouts.
+type TimeoutConfig struct {
+	BaseSeconds    int `json:"base_seconds"`
+	PerMBSeconds   int `json:"per_mb_seconds"`
+	PerPageSeconds int `json:"per_page_seconds"`
+	MaxSeconds     int `json:"max_seconds"` // 0 means no cap
+}
+
+// defaultTimeoutConfig returns the default timeout settings.
+func defaultTimeoutConfig() TimeoutConfig {
+	return TimeoutConfig{
+		BaseSeconds:    DefaultTimeoutBaseSeconds,
+		PerMBSeconds:   DefaultTimeoutPerMBSeconds,
+		PerPageSeconds: DefaultTimeoutPerPageSeconds,
+		MaxSeconds:     DefaultTimeoutMaxSeconds,
+	}
+}
+
+// validate resets negative values to defaults.
+func (t *TimeoutConfig) validate() {
+	d := defaultTimeoutConfig()
+	for _, v := range []struct{ val, def *int }{
+		{&t.BaseSeconds, &d.BaseSeconds},
+		{&t.PerMBSeconds, &d.PerMBSeconds},
+		{&t.PerPageSeconds, &d.PerPageSeconds},
+		{&t.MaxSeconds, &d.MaxSeconds},
+	} {
+		if *v.val < 0 {
+			*v.val = *v.def
+		}
+	}
+}
+
+// CoreLimits converts the configured resource limits for the core
+// package. A nil config gives no limits.
+func CoreLimits(cfg *Config) core.ResourceLimits {
+	if cfg == nil {
+		return core.ResourceLimits{}
+	}
+	return core.ResourceLimits{
+		Memory: cfg.Limits.Memory,
+		Map:    cfg.Limits.Map,
+		Disk:   cfg.Limits.Disk,
+		Time:   cfg.Limits.Time,
+	}
+}
+
+// CoreTimeout converts the configured timeouts for the core package. A
+// nil config gives no timeouts.
+func CoreTimeout(cfg *Config) core.TimeoutPolicy {
+	if cfg == nil {
+		return core.TimeoutPolicy{}
+	}
+	return core.TimeoutPolicy{
+		Base:    time.Duration(cfg.Timeouts.BaseSeconds) * time.Second,
+		PerMB:   time.Duration(cfg.Timeouts.PerMBSeconds) * time.Second,
+		PerPage: time.Duration(cfg.Timeouts.PerPageSeconds) * time.Second,
+		Max:     time.Duration(cfg.Timeouts.MaxSeconds) * time.Second,
+	}
+}
+
+// NameTemplates name the output files of each operation. A template is
+// the file name without extension, e.g. "{name}_{width}x{height}".
+type NameTemplates struct {
+	PDF      string `json:"pdf"`
+	Convert  string `json:"convert"`
+	Compress string `json:"compress"`
+}
+
+// defaultNameTemplates returns the default templates. The PDF default
+// follows the page prefix so older configs keep their page names.
+func defaultNameTemplates(prefix string) NameTemplates {
+	return NameTemplates{
+		PDF:      prefix + "{page}",
+		Convert:  DefaultConvertTemplate,
+		Compress: DefaultCompressTemplate,
+	}
+}
+
+// validate fills empty templates with defaults.
+func (t *NameTemplates) validate(prefix string) {
+	d := defaultNameTemplates(prefix)
+	for _, v := range []struct{ val, def *string }{
+		{&t.PDF, &d.PDF},
+		{&t.Convert, &d.Convert},
+		{&t.Compress, &d.Compress},
+	} {
+		if strings.TrimSpace(*v.val) == "" {
+			*v.val = *v.def
+		}
+	}
+}
+
+// LogConfig controls log output and retention
+type LogConfig struct {
+	Level      string `json:"level"`        // debug, info, warn or error
+	Format     string `json:"format"`       // text or json
+	MaxSizeMB  int    `json:"max_size_mb"`  // Rotate within a day at this size, 0 disables
+	MaxFiles   int    `json:"max_files"`    // Log files to keep, 0 keeps all
+	MaxAgeDays int    `json:"max_age_days"` // Delete logs older than this, 0 keeps all
+}
+
+// defaultLogConfig returns the default logging settings.
+func defaultLogConfig() LogConfig {
+	return LogConfig{
+		Level:      DefaultLogLevel,
+		Format:     DefaultLogFormat,
+		MaxSizeMB:  DefaultLogMaxSizeMB,
+		MaxFiles:   DefaultLogMaxFiles,
+		MaxAgeDays: DefaultLogMaxAgeDays,
+	}
+}
+
+// validate resets unknown values to defaults.
+func (l *LogConfig) validate() {
+	switch strings.ToLower(l.Level) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		l.Level = DefaultLogLevel
+	}
+	switch strings.ToLower(l.Format) {
+	case "text", "json":
+	default:
+		l.Format = DefaultLogFormat
+	}
+	if l.MaxSizeMB < 0 {
+		l.MaxSizeMB = DefaultLogMaxSizeMB
+	}
+	if l.MaxFiles < 0 {
+		l.MaxFiles = DefaultLogMaxFiles
+	}
+	if l.MaxAgeDays < 0 {
+		l.MaxAgeDays = DefaultLogMaxAgeDays
+	}
+}
+
 // Config holds user preferences
 type Config struct {
 	// PDF conversion settings
@@ -41,8 +246,30 @@ type Config struct {
 	// Compression settings
 	CompressPercent int `json:"compress_percent"`
 
+	// Output file names per operation
+	Templates NameTemplates `json:"templates"`
+
+	// What to do with existing outputs: overwrite, skip, increment or fail
+	Collision string `json:"collision"`
+
 	// UI preferences
-	LastDirectory string `json:"last_directory,omitempty"`
+	LastDirectory string   `json:"last_directory,omitempty"`
+	Bookmarks     []string `json:"bookmarks,omitempty"`
+	RecentDirs    []string `json:"recent_dirs,omitempty"` // Most recent first
+	Preview       string   `json:"preview"`               // auto, kitty, sixel, blocks or off
+
+	// Executable paths, empty to search PATH
+	MagickPath      string `json:"magick_path,omitempty"`
+	GhostscriptPath string `json:"ghostscript_path,omitempty"`
+
+	// ImageMagick resource limits applied to every job
+	Limits ResourceLimits `json:"limits"`
+
+	// Per-job timeouts that stop runaway processes
+	Timeouts TimeoutConfig `json:"timeouts"`
+
+	// Logging
+	Logging LogConfig `json:"logging"`
 
 	// Internal: config file path (not persisted)
 	filePath string `json:"-"`
@@ -56,6 +283,11 @@ func NewConfig() *Config {
 		Quality:         DefaultQuality,
 		Prefix:          DefaultPrefix,
 		CompressPercent: DefaultCompressPercent,
+		Templates:       defaultNameTemplates(DefaultPrefix),
+		Collision:       DefaultCollision,
+		Preview:         DefaultPreview,
+		Timeouts:        defaultTimeoutConfig(),
+		Logging:         defaultLogConfig(),
 	}
 }
 
@@ -73,6 +305,9 @@ func Load() (*Config, error) {
 		return cfg, err
 	}
 
+	// Configs without templates derive the PDF one from their prefix
+	cfg.Templates.PDF = ""
+
 	if err := json.Unmarshal(data, cfg); err != nil {
 		// Return defaults if config is corrupted
 		return NewConfig(), nil
@@ -130,6 +365,27 @@ func (c *Config) validate() {
 	if c.OutputFormat == "" {
 		c.OutputFormat = DefaultOutputFormat
 	}
+	c.Collision = strings.ToLower(c.Collision)
+	switch c.Collision {
+	case "overwrite", "skip", "increment", "fail":
+	default:
+		c.Collision = DefaultCollision
+	}
+	c.Preview = strings.ToLower(c.Preview)
+	switch c.Preview {
+	case "auto", "kitty", "sixel", "blocks", "off":
+	default:
+		c.Preview = DefaultPreview
+	}
+	c.Templates.validate(c.Prefix)
+	c.Bookmarks = uniqueDirs(c.Bookmarks)
+	c.RecentDirs = uniqueDirs(c.RecentDirs)
+	if len(c.RecentDirs) > MaxRecentDirs {
+		c.RecentDirs = c.RecentDirs[:MaxRecentDirs]
+	}
+	c.Limits.validate()
+	c.Timeouts.validate()
+	c.Logging.validate()
 }
 
 // Reset restores default values.
@@ -139,7 +395,111 @@ func (c *Config) Reset() {
 	c.Quality = DefaultQuality
 	c.Prefix = DefaultPrefix
 	c.CompressPercent = DefaultCompressPercent
+	c.Templates = defaultNameTemplates(DefaultPrefix)
+	c.Collision = DefaultCollision
 	c.LastDirectory = ""
+	c.Bookmarks = nil
+	c.RecentDirs = nil
+	c.Preview = DefaultPreview
+	c.MagickPath = ""
+	c.GhostscriptPath = ""
+	c.Limits = ResourceLimits{}
+	c.Timeouts = defaultTimeoutConfig()
+	c.Logging = defaultLogConfig()
+}
+
+// AddRecentDir records dir as the most recently used folder and the one
+// the file picker opens next time.
+func (c *Config) AddRecentDir(dir string) {
+	if dir == "" {
+		return
+	}
+	c.LastDirectory = dir
+	c.RecentDirs = uniqueDirs(append([]string{dir}, c.RecentDirs...))
+	if len(c.RecentDirs) > MaxRecentDirs {
+		c.RecentDirs = c.RecentDirs[:MaxRecentDirs]
+	}
+}
+
+// IsBookmarked reports whether dir is bookmarked.
+func (c *Config) IsBookmarked(dir string) bool {
+	for _, b := range c.Bookmarks {
+		if sameDir(b, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleBookmark adds or removes a bookmark for dir and reports whether it
+// is now bookmarked.
+func (c *Config) ToggleBookmark(dir string) bool {
+	for i, b := range c.Bookmarks {
+		if sameDir(b, dir) {
+			c.Bookmarks = append(c.Bookmarks[:i], c.Bookmarks[i+1:]...)
+			return false
+		}
+	}
+	c.Bookmarks = append(c.Bookmarks, dir)
+	return true
+}
+
+// uniqueDirs drops empty and duplicate folders, keeping the first of each.
+func uniqueDirs(dirs []string) []string {
+	var out []string
+	for _, d := range dirs {
+		if d == "" {
+			continue
+		}
+		dup := false
+		for _, o := range out {
+			if sameDir(o, d) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// sameDir compares folder paths after cleaning them. Windows paths are
+// compared case-insensitively.
+func sameDir(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if filepath.Separator == '\\' {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// EffectiveMagickPath returns the ImageMagick executable path, preferring
+// the IMAGETOOL_MAGICK environment variable over the config file.
+func (c *Config) EffectiveMagickPath() string {
+	if v := os.Getenv(EnvMagickPath); v != "" {
+		return v
+	}
+	return c.MagickPath
+}
+
+// EffectiveLogLevel returns the minimum log level, preferring the
+// IMAGETOOL_LOG_LEVEL environment variable over the config file.
+func (c *Config) EffectiveLogLevel() string {
+	if v := os.Getenv(EnvLogLevel); v != "" {
+		return v
+	}
+	return c.Logging.Level
+}
+
+// EffectiveGhostscriptPath returns the Ghostscript executable path, preferring
+// the IMAGETOOL_GS environment variable over the config file.
+func (c *Config) EffectiveGhostscriptPath() string {
+	if v := os.Getenv(EnvGhostscriptPath); v != "" {
+		return v
+	}
+	return c.GhostscriptPath
 }
 
 // getConfigPath returns the path to the config file.
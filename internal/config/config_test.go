@@ -4,6 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"imagetool/internal/core"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -24,6 +27,9 @@ func TestNewConfig(t *testing.T) {
 	if cfg.CompressPercent != DefaultCompressPercent {
 		t.Errorf("CompressPercent = %d; want %d", cfg.CompressPercent, DefaultCompressPercent)
 	}
+	if cfg.Logging.Level != DefaultLogLevel || cfg.Logging.MaxFiles != DefaultLogMaxFiles {
+		t.Errorf("Logging = %+v; want defaults", cfg.Logging)
+	}
 }
 
 func TestConfigValidate(t *testing.T) {
@@ -33,6 +39,12 @@ func TestConfigValidate(t *testing.T) {
 		CompressPercent: 0,   // Below min
 		Prefix:          "",  // Empty
 		OutputFormat:    "",  // Empty
+		Limits: ResourceLimits{
+			Memory: "256MiB",
+			Map:    "lots", // Invalid
+			Disk:   "2GB",
+			Time:   "1.5", // Invalid
+		},
 	}
 
 	cfg.validate()
@@ -52,6 +64,10 @@ func TestConfigValidate(t *testing.T) {
 	if cfg.OutputFormat != DefaultOutputFormat {
 		t.Errorf("OutputFormat = %s; want %s", cfg.OutputFormat, DefaultOutputFormat)
 	}
+	want := ResourceLimits{Memory: "256MiB", Disk: "2GB"}
+	if cfg.Limits != want {
+		t.Errorf("Limits = %+v; want %+v", cfg.Limits, want)
+	}
 }
 
 func TestConfigSaveLoad(t *testing.T) {
@@ -98,6 +114,7 @@ func TestConfigReset(t *testing.T) {
 		Prefix:          "Custom-",
 		CompressPercent: 25,
 		LastDirectory:   "/some/path",
+		MagickPath:      "/opt/magick",
 	}
 
 	cfg.Reset()
@@ -120,4 +137,157 @@ func TestConfigReset(t *testing.T) {
 	if cfg.LastDirectory != "" {
 		t.Errorf("LastDirectory = %s; want empty", cfg.LastDirectory)
 	}
+	if cfg.MagickPath != "" {
+		t.Errorf("MagickPath = %s; want empty", cfg.MagickPath)
+	}
+}
+
+func TestEffectiveExecutablePaths(t *testing.T) {
+	cfg := NewConfig()
+	cfg.MagickPath = "/opt/im/magick"
+	cfg.GhostscriptPath = "/opt/gs/gs"
+
+	t.Setenv(EnvMagickPath, "")
+	t.Setenv(EnvGhostscriptPath, "")
+	if got := cfg.EffectiveMagickPath(); got != cfg.MagickPath {
+		t.Errorf("EffectiveMagickPath() = %s; want %s", got, cfg.MagickPath)
+	}
+
+	t.Setenv(EnvMagickPath, "/env/magick")
+	t.Setenv(EnvGhostscriptPath, "/env/gs")
+	if got := cfg.EffectiveMagickPath(); got != "/env/magick" {
+		t.Errorf("EffectiveMagickPath() = %s; want /env/magick", got)
+	}
+	if got := cfg.EffectiveGhostscriptPath(); got != "/env/gs" {
+		t.Errorf("EffectiveGhostscriptPath() = %s; want /env/gs", got)
+	}
+}
+
+func TestTimeoutConfigValidate(t *testing.T) {
+	tc := TimeoutConfig{BaseSeconds: -5, PerMBSeconds: 0, PerPageSeconds: 3, MaxSeconds: -1}
+	tc.validate()
+
+	if tc.BaseSeconds != DefaultTimeoutBaseSeconds {
+		t.Errorf("BaseSeconds = %d; want %d", tc.BaseSeconds, DefaultTimeoutBaseSeconds)
+	}
+	if tc.PerMBSeconds != 0 || tc.PerPageSeconds != 3 {
+		t.Errorf("PerMBSeconds/PerPageSeconds = %d/%d; want 0/3", tc.PerMBSeconds, tc.PerPageSeconds)
+	}
+	if tc.MaxSeconds != DefaultTimeoutMaxSeconds {
+		t.Errorf("MaxSeconds = %d; want %d", tc.MaxSeconds, DefaultTimeoutMaxSeconds)
+	}
+}
+
+func TestCoreConversions(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Limits = ResourceLimits{Memory: "256MiB", Time: "60"}
+	cfg.Timeouts = TimeoutConfig{BaseSeconds: 30, PerMBSeconds: 2, PerPageSeconds: 5, MaxSeconds: 0}
+
+	if got := CoreLimits(cfg); got.Memory != "256MiB" || got.Time != "60" || got.Disk != "" {
+		t.Errorf("CoreLimits = %+v; want the configured limits", got)
+	}
+	want := core.TimeoutPolicy{Base: 30 * time.Second, PerMB: 2 * time.Second, PerPage: 5 * time.Second}
+	if got := CoreTimeout(cfg); got != want {
+		t.Errorf("CoreTimeout = %+v; want %+v", got, want)
+	}
+	if CoreLimits(nil) != (core.ResourceLimits{}) || CoreTimeout(nil) != (core.TimeoutPolicy{}) {
+		t.Error("a nil config should give no limits or timeouts")
+	}
+}
+
+func TestNameTemplatesValidate(t *testing.T) {
+	cfg := &Config{Prefix: "Scan-", Templates: NameTemplates{Convert: "{name}-{format}", Compress: " "}}
+	cfg.validate()
+
+	want := NameTemplates{
+		PDF:      "Scan-{page}",
+		Convert:  "{name}-{format}",
+		Compress: DefaultCompressTemplate,
+	}
+	if cfg.Templates != want {
+		t.Errorf("Templates = %+v; want %+v", cfg.Templates, want)
+	}
+}
+
+func TestCollisionValidate(t *testing.T) {
+	for in, want := range map[string]string{"Skip": "skip", "increment": "increment", "": DefaultCollision, "rename": DefaultCollision} {
+		cfg := &Config{Collision: in}
+		cfg.validate()
+		if cfg.Collision != want {
+			t.Errorf("Collision %q validated to %q; want %q", in, cfg.Collision, want)
+		}
+	}
+}
+
+func TestPreviewValidate(t *testing.T) {
+	for in, want := range map[string]string{"Kitty": "kitty", "off": "off", "": DefaultPreview, "iterm": DefaultPreview} {
+		cfg := &Config{Preview: in}
+		cfg.validate()
+		if cfg.Preview != want {
+			t.Errorf("Preview %q validated to %q; want %q", in, cfg.Preview, want)
+		}
+	}
+}
+
+func TestRecentDirsAndBookmarks(t *testing.T) {
+	cfg := NewConfig()
+	for i := 0; i < MaxRecentDirs+2; i++ {
+		cfg.AddRecentDir(filepath.Join("dir", string(rune('a'+i))))
+	}
+	cfg.AddRecentDir(filepath.Join("dir", "c") + string(filepath.Separator))
+
+	if len(cfg.RecentDirs) != MaxRecentDirs {
+		t.Fatalf("len(RecentDirs) = %d; want %d", len(cfg.RecentDirs), MaxRecentDirs)
+	}
+	if cfg.RecentDirs[0] != filepath.Join("dir", "c")+string(filepath.Separator) {
+		t.Errorf("RecentDirs[0] = %s; want the re-added dir first", cfg.RecentDirs[0])
+	}
+	if cfg.LastDirectory != cfg.RecentDirs[0] {
+		t.Errorf("LastDirectory = %s; want %s", cfg.LastDirectory, cfg.RecentDirs[0])
+	}
+
+	if !cfg.ToggleBookmark("/photos") || !cfg.IsBookmarked("/photos/") {
+		t.Error("ToggleBookmark should add a bookmark")
+	}
+	if cfg.ToggleBookmark("/photos") || cfg.IsBookmarked("/photos") {
+		t.Error("ToggleBookmark should remove an existing bookmark")
+	}
+
+	cfg.Bookmarks = []string{"/a", "", "/a", "/b"}
+	cfg.validate()
+	if len(cfg.Bookmarks) != 2 {
+		t.Errorf("Bookmarks = %v; want duplicates and empties dropped", cfg.Bookmarks)
+	}
+}
+
+func TestLogConfigValidate(t *testing.T) {
+	l := LogConfig{Level: "verbose", Format: "xml", MaxSizeMB: -1, MaxFiles: 0, MaxAgeDays: 7}
+	l.validate()
+
+	if l.Level != DefaultLogLevel {
+		t.Errorf("Level = %s; want %s", l.Level, DefaultLogLevel)
+	}
+	if l.Format != DefaultLogFormat {
+		t.Errorf("Format = %s; want %s", l.Format, DefaultLogFormat)
+	}
+	if l.MaxSizeMB != DefaultLogMaxSizeMB {
+		t.Errorf("MaxSizeMB = %d; want %d", l.MaxSizeMB, DefaultLogMaxSizeMB)
+	}
+	if l.MaxFiles != 0 || l.MaxAgeDays != 7 {
+		t.Errorf("MaxFiles/MaxAgeDays = %d/%d; want 0/7", l.MaxFiles, l.MaxAgeDays)
+	}
+}
+
+func TestEffectiveLogLevel(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Logging.Level = "warn"
+
+	t.Setenv(EnvLogLevel, "")
+	if got := cfg.EffectiveLogLevel(); got != "warn" {
+		t.Errorf("EffectiveLogLevel() = %s; want warn", got)
+	}
+	t.Setenv(EnvLogLevel, "debug")
+	if got := cfg.EffectiveLogLevel(); got != "debug" {
+		t.Errorf("EffectiveLogLevel() = %s; want debug", got)
+	}
 }
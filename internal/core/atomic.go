@@ -0,0 +1,311 @@
+package core
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TempPrefix starts the name of every partial output. ImageMagick writes
+// to such a file or folder next to the target, and it is renamed into
+// place only when the job succeeds.
+const TempPrefix = ".imagetool-tmp-"
+
+// tempIDLen is the length of the random ID after TempPrefix.
+const tempIDLen = 12
+
+// journalExt is the extension of per-process journal files.
+const journalExt = ".journal"
+
+// tempJournal records the partial outputs of this process in
+// <dir>/<pid>.journal, so a later run can remove what a crashed run left.
+// Lines are "+ path" when a temp is created and "- path" when it is
+// renamed or removed.
+type tempJournal struct {
+	mu   sync.Mutex
+	dir  string
+	file *os.File
+}
+
+var journal tempJournal
+
+// SetTempJournal enables journaling of partial outputs in dir, or
+// disables it for "". Without it, writes are still atomic but crashed runs
+// leave their temps behind.
+func SetTempJournal(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.file != nil && dir != journal.dir {
+		journal.file.Close()
+		journal.file = nil
+	}
+	journal.dir = dir
+	return nil
+}
+
+// CloseTempJournal closes and removes this process's journal. Call it on
+// clean exit, after all jobs have finished.
+func CloseTempJournal() {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.file != nil {
+		journal.file.Close()
+		os.Remove(journal.file.Name())
+		journal.file = nil
+	}
+}
+
+// record appends a journal line, opening the journal on first use.
+// Journal errors never fail a job.
+func (j *tempJournal) record(op, path string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.dir == "" {
+		return
+	}
+	if j.file == nil {
+		name := filepath.Join(j.dir, strconv.Itoa(os.Getpid())+journalExt)
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return
+		}
+		j.file = file
+	}
+	fmt.Fprintf(j.file, "%s %s\n", op, path)
+}
+
+// CleanStaleTemps removes the partial outputs listed in journals of
+// processes that are no longer running, and returns how many it removed.
+func CleanStaleTemps() int {
+	journal.mu.Lock()
+	dir := journal.dir
+	journal.mu.Unlock()
+	if dir == "" {
+		return 0
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	removed := 0
+	for _, e := range entries {
+		pid, err := strconv.Atoi(strings.TrimSuffix(e.Name(), journalExt))
+		if err != nil || !strings.HasSuffix(e.Name(), journalExt) {
+			continue
+		}
+		if pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+
+		name := filepath.Join(dir, e.Name())
+		for _, path := range pendingTemps(name) {
+			removed += removeTemp(path)
+		}
+		os.Remove(name)
+	}
+	return removed
+}
+
+// pendingTemps returns the temps a journal created but never finished.
+func pendingTemps(journalPath string) []string {
+	file, err := os.Open(journalPath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var order []string
+	pending := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		op, path, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		switch op {
+		case "+":
+			if !pending[path] {
+				order = append(order, path)
+			}
+			pending[path] = true
+		case "-":
+			pending[path] = false
+		}
+	}
+
+	var paths []string
+	for _, path := range order {
+		if pending[path] {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// removeTemp deletes a temp and any frames ImageMagick split it into,
+// returning how many entries it removed. Paths without TempPrefix are
+// never touched.
+func removeTemp(path string) int {
+	base := filepath.Base(path)
+	if !strings.HasPrefix(base, TempPrefix) || len(base) < len(TempPrefix)+tempIDLen {
+		return 0
+	}
+	id := base[:len(TempPrefix)+tempIDLen]
+
+	dir := filepath.Dir(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), id) && os.RemoveAll(filepath.Join(dir, e.Name())) == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// newTempID returns a random ID for a temp name.
+func newTempID() string {
+	b := make([]byte, tempIDLen/2)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newTempOutput returns a path next to target for ImageMagick to write
+// to. It keeps target's name and extension, which select the format.
+func newTempOutput(target string) string {
+	temp := filepath.Join(filepath.Dir(target), TempPrefix+newTempID()+"-"+filepath.Base(target))
+	journal.record("+", temp)
+	return temp
+}
+
+// newTempDir creates a temp folder inside parent.
+func newTempDir(parent string) (string, error) {
+	dir := filepath.Join(parent, TempPrefix+newTempID())
+	journal.record("+", dir)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		journal.record("-", dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// discardTemp removes a temp file or folder and any frames split from it.
+func discardTemp(temp string) {
+	removeTemp(temp)
+	journal.record("-", temp)
+}
+
+// errFramesExist is returned by commitOutput under CollisionSkip when a
+// frame's name is taken, with the existing frames as its outputs.
+var errFramesExist = errors.New("output frames already exist")
+
+// commitOutput renames a finished temp to target. When ImageMagick split
+// a multi-frame input into temp-0.ext, temp-1.ext, ... those are renamed
+// to target-0.ext, target-1.ext, ... instead, in frame order. Frame names
+// were not known when target was resolved, so each is resolved against
+// policy and checked against input before any is renamed; under
+// CollisionSkip one existing frame skips them all. It returns the final
+// paths.
+func commitOutput(temp, target string, policy CollisionPolicy, input string) ([]string, error) {
+	defer journal.record("-", temp)
+
+	if fileExists(temp) {
+		if err := os.Rename(temp, target); err != nil {
+			removeTemp(temp)
+			return nil, err
+		}
+		return []string{target}, nil
+	}
+
+	frames, err := tempFrames(temp)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("ImageMagick reported success but wrote no output")
+	}
+
+	ext := filepath.Ext(target)
+	targetStem := strings.TrimSuffix(target, ext) + "-"
+	outputs := make([]string, 0, len(frames))
+	var existing []string
+	for _, f := range frames {
+		resolved, skip, err := resolveOutput(targetStem+strconv.Itoa(f.index)+ext, policy)
+		if err == nil && !skip {
+			defer releaseOutput(resolved)
+			err = CheckNotInput(input, resolved)
+		}
+		if err != nil {
+			removeTemp(temp)
+			return nil, err
+		}
+		if skip {
+			existing = append(existing, resolved)
+		}
+		outputs = append(outputs, resolved)
+	}
+	if len(existing) > 0 {
+		removeTemp(temp)
+		return existing, fmt.Errorf("%w: %s", errFramesExist, existing[0])
+	}
+
+	for i, f := range frames {
+		if err := os.Rename(f.path, outputs[i]); err != nil {
+			removeTemp(temp)
+			return outputs[:i], err
+		}
+	}
+	return outputs, nil
+}
+
+// tempFrame is one frame ImageMagick split a temp into.
+type tempFrame struct {
+	path  string
+	index int
+}
+
+// tempFrames returns the frames split from temp, ordered by index.
+func tempFrames(temp string) ([]tempFrame, error) {
+	dir := filepath.Dir(temp)
+	ext := filepath.Ext(temp)
+	stem := strings.TrimSuffix(filepath.Base(temp), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var frames []tempFrame
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, stem) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, stem), ext))
+		if err != nil || index < 0 {
+			continue
+		}
+		frames = append(frames, tempFrame{path: filepath.Join(dir, name), index: index})
+	}
+	sort.Slice(frames, func(i, j int) bool {
+		return frames[i].index < frames[j].index
+	})
+	return frames, nil
+}
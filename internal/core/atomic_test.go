@@ -0,0 +1,205 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeMagick puts a magick shell script on PATH for the test.
+func fakeMagick(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake magick script requires a POSIX shell")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "magick"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// dirNames lists the names in dir.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestConvertImageAtomic(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.png")
+	if err := os.WriteFile(input, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.jpg")
+
+	// A crash after a partial write leaves no output and no temp
+	fakeMagick(t, `for last; do :; done; printf partial > "$last"; exit 1`+"\n")
+	result := ConvertImage(ConvertImageOptions{InputPath: input, OutputFormat: FormatJPG, OutputPath: output})
+	if result.Success {
+		t.Fatal("expected the conversion to fail")
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("failed conversion left %v; want only the input", names)
+	}
+
+	// A successful write is renamed into place
+	fakeMagick(t, `for last; do :; done; printf done > "$last"`+"\n")
+	result = ConvertImage(ConvertImageOptions{InputPath: input, OutputFormat: FormatJPG, OutputPath: output})
+	if !result.Success || result.OutputPath != output {
+		t.Fatalf("result = %+v; want success writing %s", result, output)
+	}
+	if data, _ := os.ReadFile(output); string(data) != "done" {
+		t.Errorf("output = %q; want done", data)
+	}
+	for _, name := range dirNames(t, dir) {
+		if strings.HasPrefix(name, TempPrefix) {
+			t.Errorf("temp %s left behind", name)
+		}
+	}
+}
+
+// writeFrames writes the frames ImageMagick would split temp into.
+func writeFrames(t *testing.T, temp string, count int) {
+	t.Helper()
+	stem := strings.TrimSuffix(temp, ".png")
+	for i := 0; i < count; i++ {
+		if err := os.WriteFile(fmt.Sprintf("%s-%d.png", stem, i), []byte("frame"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCommitOutputFrames(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "anim.png")
+	temp := newTempOutput(target)
+	writeFrames(t, temp, 12)
+
+	outputs, err := commitOutput(temp, target, CollisionOverwrite, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for i := 0; i < 12; i++ {
+		want = append(want, filepath.Join(dir, fmt.Sprintf("anim-%d.png", i)))
+	}
+	if strings.Join(outputs, ",") != strings.Join(want, ",") {
+		t.Errorf("commitOutput = %v; want %v", outputs, want)
+	}
+
+	if _, err := commitOutput(newTempOutput(target), target, CollisionOverwrite, ""); err == nil {
+		t.Error("commitOutput should fail when nothing was written")
+	}
+}
+
+func TestCommitOutputFramesCollide(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "anim.png")
+	existing := filepath.Join(dir, "anim-1.png")
+	if err := os.WriteFile(existing, []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy  CollisionPolicy
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{CollisionSkip, "", []string{"anim-1.png"}, errFramesExist},
+		{CollisionFail, "", nil, ErrOutputExists},
+		{CollisionIncrement, "", []string{"anim-0.png", "anim-1-1.png"}, nil},
+		{CollisionOverwrite, existing, nil, ErrOutputIsInput},
+	}
+	for _, tt := range tests {
+		temp := newTempOutput(target)
+		writeFrames(t, temp, 2)
+
+		outputs, err := commitOutput(temp, target, tt.policy, tt.input)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: commitOutput error = %v; want %v", tt.policy, err, tt.wantErr)
+		}
+		var names []string
+		for _, out := range outputs {
+			names = append(names, filepath.Base(out))
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: commitOutput = %v; want %v", tt.policy, names, tt.want)
+		}
+		if data, _ := os.ReadFile(existing); string(data) != "mine" {
+			t.Fatalf("%s: the existing frame was overwritten", tt.policy)
+		}
+		for _, name := range dirNames(t, dir) {
+			if strings.HasPrefix(name, TempPrefix) {
+				t.Errorf("%s: temp %s left behind", tt.policy, name)
+			}
+		}
+		for _, out := range outputs {
+			if out != existing {
+				os.Remove(out)
+			}
+		}
+	}
+}
+
+func TestCleanStaleTemps(t *testing.T) {
+	journalDir := t.TempDir()
+	outDir := t.TempDir()
+	if err := SetTempJournal(journalDir); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTempJournal("")
+
+	// The pid of a process that has exited
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	deadPID := cmd.Process.Pid
+
+	write := func(name string) string {
+		path := filepath.Join(outDir, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	stale := write(TempPrefix + "0123456789ab-out.png")
+	write(TempPrefix + "0123456789ab-out-0.png")
+	finished := write(TempPrefix + "ba9876543210-done.png")
+	keep := write("photo.png")
+	notTemp := write("listed.png")
+
+	lines := "+ " + stale + "\n+ " + finished + "\n- " + finished + "\n+ " + notTemp + "\n"
+	journalPath := filepath.Join(journalDir, strconv.Itoa(deadPID)+journalExt)
+	if err := os.WriteFile(journalPath, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed := CleanStaleTemps(); removed != 2 {
+		t.Errorf("CleanStaleTemps removed %d; want 2", removed)
+	}
+	for _, p := range []string{finished, keep, notTemp} {
+		if !fileExists(p) {
+			t.Errorf("%s should not be removed", filepath.Base(p))
+		}
+	}
+	if fileExists(stale) || fileExists(journalPath) {
+		t.Error("stale temp and its journal should be removed")
+	}
+}
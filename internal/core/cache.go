@@ -0,0 +1,266 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// CacheFileName is the incremental cache in the config directory.
+const CacheFileName = "cache.json"
+
+// OutputHash is a file an operation wrote and the SHA-256 of its content.
+type OutputHash struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// hashOutputs hashes every file a result lists as output.
+func hashOutputs(result Result) ([]OutputHash, error) {
+	paths := result.OutputPaths
+	if len(paths) == 0 {
+		paths = []string{result.OutputPath}
+	}
+	outputs := make([]OutputHash, 0, len(paths))
+	for _, path := range paths {
+		hash, err := HashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, OutputHash{Path: path, Hash: hash})
+	}
+	return outputs, nil
+}
+
+// outputsUnchanged reports whether there are outputs and each still has
+// its recorded hash.
+func outputsUnchanged(outputs []OutputHash) bool {
+	for _, out := range outputs {
+		if hash, err := HashFile(out.Path); err != nil || hash != out.Hash {
+			return false
+		}
+	}
+	return len(outputs) > 0
+}
+
+// CacheEntry records what an operation produced from one input. Options
+// is a hash of the serialized operation options and InputHash the SHA-256
+// of the input's content when it was processed.
+type CacheEntry struct {
+	Options   string       `json:"options"`
+	Input     string       `json:"input"`
+	InputHash string       `json:"input_hash"`
+	Outputs   []OutputHash `json:"outputs"`
+}
+
+// Cache remembers the outputs of earlier runs so an incremental run can
+// skip inputs that haven't changed. An input is up to date when its
+// content hash and the operation options match an entry and every output
+// the entry lists still exists with the recorded hash. The input's path
+// is part of the entry too, since outputs are named after it; processing
+// a changed input replaces its entry.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]CacheEntry // By options and input path
+	changed bool
+}
+
+// OpenCache loads the cache file at path. A missing file gives an empty
+// cache.
+func OpenCache(path string) (*Cache, error) {
+	c := &Cache{path: path, entries: make(map[string]CacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid cache file %s: %w", path, err)
+	}
+	for _, e := range entries {
+		c.entries[cacheKey(e.Options, e.Input)] = e
+	}
+	return c, nil
+}
+
+// Path returns the cache file.
+func (c *Cache) Path() string {
+	return c.path
+}
+
+// Outputs returns the set of files the cached entries list as outputs.
+func (c *Cache) Outputs() map[string]bool {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	outputs := make(map[string]bool)
+	for _, e := range c.entries {
+		for _, out := range e.Outputs {
+			outputs[out.Path] = true
+		}
+	}
+	return outputs
+}
+
+// Save writes the cache if it changed, first dropping the entries of
+// inputs that no longer exist. It writes through a uniquely named temp
+// file in the same directory, so a crash never leaves the cache truncated
+// and two processes saving at once never write the same temp.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, e := range c.entries {
+		if _, err := os.Stat(e.Input); errors.Is(err, os.ErrNotExist) {
+			delete(c.entries, key)
+			c.changed = true
+		}
+	}
+	if !c.changed {
+		return nil
+	}
+	entries := make([]CacheEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Input != entries[j].Input {
+			return entries[i].Input < entries[j].Input
+		}
+		return entries[i].Options < entries[j].Options
+	})
+
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	temp := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp, c.path)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+// cacheKey identifies an input under an operation's options.
+func cacheKey(options, input string) string {
+	return options + "\x00" + input
+}
+
+// hashOptions hashes the serialized options of an operation.
+func hashOptions(op BatchOperation, opts interface{}) string {
+	data, err := json.Marshal(struct {
+		Operation BatchOperation `json:"operation"`
+		Options   interface{}    `json:"options"`
+	}{op, opts})
+	if err != nil {
+		// Options that can't be serialized never match an entry
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheSlot is an input's place in the cache, found before it is
+// processed and filled in after.
+type cacheSlot struct {
+	cache     *Cache
+	options   string
+	input     string
+	inputHash string
+}
+
+// lookup finds an input in the cache. It returns the up-to-date result
+// when the input can be skipped. A nil cache, or an input that can't be
+// hashed, gives a slot that stores nothing.
+func (c *Cache) lookup(options, input string) (cacheSlot, Result, bool) {
+	if c == nil || options == "" {
+		return cacheSlot{}, Result{}, false
+	}
+	inputHash, err := HashFile(input)
+	if err != nil {
+		return cacheSlot{}, Result{}, false
+	}
+	slot := cacheSlot{cache: c, options: options, input: input, inputHash: inputHash}
+
+	c.mu.Lock()
+	e, ok := c.entries[cacheKey(options, input)]
+	c.mu.Unlock()
+	if !ok || e.InputHash != inputHash || !outputsUnchanged(e.Outputs) {
+		return slot, Result{}, false
+	}
+
+	var paths []string
+	var size int64
+	for _, out := range e.Outputs {
+		if info, err := os.Stat(out.Path); err == nil {
+			size += info.Size()
+		}
+		paths = append(paths, out.Path)
+	}
+	result := Result{
+		Success:    true,
+		Message:    "Up to date",
+		OutputPath: paths[0],
+		OutputSize: size,
+		UpToDate:   true,
+	}
+	if len(paths) > 1 {
+		result.OutputPaths = paths
+	}
+	return slot, result, true
+}
+
+// store records the outputs of a successfully processed input. Failed
+// and skipped inputs are not cached.
+func (s cacheSlot) store(result Result) {
+	if s.cache == nil || !result.Success || result.Skipped || result.UpToDate {
+		return
+	}
+	outputs, err := hashOutputs(result)
+	if err != nil {
+		return
+	}
+	e := CacheEntry{Options: s.options, Input: s.input, InputHash: s.inputHash, Outputs: outputs}
+
+	s.cache.mu.Lock()
+	defer s.cache.mu.Unlock()
+	s.cache.entries[cacheKey(s.options, s.input)] = e
+	s.cache.changed = true
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchCacheSkipsUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a", "b", "c"} {
+		input := filepath.Join(dir, name+".png")
+		if err := os.WriteFile(input, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input)
+	}
+	log := filepath.Join(t.TempDir(), "runs.log")
+	fakeMagick(t, fmt.Sprintf(`for last; do :; done
+echo "$1" >> %q
+cp "$1" "$last"
+`, log))
+
+	cachePath := filepath.Join(t.TempDir(), CacheFileName)
+	run := func(opts BatchConvertOptions) (BatchResult, string) {
+		t.Helper()
+		os.Remove(log)
+		cache, err := OpenCache(cachePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Cache = cache
+		batch := BatchConvert(context.Background(), inputs, opts)
+		if err := cache.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return batch, strings.Join(runLog(t, log), " ")
+	}
+	webp := BatchConvertOptions{OutputFormat: FormatWebP, Timeout: TimeoutPolicy{Base: time.Hour}}
+
+	if batch, _ := run(webp); batch.SuccessCount != 3 {
+		t.Fatalf("first run succeeded for %d; want 3", batch.SuccessCount)
+	}
+
+	// Limits and timeouts don't change the outputs
+	webp.Timeout = TimeoutPolicy{}
+	batch, ran := run(webp)
+	if batch.UpToDateCount != 3 || ran != "" {
+		t.Errorf("unchanged run: %d up to date, processed %q; want 3 and nothing", batch.UpToDateCount, ran)
+	}
+
+	// A changed input and an edited output are processed again
+	if err := os.WriteFile(inputs[0], []byte("a2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b_conv.webp"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	batch, ran = run(webp)
+	if batch.UpToDateCount != 1 || batch.SuccessCount != 2 || ran != "a.png b.png" {
+		t.Errorf("after changes: %d up to date, %d processed (%q); want 1, 2 (a.png b.png)",
+			batch.UpToDateCount, batch.SuccessCount, ran)
+	}
+
+	// Other options don't match the entries
+	if _, ran = run(BatchConvertOptions{OutputFormat: FormatWebP, NameTemplate: "{name}-small"}); ran != "a.png b.png c.png" {
+		t.Errorf("run with another template processed %q; want every input", ran)
+	}
+	if batch, ran = run(webp); batch.UpToDateCount != 3 || ran != "" {
+		t.Errorf("entries of other options were lost: %d up to date, processed %q", batch.UpToDateCount, ran)
+	}
+}
+
+func TestOpenCacheInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), CacheFileName)
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCache(path); err == nil {
+		t.Error("expected an error for a corrupt cache file")
+	}
+}
+
+func TestCacheSavePrunesMissingInputs(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), CacheFileName)
+	cache, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kept", "deleted"} {
+		input := filepath.Join(dir, name+".png")
+		output := filepath.Join(dir, name+".webp")
+		for _, p := range []string{input, output} {
+			if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		slot, _, _ := cache.lookup("options", input)
+		slot.store(Result{Success: true, OutputPath: output})
+	}
+	if err := os.Remove(filepath.Join(dir, "deleted.png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.entries[cacheKey("options", filepath.Join(dir, "kept.png"))]; !ok || len(reopened.entries) != 1 {
+		t.Errorf("saved entries = %v; want only kept.png's", reopened.entries)
+	}
+	if names := dirNames(t, filepath.Dir(cachePath)); len(names) != 1 || names[0] != CacheFileName {
+		t.Errorf("cache directory holds %v; want only %s", names, CacheFileName)
+	}
+}
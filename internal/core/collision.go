@@ -0,0 +1,221 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CollisionPolicy decides what happens when an output file already exists.
+type CollisionPolicy int
+
+const (
+	// CollisionOverwrite replaces the existing file.
+	CollisionOverwrite CollisionPolicy = iota
+	// CollisionSkip leaves the existing file and skips the job.
+	CollisionSkip
+	// CollisionIncrement writes to name-1.ext, name-2.ext, ... instead.
+	CollisionIncrement
+	// CollisionFail fails the job.
+	CollisionFail
+)
+
+// CollisionPolicies lists the policies in the order the UI cycles them.
+var CollisionPolicies = []CollisionPolicy{
+	CollisionOverwrite, CollisionSkip, CollisionIncrement, CollisionFail,
+}
+
+// ErrOutputExists is returned under CollisionFail when an output exists.
+var ErrOutputExists = errors.New("output file already exists")
+
+// ErrOutputIsInput is returned when an output would be written over the
+// input it is made from.
+var ErrOutputIsInput = errors.New("output is the input file")
+
+// maxIncrement bounds the search for a free incremented name.
+const maxIncrement = 10000
+
+// String returns the policy's config name.
+func (p CollisionPolicy) String() string {
+	switch p {
+	case CollisionSkip:
+		return "skip"
+	case CollisionIncrement:
+		return "increment"
+	case CollisionFail:
+		return "fail"
+	}
+	return "overwrite"
+}
+
+// Describe returns what the policy does to an existing file, for display.
+func (p CollisionPolicy) Describe() string {
+	switch p {
+	case CollisionSkip:
+		return "will be skipped"
+	case CollisionIncrement:
+		return "will be kept, output gets a numbered name"
+	case CollisionFail:
+		return "will make the job fail"
+	}
+	return "will be overwritten"
+}
+
+// ParseCollisionPolicy parses a config name, defaulting to overwrite.
+func ParseCollisionPolicy(s string) CollisionPolicy {
+	for _, p := range CollisionPolicies {
+		if strings.EqualFold(s, p.String()) {
+			return p
+		}
+	}
+	return CollisionOverwrite
+}
+
+// Next returns the policy after p in CollisionPolicies, wrapping around.
+func (p CollisionPolicy) Next() CollisionPolicy {
+	for i, q := range CollisionPolicies {
+		if q == p {
+			return CollisionPolicies[(i+1)%len(CollisionPolicies)]
+		}
+	}
+	return CollisionOverwrite
+}
+
+// reservedOutputs holds the outputs jobs in this process have resolved
+// but not yet written. resolveOutput treats them as taken, so jobs running
+// at the same time never pick the same name.
+var reservedOutputs = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+// resolveOutput applies the policy to an output path. It returns the path
+// to write, or skip when the job should not run. A name another job is
+// still writing counts as existing, except that it is never overwritten:
+// under CollisionOverwrite the output gets a numbered name instead. The
+// returned path is reserved until releaseOutput is called with it.
+func resolveOutput(path string, policy CollisionPolicy) (resolved string, skip bool, err error) {
+	reservedOutputs.Lock()
+	defer reservedOutputs.Unlock()
+
+	reserved := reservedOutputs.paths[reservationKey(path)]
+	if !reserved && !fileExists(path) {
+		return reserve(path), false, nil
+	}
+
+	switch policy {
+	case CollisionSkip:
+		return path, true, nil
+	case CollisionFail:
+		return "", false, fmt.Errorf("%w: %s", ErrOutputExists, path)
+	case CollisionIncrement:
+		return incrementPath(path)
+	}
+	if reserved {
+		return incrementPath(path)
+	}
+	return reserve(path), false, nil
+}
+
+// releaseOutput ends the reservation resolveOutput made for path, once
+// the output is written or the job has given up on it.
+func releaseOutput(path string) {
+	reservedOutputs.Lock()
+	defer reservedOutputs.Unlock()
+	delete(reservedOutputs.paths, reservationKey(path))
+}
+
+// reserve marks path as taken. The caller holds reservedOutputs.
+func reserve(path string) string {
+	reservedOutputs.paths[reservationKey(path)] = true
+	return path
+}
+
+// reservationKey is the absolute form of path, so one file has one key.
+func reservationKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// CheckNotInput fails with ErrOutputIsInput when output names the input
+// file, either by path or, when both exist, as the same file under
+// another name, so no template or policy can replace an original.
+func CheckNotInput(input, output string) error {
+	if input == "" || output == "" {
+		return nil
+	}
+	in, inErr := filepath.Abs(input)
+	out, outErr := filepath.Abs(output)
+	if inErr == nil && outErr == nil && in == out {
+		return fmt.Errorf("%w: %s", ErrOutputIsInput, output)
+	}
+	inInfo, err := os.Stat(input)
+	if err != nil {
+		return nil
+	}
+	if outInfo, err := os.Stat(output); err == nil && os.SameFile(inInfo, outInfo) {
+		return fmt.Errorf("%w: %s", ErrOutputIsInput, output)
+	}
+	return nil
+}
+
+// incrementPath reserves the first free name-N.ext next to path. The
+// caller holds reservedOutputs.
+func incrementPath(path string) (string, bool, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 1; i <= maxIncrement; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if !reservedOutputs.paths[reservationKey(candidate)] && !fileExists(candidate) {
+			return reserve(candidate), false, nil
+		}
+	}
+	return "", false, fmt.Errorf("no free name for %s", path)
+}
+
+// fileExists reports whether anything exists at path.
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// ExistingOutputs returns the paths that already exist.
+func ExistingOutputs(paths []string) []string {
+	var existing []string
+	for _, p := range paths {
+		if fileExists(p) {
+			existing = append(existing, p)
+		}
+	}
+	return existing
+}
+
+// skippedResult reports a job skipped because its output exists, with
+// the size of the existing file.
+func skippedResult(path string) Result {
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	return Result{
+		Success:    true,
+		Skipped:    true,
+		Message:    fmt.Sprintf("Skipped: %s already exists", filepath.Base(path)),
+		OutputPath: path,
+		OutputSize: size,
+	}
+}
+
+// collisionFailedResult reports an output collision under CollisionFail.
+func collisionFailedResult(err error) Result {
+	return Result{
+		Success: false,
+		Message: err.Error(),
+		Error:   err,
+	}
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestResolveOutput(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "out.png")
+	free := filepath.Join(dir, "free.png")
+	for _, p := range []string{existing, filepath.Join(dir, "out-1.png")} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		policy   CollisionPolicy
+		want     string
+		wantSkip bool
+		wantErr  bool
+	}{
+		{free, CollisionFail, free, false, false},
+		{existing, CollisionOverwrite, existing, false, false},
+		{existing, CollisionSkip, existing, true, false},
+		{existing, CollisionIncrement, filepath.Join(dir, "out-2.png"), false, false},
+		{existing, CollisionFail, "", false, true},
+	}
+
+	for _, tt := range tests {
+		got, skip, err := resolveOutput(tt.path, tt.policy)
+		if err == nil && !skip {
+			releaseOutput(got)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveOutput(%s, %s) error = %v", filepath.Base(tt.path), tt.policy, err)
+			continue
+		}
+		if tt.wantErr && !errors.Is(err, ErrOutputExists) {
+			t.Errorf("resolveOutput error %v is not ErrOutputExists", err)
+		}
+		if got != tt.want || skip != tt.wantSkip {
+			t.Errorf("resolveOutput(%s, %s) = %q, %v; want %q, %v",
+				filepath.Base(tt.path), tt.policy, got, skip, tt.want, tt.wantSkip)
+		}
+	}
+}
+
+func TestParseCollisionPolicy(t *testing.T) {
+	for _, p := range CollisionPolicies {
+		if got := ParseCollisionPolicy(p.String()); got != p {
+			t.Errorf("ParseCollisionPolicy(%q) = %v; want %v", p.String(), got, p)
+		}
+	}
+	if got := ParseCollisionPolicy("SKIP"); got != CollisionSkip {
+		t.Errorf("ParseCollisionPolicy(SKIP) = %v; want skip", got)
+	}
+	if got := ParseCollisionPolicy("bogus"); got != CollisionOverwrite {
+		t.Errorf("ParseCollisionPolicy(bogus) = %v; want overwrite", got)
+	}
+	if got := CollisionFail.Next(); got != CollisionOverwrite {
+		t.Errorf("CollisionFail.Next() = %v; want overwrite", got)
+	}
+}
+
+func TestBatchConvertCountsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a", "b"} {
+		input := filepath.Join(dir, name+".jpg")
+		output := filepath.Join(dir, name+"_conv.png")
+		for _, p := range []string{input, output} {
+			if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		inputs = append(inputs, input)
+	}
+
+	// Every output exists, so ImageMagick is never run
+	batch := BatchConvert(context.Background(), inputs, BatchConvertOptions{
+		OutputFormat: FormatPNG,
+		Collision:    CollisionSkip,
+	})
+	if batch.SkippedCount != 2 || batch.SuccessCount != 0 || batch.FailCount != 0 {
+		t.Errorf("skipped/success/fail = %d/%d/%d; want 2/0/0",
+			batch.SkippedCount, batch.SuccessCount, batch.FailCount)
+	}
+	for _, r := range batch.Results {
+		if !r.Skipped || !r.Success {
+			t.Errorf("result %+v should be a successful skip", r)
+		}
+	}
+}
+
+func TestConvertRefusesToReplaceInput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "photo.png")
+	if err := os.WriteFile(input, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fakeMagick(t, `for last; do :; done
+echo converted > "$last"
+`)
+
+	// {name} in the input's format names the input itself
+	result := ConvertImage(ConvertImageOptions{
+		InputPath:    input,
+		OutputFormat: FormatPNG,
+		NameTemplate: "{name}",
+	})
+	if result.Success || !errors.Is(result.Error, ErrOutputIsInput) {
+		t.Errorf("result = %+v; want ErrOutputIsInput", result)
+	}
+
+	// So does an output folder mirroring onto the input folder
+	batch := BatchConvert(context.Background(), []string{input}, BatchConvertOptions{
+		OutputFormat: FormatPNG,
+		InputRoot:    dir,
+		OutputRoot:   dir,
+	})
+	if batch.FailCount != 1 || !errors.Is(batch.Results[0].Error, ErrOutputIsInput) {
+		t.Errorf("mirrored batch = %+v; want ErrOutputIsInput", batch.Results)
+	}
+	if data, _ := os.ReadFile(input); string(data) != "original" {
+		t.Errorf("input was replaced with %q", data)
+	}
+
+	// Skipping leaves the input alone, so it is allowed
+	result = ConvertImage(ConvertImageOptions{
+		InputPath:    input,
+		OutputFormat: FormatPNG,
+		NameTemplate: "{name}",
+		Collision:    CollisionSkip,
+	})
+	if !result.Skipped {
+		t.Errorf("result = %+v; want skipped", result)
+	}
+}
+
+func TestCheckNotInputSameFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(input, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.png")
+	if err := os.Link(input, link); err != nil {
+		t.Skip("hard links not supported:", err)
+	}
+
+	if err := CheckNotInput(input, filepath.Join(dir, ".", "a.png")); !errors.Is(err, ErrOutputIsInput) {
+		t.Errorf("unclean path: %v; want ErrOutputIsInput", err)
+	}
+	if err := CheckNotInput(input, link); !errors.Is(err, ErrOutputIsInput) {
+		t.Errorf("hard link: %v; want ErrOutputIsInput", err)
+	}
+	if err := CheckNotInput(input, filepath.Join(dir, "b.png")); err != nil {
+		t.Errorf("other file: %v", err)
+	}
+}
+
+func TestConcurrentConvertsGetDistinctOutputs(t *testing.T) {
+	// Each conversion writes its input's name to its output, slowly enough
+	// that the jobs overlap
+	fakeMagick(t, `for a; do out=$a; done
+sleep 0.1
+echo "$1" > "$out"
+`)
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.png")
+	if err := os.WriteFile(output, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []CollisionPolicy{CollisionIncrement, CollisionOverwrite} {
+		const jobs = 4
+		inputs := make([]string, jobs)
+		for i := range inputs {
+			inputs[i] = filepath.Join(dir, fmt.Sprintf("%s-%d.jpg", policy, i))
+			if err := os.WriteFile(inputs[i], []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		results := make([]Result, jobs)
+		var wg sync.WaitGroup
+		for i := range inputs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = ConvertImageContext(context.Background(), ConvertImageOptions{
+					InputPath:    inputs[i],
+					OutputPath:   output,
+					OutputFormat: FormatPNG,
+					Collision:    policy,
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		seen := make(map[string]bool)
+		for i, r := range results {
+			if !r.Success {
+				t.Fatalf("%s: job %d failed: %s", policy, i, r.Message)
+			}
+			if seen[r.OutputPath] {
+				t.Fatalf("%s: two jobs wrote %s", policy, filepath.Base(r.OutputPath))
+			}
+			seen[r.OutputPath] = true
+			data, err := os.ReadFile(r.OutputPath)
+			if err != nil || strings.TrimSpace(string(data)) != inputs[i] {
+				t.Errorf("%s: %s holds %q; want job %d's output", policy, filepath.Base(r.OutputPath), data, i)
+			}
+		}
+		if policy == CollisionIncrement && seen[output] {
+			t.Errorf("increment: a job replaced the existing %s", filepath.Base(output))
+		}
+		if len(reservedOutputs.paths) != 0 {
+			t.Errorf("%s: reservations left after the jobs: %v", policy, reservedOutputs.paths)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+)
+
+// maxCompareSide bounds the size images are compared at so very large
+// originals do not need gigabytes of memory.
+const maxCompareSide = 4096
+
+// ssimWindow and ssimStride set the windows SSIM is averaged over.
+const (
+	ssimWindow = 8
+	ssimStride = 4
+)
+
+// msssimWeights weight each scale of MS-SSIM, finest first, as in Wang,
+// Simoncelli and Bovik (2003).
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// Metrics measures how much a processed image differs from its original.
+type Metrics struct {
+	Width      int     // width the images were compared at
+	Height     int     // height the images were compared at
+	PSNR       float64 // peak signal-to-noise ratio in dB; +Inf when identical
+	SSIM       float64 // structural similarity of the luma, 1 when identical
+	MSSSIM     float64 // multi-scale SSIM, 1 when identical
+	MAE        float64 // mean absolute difference per channel, 0-255
+	MaxDiff    int     // largest difference in any channel of any pixel, 0-255
+	DiffPixels int     // pixels with a channel differing by more than the threshold
+}
+
+// Identical reports whether the images matched exactly.
+func (m Metrics) Identical() bool {
+	return m.MaxDiff == 0
+}
+
+// DiffPercent returns DiffPixels as a percentage of all pixels.
+func (m Metrics) DiffPercent() float64 {
+	if m.Width == 0 || m.Height == 0 {
+		return 0
+	}
+	return float64(m.DiffPixels) * 100 / float64(m.Width*m.Height)
+}
+
+// CompareOptions configures Compare.
+type CompareOptions struct {
+	Original  string
+	Processed string
+
+	// Threshold is the channel difference a pixel may have and still be
+	// counted as unchanged, 0-255.
+	Threshold int
+
+	// DiffPath, if set, receives a PNG of the original faded to grey with
+	// changed pixels in red, brighter for larger differences.
+	DiffPath string
+}
+
+// Compare measures Processed against Original. Both are read at the
+// original's size and flattened onto white, so an output in another format
+// or with transparency removed can still be compared. Only the first page
+// or frame is used.
+func Compare(ctx context.Context, opts CompareOptions) (Metrics, error) {
+	ref, err := loadReference(ctx, opts.Original)
+	if err != nil {
+		return Metrics{}, err
+	}
+	b, err := ref.read(ctx, opts.Processed)
+	if err != nil {
+		return Metrics{}, err
+	}
+
+	m := measure(ref.pix, b, ref.w, ref.h, opts.Threshold)
+	if opts.DiffPath != "" {
+		if err := writeDiffImage(opts.DiffPath, ref.pix, b, ref.w, ref.h, opts.Threshold); err != nil {
+			return m, fmt.Errorf("writing diff image: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// reference is an original decoded once so several outputs can be
+// measured against it.
+type reference struct {
+	w, h int
+	pix  []uint8
+	y    []float64 // Luma, computed on first use
+}
+
+// loadReference decodes the original at the size comparisons use.
+func loadReference(ctx context.Context, path string) (*reference, error) {
+	w, h, err := imageDimensions(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	w, h = compareSize(w, h)
+
+	pix, err := readPixels(ctx, path, w, h)
+	if err != nil {
+		return nil, err
+	}
+	return &reference{w: w, h: h, pix: pix}, nil
+}
+
+// luma returns the reference's luma plane.
+func (r *reference) luma() []float64 {
+	if r.y == nil {
+		r.y = luma(r.pix)
+	}
+	return r.y
+}
+
+// read decodes another image at the reference's size.
+func (r *reference) read(ctx context.Context, path string) ([]uint8, error) {
+	return readPixels(ctx, path, r.w, r.h)
+}
+
+// compareSize scales w×h down to fit maxCompareSide, keeping the aspect.
+func compareSize(w, h int) (int, int) {
+	longest := w
+	if h > longest {
+		longest = h
+	}
+	if longest <= maxCompareSide {
+		return w, h
+	}
+	scale := float64(maxCompareSide) / float64(longest)
+	return max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+}
+
+// readPixels decodes the first frame of path at exactly w×h as 8-bit RGB.
+func readPixels(ctx context.Context, path string, w, h int) ([]uint8, error) {
+	file, err := os.CreateTemp("", TempPrefix+"compare-*.png")
+	if err != nil {
+		return nil, err
+	}
+	temp := file.Name()
+	file.Close()
+	defer os.Remove(temp)
+
+	cmd := magickCommand(ctx, ResourceLimits{}, path+"[0]", "-auto-orient",
+		"-resize", fmt.Sprintf("%dx%d!", w, h),
+		"-background", "white", "-alpha", "remove", "-alpha", "off", "-depth", "8", temp)
+	if output, err := runCommand(ctx, cmd); err != nil {
+		return nil, ClassifyError(err, string(output))
+	}
+
+	in, err := os.Open(temp)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	img, err := png.Decode(in)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+		return nil, fmt.Errorf("reading %s: got %dx%d, want %dx%d", path, img.Bounds().Dx(), img.Bounds().Dy(), w, h)
+	}
+	return rgbPixels(img), nil
+}
+
+// rgbPixels returns the pixels of img as packed RGB bytes.
+func rgbPixels(img image.Image) []uint8 {
+	b := img.Bounds()
+	rgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+
+	pix := make([]uint8, 0, b.Dx()*b.Dy()*3)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		pix = append(pix, rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2])
+	}
+	return pix
+}
+
+// measure compares two w×h RGB images, counting the pixels that differ
+// by more than threshold.
+func measure(a, b []uint8, w, h, threshold int) Metrics {
+	m := Metrics{Width: w, Height: h}
+
+	var sum, abs float64
+	for i := 0; i < len(a); i += 3 {
+		changed := false
+		for c := i; c < i+3; c++ {
+			d := absDiff(a[c], b[c])
+			if d > m.MaxDiff {
+				m.MaxDiff = d
+			}
+			if d > threshold {
+				changed = true
+			}
+			sum += float64(d * d)
+			abs += float64(d)
+		}
+		if changed {
+			m.DiffPixels++
+		}
+	}
+	m.PSNR = psnr(sum / float64(len(a)))
+	m.MAE = abs / float64(len(a))
+
+	ya, yb := luma(a), luma(b)
+	m.SSIM, _ = ssim(ya, yb, w, h)
+	m.MSSSIM = msssim(ya, yb, w, h)
+	return m
+}
+
+// absDiff returns |a-b|.
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// psnr converts a mean squared error of 8-bit samples to decibels.
+func psnr(mse float64) float64 {
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
+
+// luma converts packed RGB to Rec. 601 luma.
+func luma(rgb []uint8) []float64 {
+	y := make([]float64, len(rgb)/3)
+	for i := range y {
+		y[i] = 0.299*float64(rgb[i*3]) + 0.587*float64(rgb[i*3+1]) + 0.114*float64(rgb[i*3+2])
+	}
+	return y
+}
+
+// ssim averages the structural similarity of a and b over square windows,
+// and returns the contrast-structure part alone for MS-SSIM. Images
+// smaller than a window are compared as a single window.
+func ssim(a, b []float64, w, h int) (float64, float64) {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	size := min(ssimWindow, w, h)
+	if size == 0 {
+		return 1, 1
+	}
+
+	var total, totalCS float64
+	var windows int
+	for y0 := 0; y0+size <= h; y0 += ssimStride {
+		for x0 := 0; x0+size <= w; x0 += ssimStride {
+			var sa, sb, saa, sbb, sab float64
+			for y := y0; y < y0+size; y++ {
+				for x := x0; x < x0+size; x++ {
+					va, vb := a[y*w+x], b[y*w+x]
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+				}
+			}
+			n := float64(size * size)
+			ma, mb := sa/n, sb/n
+			va := saa/n - ma*ma
+			vb := sbb/n - mb*mb
+			cov := sab/n - ma*mb
+			cs := (2*cov + c2) / (va + vb + c2)
+			total += (2*ma*mb + c1) / (ma*ma + mb*mb + c1) * cs
+			totalCS += cs
+			windows++
+		}
+	}
+	return total / float64(windows), totalCS / float64(windows)
+}
+
+// msssim combines SSIM over successively halved images. Scales too small
+// for a window are dropped and the remaining weights renormalised.
+func msssim(a, b []float64, w, h int) float64 {
+	var css []float64 // Contrast-structure of each scale
+	var coarsest float64
+	for scale := range msssimWeights {
+		s, cs := ssim(a, b, w, h)
+		css = append(css, cs)
+		coarsest = s
+		if scale == len(msssimWeights)-1 || w/2 < ssimWindow || h/2 < ssimWindow {
+			break
+		}
+		a, b, w, h = halve(a, w, h), halve(b, w, h), w/2, h/2
+	}
+
+	weights := msssimWeights[:len(css)]
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+
+	// The coarsest scale also counts luminance; negative terms mean no
+	// similarity at that scale
+	css[len(css)-1] = coarsest
+	result := 1.0
+	for i, v := range css {
+		result *= math.Pow(math.Max(v, 0), weights[i]/total)
+	}
+	return result
+}
+
+// halve averages 2×2 blocks of a w×h plane, dropping an odd last row or
+// column.
+func halve(p []float64, w, h int) []float64 {
+	hw, hh := w/2, h/2
+	out := make([]float64, hw*hh)
+	for y := 0; y < hh; y++ {
+		for x := 0; x < hw; x++ {
+			i := 2*y*w + 2*x
+			out[y*hw+x] = (p[i] + p[i+1] + p[i+w] + p[i+w+1]) / 4
+		}
+	}
+	return out
+}
+
+// writeDiffImage writes a PNG of a faded to light grey, with pixels that
+// differ from b by more than threshold in red, brighter for larger
+// differences.
+func writeDiffImage(path string, a, b []uint8, w, h, threshold int) error {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		d := 0
+		for c := i * 3; c < i*3+3; c++ {
+			if cd := absDiff(a[c], b[c]); cd > d {
+				d = cd
+			}
+		}
+
+		px := img.Pix[i*4 : i*4+4]
+		px[3] = 255
+		if d > threshold {
+			px[0] = uint8(128 + d*127/255)
+			continue
+		}
+		l := 0.299*float64(a[i*3]) + 0.587*float64(a[i*3+1]) + 0.114*float64(a[i*3+2])
+		grey := uint8(255 - (255-l)/3)
+		px[0], px[1], px[2] = grey, grey, grey
+	}
+
+	temp := newTempOutput(path)
+	file, err := os.Create(temp)
+	if err != nil {
+		discardTemp(temp)
+		return err
+	}
+	err = png.Encode(file, img)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		discardTemp(temp)
+		return err
+	}
+	_, err = commitOutput(temp, path, CollisionOverwrite, "")
+	return err
+}
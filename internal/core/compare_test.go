@@ -0,0 +1,144 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePNG writes a w×h PNG filled by fill.
+func writePNG(t *testing.T, path string, w, h int, fill func(x, y int) color.Color) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill(x, y))
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	const w, h = 16, 16
+	a := make([]uint8, w*h*3)
+	for i := range a {
+		a[i] = uint8(i * 7)
+	}
+
+	same := measure(a, a, w, h, 0)
+	if !same.Identical() || !math.IsInf(same.PSNR, 1) || math.Abs(same.SSIM-1) > 1e-9 ||
+		math.Abs(same.MSSSIM-1) > 1e-9 || same.MAE != 0 || same.DiffPixels != 0 {
+		t.Errorf("identical images = %+v; want PSNR +Inf, SSIM and MS-SSIM 1, no differences", same)
+	}
+
+	// Shifting every sample by 10 gives an MSE of 100
+	b := make([]uint8, len(a))
+	for i := range a {
+		b[i] = a[i]
+		if b[i] < 200 {
+			b[i] += 10
+		} else {
+			b[i] -= 10
+		}
+	}
+	diff := measure(a, b, w, h, 0)
+	if diff.MaxDiff != 10 || diff.MAE != 10 || diff.DiffPixels != w*h || diff.DiffPercent() != 100 {
+		t.Errorf("measure = %+v; want MaxDiff 10, MAE 10 and every pixel changed", diff)
+	}
+	if want := 10 * math.Log10(255*255/100.0); math.Abs(diff.PSNR-want) > 1e-9 {
+		t.Errorf("PSNR = %.3f; want %.3f", diff.PSNR, want)
+	}
+	if diff.SSIM >= 1 || diff.SSIM <= 0 || diff.MSSSIM >= 1 || diff.MSSSIM <= 0 {
+		t.Errorf("SSIM = %.3f, MS-SSIM = %.3f; want between 0 and 1", diff.SSIM, diff.MSSSIM)
+	}
+
+	// Differences within the threshold are not counted as changed
+	if within := measure(a, b, w, h, 10); within.DiffPixels != 0 {
+		t.Errorf("DiffPixels with threshold 10 = %d; want 0", within.DiffPixels)
+	}
+
+	// Images smaller than a window still get a score
+	if tiny := measure(a[:12], a[:12], 2, 2, 0); math.Abs(tiny.SSIM-1) > 1e-9 || math.Abs(tiny.MSSSIM-1) > 1e-9 {
+		t.Errorf("2x2 SSIM = %.3f, MS-SSIM = %.3f; want 1", tiny.SSIM, tiny.MSSSIM)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	dir := t.TempDir()
+	before := filepath.Join(dir, "before.png")
+	after := filepath.Join(dir, "after.png")
+	writePNG(t, before, 8, 8, func(x, y int) color.Color { return color.NRGBA{uint8(x * 30), uint8(y * 30), 0, 255} })
+	writePNG(t, after, 8, 8, func(x, y int) color.Color { return color.NRGBA{uint8(x * 30), uint8(y*30) + 5, 0, 255} })
+
+	// The fake decodes by copying the input, minus its frame index
+	fakeMagick(t, `for last; do :; done
+case "$1" in
+-ping) echo "8 8" ;;
+*) cp "${1%\[0\]}" "$last" ;;
+esac
+`)
+
+	diffPath := filepath.Join(dir, "diff.png")
+	m, err := Compare(context.Background(), CompareOptions{Original: before, Processed: after, DiffPath: diffPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 8 || m.Height != 8 || m.MaxDiff != 5 || m.DiffPixels != 64 || m.SSIM >= 1 {
+		t.Errorf("Compare = %+v; want 8x8 with MaxDiff 5 in every pixel", m)
+	}
+
+	// Every pixel changed, so the diff image is all red
+	in, err := os.Open(diffPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	diff, err := png.Decode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := diff.At(3, 3).RGBA(); r>>8 < 128 || g != 0 || b != 0 {
+		t.Errorf("diff pixel = %d,%d,%d; want red", r>>8, g>>8, b>>8)
+	}
+
+	// Within the threshold the diff image shows the faded original
+	if _, err := Compare(context.Background(), CompareOptions{Original: before, Processed: after, Threshold: 5, DiffPath: diffPath}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(diffPath); err != nil {
+		t.Fatal(err)
+	} else if faded, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	} else if r, g, b, _ := faded.At(3, 3).RGBA(); r != g || g != b {
+		t.Errorf("unchanged pixel = %d,%d,%d; want grey", r>>8, g>>8, b>>8)
+	}
+	if names := dirNames(t, dir); len(names) != 3 {
+		t.Errorf("files after writing the diff twice = %v; want no temps left", names)
+	}
+
+	if _, err := Compare(context.Background(), CompareOptions{Original: before, Processed: filepath.Join(dir, "missing.png")}); err == nil {
+		t.Error("expected an error when the output cannot be read")
+	}
+}
+
+func TestCompareSize(t *testing.T) {
+	if w, h := compareSize(800, 600); w != 800 || h != 600 {
+		t.Errorf("compareSize(800, 600) = %dx%d; want unchanged", w, h)
+	}
+	if w, h := compareSize(8192, 4096); w != maxCompareSide || h != maxCompareSide/2 {
+		t.Errorf("compareSize(8192, 4096) = %dx%d; want %dx%d", w, h, maxCompareSide, maxCompareSide/2)
+	}
+}
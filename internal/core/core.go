@@ -3,11 +3,17 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+
+	"imagetool/internal/deps"
 )
 
 // ImageFormat represents a supported image format.
@@ -35,6 +41,32 @@ var SupportedPDFOutputFormats = []ImageFormat{
 	FormatPNG, FormatJPG, FormatJPEG, FormatBMP, FormatTIFF, FormatGIF,
 }
 
+// ResourceLimits are passed to ImageMagick as -limit options. Sizes use
+// ImageMagick units (e.g. "256MiB"); Time is in seconds. Empty values keep
+// ImageMagick's defaults.
+type ResourceLimits struct {
+	Memory string
+	Map    string
+	Disk   string
+	Time   string
+}
+
+// args returns the -limit arguments, which must precede the input file.
+func (l ResourceLimits) args() []string {
+	var args []string
+	for _, lim := range []struct{ name, value string }{
+		{"memory", l.Memory},
+		{"map", l.Map},
+		{"disk", l.Disk},
+		{"time", l.Time},
+	} {
+		if lim.value != "" {
+			args = append(args, "-limit", lim.name, lim.value)
+		}
+	}
+	return args
+}
+
 // Result represents the outcome of a processing operation.
 type Result struct {
 	Success     bool
@@ -42,6 +74,10 @@ type Result struct {
 	OutputPath  string
 	OutputPaths []string
 	OutputSize  int64
+	Skipped     bool    // The output existed and the collision policy skipped it
+	UpToDate    bool    // An earlier run's output is still valid, so nothing was done
+	Quality     int     // Encoder quality chosen by CompressMethodQuality
+	Score       float64 // SSIM reached by CompressMethodQuality
 	Error       error
 }
 
@@ -50,50 +86,99 @@ type ConvertImageOptions struct {
 	InputPath    string
 	OutputFormat ImageFormat
 	OutputPath   string // Optional, will be auto-generated if empty
+	NameTemplate string // Output name next to the input when OutputPath is empty
+	Collision    CollisionPolicy
+	Limits       ResourceLimits
+	Timeout      TimeoutPolicy
 }
 
 // ConvertImage converts an image to a different format using ImageMagick.
 func ConvertImage(opts ConvertImageOptions) Result {
+	return ConvertImageContext(context.Background(), opts)
+}
+
+// ConvertImageContext is ConvertImage with a context that can cancel the
+// conversion. The options' timeout policy further limits it.
+func ConvertImageContext(ctx context.Context, opts ConvertImageOptions) Result {
+	ctx, cancel := withTimeout(ctx, opts.Timeout, opts.InputPath)
+	defer cancel()
+
+	if opts.OutputPath == "" && opts.NameTemplate != "" {
+		outputPath, err := outputFromTemplate(ctx, filepath.Dir(opts.InputPath), opts.NameTemplate,
+			opts.InputPath, string(opts.OutputFormat), ConvertPreset(opts.OutputFormat))
+		if err != nil {
+			return templateFailedResult(err)
+		}
+		opts.OutputPath = outputPath
+	}
 	if opts.OutputPath == "" {
 		opts.OutputPath = generateOutputPath(opts.InputPath, "_conv", string(opts.OutputFormat))
 	}
 
-	cmd := exec.Command("magick", opts.InputPath, opts.OutputPath)
-	output, err := cmd.CombinedOutput()
+	outputPath, skip, err := resolveOutput(opts.OutputPath, opts.Collision)
 	if err != nil {
-		return Result{
-			Success: false,
-			Message: fmt.Sprintf("Conversion failed: %v", err),
-			Error:   fmt.Errorf("%v: %s", err, string(output)),
-		}
+		return collisionFailedResult(err)
+	}
+	if skip {
+		return skippedResult(outputPath)
 	}
+	defer releaseOutput(outputPath)
+	if err := CheckNotInput(opts.InputPath, outputPath); err != nil {
+		return collisionFailedResult(err)
+	}
+	opts.OutputPath = outputPath
 
-	info, err := os.Stat(opts.OutputPath)
-	var size int64
-	if err == nil {
-		size = info.Size()
+	temp := newTempOutput(opts.OutputPath)
+	cmd := magickCommand(ctx, opts.Limits, opts.InputPath, temp)
+	output, err := runCommand(ctx, cmd)
+	if err != nil {
+		discardTemp(temp)
+		return failedResult("Conversion", cmd, err, output)
 	}
 
-	return Result{
+	outputs, err := commitOutput(temp, opts.OutputPath, opts.Collision, opts.InputPath)
+	if errors.Is(err, errFramesExist) {
+		return skippedResult(outputs[0])
+	}
+	if err != nil {
+		return saveFailedResult(err)
+	}
+
+	result := Result{
 		Success:    true,
 		Message:    "Image converted successfully",
-		OutputPath: opts.OutputPath,
-		OutputSize: size,
+		OutputPath: outputs[0],
+		OutputSize: totalSize(outputs),
+	}
+	if len(outputs) > 1 {
+		result.OutputPaths = outputs
+		result.Message = fmt.Sprintf("Image converted successfully to %d frames", len(outputs))
 	}
+	return result
 }
 
 // ConvertPDFOptions contains options for PDF to image conversion.
 type ConvertPDFOptions struct {
 	InputPath    string
 	OutputFormat ImageFormat
-	OutputDir    string // Directory for output images
-	Density      int    // DPI (72-600)
-	Quality      int    // Output quality (1-100)
-	Prefix       string // Filename prefix for output images
+	OutputDir    string          // Directory for output images
+	Density      int             // DPI (72-600)
+	Quality      int             // Output quality (1-100)
+	Prefix       string          // Deprecated: use NameTemplate "<prefix>{page}"
+	NameTemplate string          // Output name for each page; "-{page}" is appended if missing
+	Collision    CollisionPolicy // Applied to each page
+	Limits       ResourceLimits
+	Timeout      TimeoutPolicy
 }
 
 // ConvertPDFToImages converts a PDF to images using ImageMagick.
 func ConvertPDFToImages(opts ConvertPDFOptions) Result {
+	return ConvertPDFToImagesContext(context.Background(), opts)
+}
+
+// ConvertPDFToImagesContext is ConvertPDFToImages with a context that can
+// cancel the conversion. The options' timeout policy further limits it.
+func ConvertPDFToImagesContext(ctx context.Context, opts ConvertPDFOptions) Result {
 	// Set defaults
 	if opts.Density < 72 {
 		opts.Density = 180
@@ -104,8 +189,15 @@ func ConvertPDFToImages(opts ConvertPDFOptions) Result {
 	if opts.Quality < 1 || opts.Quality > 100 {
 		opts.Quality = 90
 	}
-	if opts.Prefix == "" {
-		opts.Prefix = "Page-"
+	if opts.NameTemplate == "" {
+		if opts.Prefix == "" {
+			opts.Prefix = "Page-"
+		}
+		opts.NameTemplate = opts.Prefix + "{" + TokenPage + "}"
+	}
+	opts.NameTemplate = PageTemplate(opts.NameTemplate)
+	if err := ValidateTemplate(opts.NameTemplate); err != nil {
+		return templateFailedResult(err)
 	}
 	if opts.OutputDir == "" {
 		base := strings.TrimSuffix(filepath.Base(opts.InputPath), filepath.Ext(opts.InputPath))
@@ -121,35 +213,201 @@ func ConvertPDFToImages(opts ConvertPDFOptions) Result {
 		}
 	}
 
-	// Build output pattern
-	outputPattern := filepath.Join(opts.OutputDir, opts.Prefix+"%d."+string(opts.OutputFormat))
+	// Render into a hidden scratch folder, then rename each page to its
+	// template name; per-page tokens like {width} are only known afterwards
+	tempDir, err := newTempDir(opts.OutputDir)
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create output directory: %v", err),
+			Error:   err,
+		}
+	}
+	defer discardTemp(tempDir)
+
+	format := string(opts.OutputFormat)
+	outputPattern := filepath.Join(tempDir, "page-%d."+format)
 
 	// Run ImageMagick
-	cmd := exec.Command("magick",
+	ctx, cancel := withTimeout(ctx, opts.Timeout, opts.InputPath)
+	defer cancel()
+
+	cmd := magickCommand(ctx, opts.Limits,
 		"-density", fmt.Sprintf("%d", opts.Density),
 		opts.InputPath,
 		"-quality", fmt.Sprintf("%d", opts.Quality),
 		outputPattern,
 	)
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
+	if err != nil {
+		return failedResult("Conversion", cmd, err, output)
+	}
+
+	outputs, skipped, err := renamePages(ctx, tempDir, opts.OutputDir, pageNaming{
+		template:  opts.NameTemplate,
+		inputPath: opts.InputPath,
+		format:    format,
+		preset:    PDFPreset(opts.Density, opts.Quality),
+		collision: opts.Collision,
+	})
+	if errors.Is(err, ErrOutputExists) {
+		return collisionFailedResult(err)
+	}
 	if err != nil {
 		return Result{
 			Success: false,
-			Message: fmt.Sprintf("Conversion failed: %v", err),
-			Error:   fmt.Errorf("%v: %s", err, string(output)),
+			Message: fmt.Sprintf("Failed to name output pages: %v", err),
+			Error:   err,
 		}
 	}
 
-	// Count output files
-	pattern := filepath.Join(opts.OutputDir, opts.Prefix+"*."+string(opts.OutputFormat))
-	matches, _ := filepath.Glob(pattern)
-
+	msg := fmt.Sprintf("Successfully converted %d page(s)", len(outputs))
+	if skipped > 0 {
+		msg += fmt.Sprintf(", skipped %d existing", skipped)
+	}
 	return Result{
 		Success:     true,
-		Message:     fmt.Sprintf("Successfully converted %d page(s)", len(matches)),
+		Message:     msg,
 		OutputPath:  opts.OutputDir,
-		OutputPaths: matches,
+		OutputPaths: outputs,
+		Skipped:     len(outputs) == 0 && skipped > 0,
+	}
+}
+
+// pageNaming describes how renamePages names PDF pages.
+type pageNaming struct {
+	template  string
+	inputPath string
+	format    string
+	preset    string
+	collision CollisionPolicy
+}
+
+// renamePages moves the page-<n> files ImageMagick wrote to tempDir into
+// outputDir under their template names, in page order. All names are
+// resolved against the collision policy before any page is moved, so a
+// failing policy leaves the output folder untouched.
+func renamePages(ctx context.Context, tempDir, outputDir string, naming pageNaming) (outputs []string, skipped int, err error) {
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	format := naming.format
+
+	pages := make(map[int]string)
+	var numbers []int
+	for _, e := range entries {
+		var n int
+		if _, err := fmt.Sscanf(e.Name(), "page-%d."+format, &n); err != nil {
+			continue
+		}
+		pages[n] = filepath.Join(tempDir, e.Name())
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	tmpl := naming.template
+	dims := TemplateUses(tmpl, TokenWidth) || TemplateUses(tmpl, TokenHeight)
+	data := NewTemplateData(naming.inputPath, format, naming.preset)
+	targets := make(map[int]string, len(numbers))
+	for _, n := range numbers {
+		data.Page = n
+		if dims {
+			if data.Width, data.Height, err = imageDimensions(ctx, pages[n]); err != nil {
+				return nil, 0, err
+			}
+		}
+		outputPath, err := TemplateOutputPath(outputDir, tmpl, data)
+		if err != nil {
+			return nil, 0, err
+		}
+		outputPath, skip, err := resolveOutput(outputPath, naming.collision)
+		if err != nil {
+			return nil, 0, err
+		}
+		if skip {
+			skipped++
+			continue
+		}
+		defer releaseOutput(outputPath)
+		if err := CheckNotInput(naming.inputPath, outputPath); err != nil {
+			return nil, 0, err
+		}
+		targets[n] = outputPath
+	}
+
+	outputs = make([]string, 0, len(targets))
+	for _, n := range numbers {
+		target, ok := targets[n]
+		if !ok {
+			continue
+		}
+		if err := os.Rename(pages[n], target); err != nil {
+			return outputs, skipped, err
+		}
+		outputs = append(outputs, target)
+	}
+	return outputs, skipped, nil
+}
+
+// PageTemplate returns tmpl with "-{page}" appended when it has no {page},
+// so every PDF page gets a distinct name.
+func PageTemplate(tmpl string) string {
+	if TemplateUses(tmpl, TokenPage) {
+		return tmpl
+	}
+	return tmpl + "-{" + TokenPage + "}"
+}
+
+// ConvertPreset is the {preset} value for an image conversion.
+func ConvertPreset(format ImageFormat) string {
+	return string(format)
+}
+
+// PDFPreset is the {preset} value for a PDF conversion, e.g. "180dpi-q90".
+func PDFPreset(density, quality int) string {
+	return fmt.Sprintf("%ddpi-q%d", density, quality)
+}
+
+// CompressPreset is the {preset} value for a compression, e.g. "75pct",
+// "500KB" or "ssim95".
+func CompressPreset(method CompressMethod, percent int, targetBytes int64, minSSIM float64) string {
+	switch method {
+	case CompressMethodPercent:
+		return fmt.Sprintf("%dpct", percent)
+	case CompressMethodQuality:
+		return QualityPreset(minSSIM)
+	}
+	return fmt.Sprintf("%dKB", targetBytes/1024)
+}
+
+// saveFailedResult reports an output that couldn't be moved into place.
+func saveFailedResult(err error) Result {
+	return Result{
+		Success: false,
+		Message: fmt.Sprintf("Failed to save output: %v", err),
+		Error:   err,
+	}
+}
+
+// totalSize returns the combined size of files, skipping unreadable ones.
+func totalSize(paths []string) int64 {
+	var size int64
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// templateFailedResult reports a name template that can't be expanded.
+func templateFailedResult(err error) Result {
+	return Result{
+		Success: false,
+		Message: fmt.Sprintf("Invalid name template: %v", err),
+		Error:   err,
 	}
 }
 
@@ -161,19 +419,34 @@ const (
 	CompressMethodPercent CompressMethod = iota
 	// CompressMethodFixedSize compresses to a specific file size.
 	CompressMethodFixedSize
+	// CompressMethodQuality finds the smallest file that stays above a
+	// minimum SSIM.
+	CompressMethodQuality
 )
 
 // CompressOptions contains options for file compression.
 type CompressOptions struct {
 	InputPath     string
 	Method        CompressMethod
-	TargetPercent int   // For CompressMethodPercent (1-100)
-	TargetBytes   int64 // For CompressMethodFixedSize
+	TargetPercent int           // For CompressMethodPercent (1-100)
+	TargetBytes   int64         // For CompressMethodFixedSize
+	MinSSIM       float64       // For CompressMethodQuality (0-1)
+	Formats       []ImageFormat // For CompressMethodQuality; empty means JPG
 	OutputPath    string
+	NameTemplate  string // Output name next to the input when OutputPath is empty
+	Collision     CollisionPolicy
+	Limits        ResourceLimits
+	Timeout       TimeoutPolicy
 }
 
 // CompressFile compresses an image or PDF using ImageMagick.
 func CompressFile(opts CompressOptions) Result {
+	return CompressFileContext(context.Background(), opts)
+}
+
+// CompressFileContext is CompressFile with a context that can cancel the
+// compression. The options' timeout policy further limits it.
+func CompressFileContext(ctx context.Context, opts CompressOptions) Result {
 	// Get input file size
 	inputInfo, err := os.Stat(opts.InputPath)
 	if err != nil {
@@ -199,16 +472,44 @@ func CompressFile(opts CompressOptions) Result {
 		targetBytes = opts.TargetBytes
 	}
 
+	// The quality search runs many encodes, so it limits each of them
+	// instead of the whole job
+	searchCtx := ctx
+	ctx, cancel := withTimeout(ctx, opts.Timeout, opts.InputPath)
+	defer cancel()
+
 	// Generate output path if not provided
 	if opts.OutputPath == "" {
-		ext := filepath.Ext(opts.InputPath)
-		// For images, output as JPG for better compression
-		if strings.ToLower(ext) != ".pdf" {
-			ext = ".jpg"
+		ext := CompressOutputFormat(opts.InputPath)
+		if opts.NameTemplate != "" {
+			outputPath, err := outputFromTemplate(ctx, filepath.Dir(opts.InputPath), opts.NameTemplate,
+				opts.InputPath, ext, CompressPreset(opts.Method, opts.TargetPercent, opts.TargetBytes, opts.MinSSIM))
+			if err != nil {
+				return templateFailedResult(err)
+			}
+			opts.OutputPath = outputPath
+		} else {
+			opts.OutputPath = generateOutputPath(opts.InputPath, "_comp", ext)
 		}
-		opts.OutputPath = generateOutputPath(opts.InputPath, "_comp", ext[1:])
 	}
 
+	if opts.Method == CompressMethodQuality {
+		return compressToQuality(searchCtx, opts, inputSize)
+	}
+
+	outputPath, skip, err := resolveOutput(opts.OutputPath, opts.Collision)
+	if err != nil {
+		return collisionFailedResult(err)
+	}
+	if skip {
+		return skippedResult(outputPath)
+	}
+	defer releaseOutput(outputPath)
+	if err := CheckNotInput(opts.InputPath, outputPath); err != nil {
+		return collisionFailedResult(err)
+	}
+	opts.OutputPath = outputPath
+
 	// Build ImageMagick arguments
 	ext := strings.ToLower(filepath.Ext(opts.OutputPath))
 	args := []string{opts.InputPath}
@@ -218,24 +519,24 @@ func CompressFile(opts CompressOptions) Result {
 		targetSize := fmt.Sprintf("%d", targetBytes)
 		args = append(args, "-define", fmt.Sprintf("jpeg:extent=%s", targetSize))
 	}
-	args = append(args, opts.OutputPath)
+	temp := newTempOutput(opts.OutputPath)
+	args = append(args, temp)
 
-	cmd := exec.Command("magick", args...)
-	output, err := cmd.CombinedOutput()
+	cmd := magickCommand(ctx, opts.Limits, args...)
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
-		return Result{
-			Success: false,
-			Message: fmt.Sprintf("Compression failed: %v", err),
-			Error:   fmt.Errorf("%v: %s", err, string(output)),
-		}
+		discardTemp(temp)
+		return failedResult("Compression", cmd, err, output)
 	}
 
-	// Get output file size
-	outputInfo, err := os.Stat(opts.OutputPath)
-	var outputSize int64
-	if err == nil {
-		outputSize = outputInfo.Size()
+	outputs, err := commitOutput(temp, opts.OutputPath, opts.Collision, opts.InputPath)
+	if errors.Is(err, errFramesExist) {
+		return skippedResult(outputs[0])
+	}
+	if err != nil {
+		return saveFailedResult(err)
 	}
+	outputSize := totalSize(outputs)
 
 	// Calculate reduction
 	reduction := 0.0
@@ -256,46 +557,286 @@ func CompressFile(opts CompressOptions) Result {
 	}
 }
 
+// CompressOutputFormat returns the extension (without the dot) a
+// compressed file gets: PDFs stay PDFs, images become JPG for better
+// compression.
+func CompressOutputFormat(inputPath string) string {
+	if strings.ToLower(filepath.Ext(inputPath)) == ".pdf" {
+		return "pdf"
+	}
+	return "jpg"
+}
+
 // BatchResult contains results for batch operations.
 type BatchResult struct {
 	TotalFiles      int
 	SuccessCount    int
 	FailCount       int
+	SkippedCount    int // Outputs that existed under CollisionSkip
+	UpToDateCount   int // Inputs an earlier run already finished
 	Results         []Result
 	TotalInputSize  int64
 	TotalOutputSize int64
 }
 
+// BatchConvertOptions configures BatchConvert.
+type BatchConvertOptions struct {
+	OutputFormat ImageFormat
+	// InputRoot and OutputRoot mirror the input tree: an input at
+	// InputRoot/a/b.png is written to OutputRoot/a/b.<format>. If
+	// OutputRoot is empty, outputs go next to their inputs.
+	InputRoot  string
+	OutputRoot string
+	// NameTemplate names each output. When it is empty, outputs under
+	// OutputRoot keep the input's name and outputs next to their inputs
+	// get the "_conv" suffix.
+	NameTemplate string
+	Collision    CollisionPolicy
+	Limits       ResourceLimits
+	Timeout      TimeoutPolicy
+	// Progress, if set, is called after each file with the number done.
+	Progress func(done, total int) `json:"-"`
+	// Manifest, if set, records each file so an interrupted run can be
+	// resumed, and skips files it records as finished.
+	Manifest *Manifest `json:"-"`
+	// Cache, if set, skips inputs whose outputs from an earlier run with
+	// the same options are still in place, and records the new outputs.
+	Cache *Cache `json:"-"`
+}
+
+// cacheOptions hashes the options that decide the outputs, for the
+// incremental cache. Limits and timeouts don't change an output.
+func (o BatchConvertOptions) cacheOptions() string {
+	o.Limits, o.Timeout = ResourceLimits{}, TimeoutPolicy{}
+	return hashOptions(BatchOpConvert, o)
+}
+
 // BatchConvertImages converts multiple images to a different format.
 func BatchConvertImages(inputPaths []string, outputFormat ImageFormat) BatchResult {
+	return BatchConvert(context.Background(), inputPaths, BatchConvertOptions{OutputFormat: outputFormat})
+}
+
+// BatchConvert converts multiple images, stopping early if ctx is cancelled.
+func BatchConvert(ctx context.Context, inputPaths []string, opts BatchConvertOptions) BatchResult {
 	batch := BatchResult{
 		TotalFiles: len(inputPaths),
 		Results:    make([]Result, 0, len(inputPaths)),
 	}
+	options := opts.cacheOptions()
 
-	for _, inputPath := range inputPaths {
-		result := ConvertImage(ConvertImageOptions{
+	for i, inputPath := range inputPaths {
+		if ctx.Err() != nil {
+			break
+		}
+		if result, ok := opts.Manifest.completed(inputPath); ok {
+			batch.add(inputPath, result)
+			reportProgress(opts.Progress, i+1, len(inputPaths))
+			continue
+		}
+		slot, result, ok := opts.Cache.lookup(options, inputPath)
+		if ok {
+			batch.add(inputPath, result)
+			opts.Manifest.record(inputPath, result)
+			reportProgress(opts.Progress, i+1, len(inputPaths))
+			continue
+		}
+
+		convOpts := ConvertImageOptions{
 			InputPath:    inputPath,
-			OutputFormat: outputFormat,
-		})
-		batch.Results = append(batch.Results, result)
+			OutputFormat: opts.OutputFormat,
+			NameTemplate: opts.NameTemplate,
+			Collision:    opts.Collision,
+			Limits:       opts.Limits,
+			Timeout:      opts.Timeout,
+		}
+		if opts.OutputRoot != "" {
+			outputPath, err := mirrorOutputPath(opts.InputRoot, opts.OutputRoot, inputPath, string(opts.OutputFormat))
+			if err == nil {
+				err = os.MkdirAll(filepath.Dir(outputPath), 0755)
+			}
+			if err == nil && opts.NameTemplate != "" {
+				outputPath, err = outputFromTemplate(ctx, filepath.Dir(outputPath), opts.NameTemplate,
+					inputPath, string(opts.OutputFormat), ConvertPreset(opts.OutputFormat))
+			}
+			if err != nil {
+				result := Result{
+					Success: false,
+					Message: fmt.Sprintf("Failed to prepare output folder: %v", err),
+					Error:   err,
+				}
+				batch.Results = append(batch.Results, result)
+				batch.FailCount++
+				opts.Manifest.record(inputPath, result)
+				reportProgress(opts.Progress, i+1, len(inputPaths))
+				continue
+			}
+			convOpts.OutputPath = outputPath
+		}
 
-		if result.Success {
-			batch.SuccessCount++
-			batch.TotalOutputSize += result.OutputSize
-		} else {
-			batch.FailCount++
+		result = ConvertImageContext(ctx, convOpts)
+		batch.add(inputPath, result)
+		slot.store(result)
+		if ctx.Err() == nil {
+			// A cancelled file is left for the resumed run
+			opts.Manifest.record(inputPath, result)
+		}
+		reportProgress(opts.Progress, i+1, len(inputPaths))
+	}
+
+	return batch
+}
+
+// reportProgress calls progress if it is set.
+func reportProgress(progress func(done, total int), done, total int) {
+	if progress != nil {
+		progress(done, total)
+	}
+}
+
+// add records the result of one file.
+func (b *BatchResult) add(inputPath string, result Result) {
+	b.Results = append(b.Results, result)
+
+	if result.UpToDate {
+		b.UpToDateCount++
+	} else if result.Skipped {
+		b.SkippedCount++
+	} else if result.Success {
+		b.SuccessCount++
+		b.TotalOutputSize += result.OutputSize
+	} else {
+		b.FailCount++
+	}
+
+	// Track input size
+	if info, err := os.Stat(inputPath); err == nil {
+		b.TotalInputSize += info.Size()
+	}
+}
+
+// BatchCompressOptions configures BatchCompress. Outputs go next to their
+// inputs.
+type BatchCompressOptions struct {
+	Method        CompressMethod
+	TargetPercent int
+	TargetBytes   int64
+	MinSSIM       float64
+	Formats       []ImageFormat
+	// NameTemplate names each output; empty uses the "_comp" suffix.
+	NameTemplate string
+	Collision    CollisionPolicy
+	Limits       ResourceLimits
+	Timeout      TimeoutPolicy
+	// Progress, if set, is called after each file with the number done.
+	Progress func(done, total int) `json:"-"`
+	// Manifest, if set, records each file so an interrupted run can be
+	// resumed, and skips files it records as finished.
+	Manifest *Manifest `json:"-"`
+	// Cache, if set, skips inputs whose outputs from an earlier run with
+	// the same options are still in place, and records the new outputs.
+	Cache *Cache `json:"-"`
+}
+
+// cacheOptions hashes the options that decide the outputs, for the
+// incremental cache. Limits and timeouts don't change an output.
+func (o BatchCompressOptions) cacheOptions() string {
+	o.Limits, o.Timeout = ResourceLimits{}, TimeoutPolicy{}
+	return hashOptions(BatchOpCompress, o)
+}
+
+// BatchCompress compresses multiple files, stopping early if ctx is
+// cancelled.
+func BatchCompress(ctx context.Context, inputPaths []string, opts BatchCompressOptions) BatchResult {
+	batch := BatchResult{
+		TotalFiles: len(inputPaths),
+		Results:    make([]Result, 0, len(inputPaths)),
+	}
+	options := opts.cacheOptions()
+
+	for i, inputPath := range inputPaths {
+		if ctx.Err() != nil {
+			break
+		}
+		if result, ok := opts.Manifest.completed(inputPath); ok {
+			batch.add(inputPath, result)
+			reportProgress(opts.Progress, i+1, len(inputPaths))
+			continue
+		}
+		slot, result, ok := opts.Cache.lookup(options, inputPath)
+		if ok {
+			batch.add(inputPath, result)
+			opts.Manifest.record(inputPath, result)
+			reportProgress(opts.Progress, i+1, len(inputPaths))
+			continue
 		}
 
-		// Track input size
-		if info, err := os.Stat(inputPath); err == nil {
-			batch.TotalInputSize += info.Size()
+		result = CompressFileContext(ctx, CompressOptions{
+			InputPath:     inputPath,
+			Method:        opts.Method,
+			TargetPercent: opts.TargetPercent,
+			TargetBytes:   opts.TargetBytes,
+			MinSSIM:       opts.MinSSIM,
+			Formats:       opts.Formats,
+			NameTemplate:  opts.NameTemplate,
+			Collision:     opts.Collision,
+			Limits:        opts.Limits,
+			Timeout:       opts.Timeout,
+		})
+		batch.add(inputPath, result)
+		slot.store(result)
+		if ctx.Err() == nil {
+			// A cancelled file is left for the resumed run
+			opts.Manifest.record(inputPath, result)
 		}
+		reportProgress(opts.Progress, i+1, len(inputPaths))
 	}
 
 	return batch
 }
 
+// mirrorOutputPath maps an input under inputRoot to the same relative
+// location under outputRoot.
+func mirrorOutputPath(inputRoot, outputRoot, inputPath, ext string) (string, error) {
+	rel, err := filepath.Rel(inputRoot, inputPath)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside %s", inputPath, inputRoot)
+	}
+	return MirrorPath(outputRoot, WalkEntry{Rel: rel}, ext), nil
+}
+
+// magickCommand builds an ImageMagick command using the executable resolved
+// by the dependency check (magick, or convert for ImageMagick 6), with the
+// resource limits placed ahead of the other arguments. Cancelling ctx kills
+// the process and any delegates it started.
+func magickCommand(ctx context.Context, limits ResourceLimits, args ...string) *exec.Cmd {
+	args = append(limits.args(), args...)
+	cmd := exec.CommandContext(ctx, deps.GetImageMagickCommand(), args...)
+	setProcessTreeKill(cmd)
+	cmd.WaitDelay = killWaitDelay
+	cmd.Env = commandEnv(deps.GetGhostscriptCommand())
+	return cmd
+}
+
+// commandEnv returns the environment for ImageMagick child processes.
+// ImageMagick invokes Ghostscript itself, so when Ghostscript was resolved
+// to an explicit location its directory is made visible to the child only;
+// the user's own PATH is never modified.
+func commandEnv(gsPath string) []string {
+	env := os.Environ()
+	if gsPath == "" || !filepath.IsAbs(gsPath) {
+		return env
+	}
+
+	gsDir := filepath.Dir(gsPath)
+	return append(env,
+		"PATH="+gsDir+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"MAGICK_GHOSTSCRIPT_PATH="+gsDir,
+	)
+}
+
 // generateOutputPath creates an output path with suffix and extension.
 func generateOutputPath(inputPath, suffix, ext string) string {
 	dir := filepath.Dir(inputPath)
@@ -317,50 +858,56 @@ func FormatSize(bytes int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-// OpenFolder opens a folder in Windows Explorer.
+// OpenFolder opens a folder in the platform's file manager: Explorer on
+// Windows, Finder on macOS and the xdg-open handler elsewhere.
 func OpenFolder(path string) error {
-	cmd := exec.Command("explorer", path)
-	return cmd.Start()
+	opener := "xdg-open"
+	switch runtime.GOOS {
+	case "windows":
+		opener = "explorer"
+	case "darwin":
+		opener = "open"
+	}
+	cmd := exec.Command(opener, path)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	// Reap the opener so it doesn't linger as a zombie
+	go cmd.Wait()
+	return nil
 }
 
-// IsImageFile checks if a file is a supported image format.
+// IsImageFile checks if a file's extension is a supported image format.
+// Use DetectType to check the content.
 func IsImageFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".webp", ".avif"}
-	for _, e := range imageExts {
-		if ext == e {
-			return true
-		}
-	}
-	return false
+	return ExtensionType(path).IsImage()
 }
 
-// IsPDFFile checks if a file is a PDF.
+// IsPDFFile checks if a file's extension is PDF.
 func IsPDFFile(path string) bool {
-	return strings.ToLower(filepath.Ext(path)) == ".pdf"
+	return ExtensionType(path) == TypePDF
 }
 
 // GetFilesInDirectory returns files matching the filter in a directory.
+// It does not recurse; use Walk for nested folders.
 func GetFilesInDirectory(dir string, includeImages, includePDFs bool) ([]string, error) {
-	entries, err := os.ReadDir(dir)
+	if !includeImages && !includePDFs {
+		return nil, nil
+	}
+
+	entries, err := Walk(dir, WalkOptions{
+		IncludeImages: includeImages,
+		IncludePDFs:   includePDFs,
+		Hidden:        true,
+		Symlinks:      SymlinksFiles,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	var files []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		path := filepath.Join(dir, entry.Name())
-		if includeImages && IsImageFile(path) {
-			files = append(files, path)
-		}
-		if includePDFs && IsPDFFile(path) {
-			files = append(files, path)
-		}
+	files := make([]string, len(entries))
+	for i, e := range entries {
+		files[i] = e.Path
 	}
-
 	return files, nil
 }
@@ -1,8 +1,11 @@
 package core
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -143,3 +146,61 @@ func TestGetFilesInDirectory(t *testing.T) {
 		t.Errorf("Expected 3 files, got %d", len(files))
 	}
 }
+
+func TestResourceLimitsArgs(t *testing.T) {
+	if args := (ResourceLimits{}).args(); len(args) != 0 {
+		t.Errorf("empty limits args = %v; want none", args)
+	}
+
+	args := ResourceLimits{Memory: "256MiB", Time: "60"}.args()
+	want := []string{"-limit", "memory", "256MiB", "-limit", "time", "60"}
+	if len(args) != len(want) {
+		t.Fatalf("args = %v; want %v", args, want)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Errorf("args[%d] = %s; want %s", i, args[i], want[i])
+		}
+	}
+}
+
+func TestBatchCompress(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"a", "b"} {
+		input := filepath.Join(dir, name+".png")
+		if err := os.WriteFile(input, []byte("input"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input)
+	}
+	// b's output already exists and is skipped
+	existing := filepath.Join(dir, "b_comp.jpg")
+	if err := os.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeMagick(t, `for last; do :; done; printf ok > "$last"`+"\n")
+	var progress []string
+	batch := BatchCompress(context.Background(), inputs, BatchCompressOptions{
+		Method:        CompressMethodPercent,
+		TargetPercent: 50,
+		Collision:     CollisionSkip,
+		Progress: func(done, total int) {
+			progress = append(progress, fmt.Sprintf("%d/%d", done, total))
+		},
+	})
+	if strings.Join(progress, " ") != "1/2 2/2" {
+		t.Errorf("progress = %v; want 1/2 2/2", progress)
+	}
+	if batch.SuccessCount != 1 || batch.SkippedCount != 1 || batch.FailCount != 0 {
+		t.Errorf("success/skipped/fail = %d/%d/%d; want 1/1/0",
+			batch.SuccessCount, batch.SkippedCount, batch.FailCount)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a_comp.jpg")); string(data) != "ok" {
+		t.Errorf("a_comp.jpg = %q; want ok", data)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "old" {
+		t.Errorf("b_comp.jpg = %q; want it untouched", data)
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrorKind classifies why an ImageMagick or Ghostscript run failed.
+type ErrorKind int
+
+const (
+	// ErrorUnknown means the output matched no known failure.
+	ErrorUnknown ErrorKind = iota
+	// ErrorUnsupportedFormat means ImageMagick has no coder for the format.
+	ErrorUnsupportedFormat
+	// ErrorMissingDelegate means an external helper such as Ghostscript
+	// is missing or failed to run.
+	ErrorMissingDelegate
+	// ErrorPolicyDenied means policy.xml blocked the operation.
+	ErrorPolicyDenied
+	// ErrorCorruptInput means the input file could not be decoded.
+	ErrorCorruptInput
+	// ErrorPasswordProtected means the PDF needs a password.
+	ErrorPasswordProtected
+	// ErrorOutOfMemory means memory or another resource limit ran out.
+	ErrorOutOfMemory
+	// ErrorDiskFull means there was no space left to write output.
+	ErrorDiskFull
+	// ErrorTimeout means the operation ran past its time limit.
+	ErrorTimeout
+)
+
+// Sentinel errors matched by errors.Is against a *ProcessError.
+var (
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	ErrMissingDelegate   = errors.New("missing delegate")
+	ErrPolicyDenied      = errors.New("blocked by security policy")
+	ErrCorruptInput      = errors.New("corrupt input")
+	ErrPasswordProtected = errors.New("password-protected PDF")
+	ErrOutOfMemory       = errors.New("out of memory")
+	ErrDiskFull          = errors.New("disk full")
+	ErrTimeout           = errors.New("timed out")
+)
+
+// kindInfo is the sentinel, hint and stderr patterns for an ErrorKind.
+type kindInfo struct {
+	kind     ErrorKind
+	sentinel error
+	hint     string
+	patterns []string // Lower-case substrings of the command output
+}
+
+// errorKinds is checked in order; the first kind with a matching pattern
+// wins. More specific failures come before generic ones.
+var errorKinds = []kindInfo{
+	{
+		kind:     ErrorPolicyDenied,
+		sentinel: ErrPolicyDenied,
+		hint:     "ImageMagick's security policy (policy.xml) blocks this operation. Ask your administrator to allow the format, or edit policy.xml.",
+		patterns: []string{"not authorized", "notauthorized", "security policy"},
+	},
+	{
+		kind:     ErrorTimeout,
+		sentinel: ErrTimeout,
+		hint:     "The operation took too long and was stopped. Try a smaller file or a lower density, or raise the time limit in the config.",
+		patterns: []string{"time limit exceeded", "timelimitexceeded"},
+	},
+	{
+		kind:     ErrorDiskFull,
+		sentinel: ErrDiskFull,
+		hint:     "The disk is full. Free up space or choose an output folder on another drive.",
+		patterns: []string{"no space left on device", "not enough space on the disk", "disk quota exceeded", "enospc"},
+	},
+	{
+		kind:     ErrorOutOfMemory,
+		sentinel: ErrOutOfMemory,
+		hint:     "ImageMagick ran out of memory or hit a resource limit. Lower the density or image size, or raise the limits in the config.",
+		patterns: []string{"memory allocation failed", "memoryallocationfailed", "cache resources exhausted", "out of memory", "width or height exceeds limit"},
+	},
+	{
+		kind:     ErrorPasswordProtected,
+		sentinel: ErrPasswordProtected,
+		hint:     "The PDF is password-protected. Remove the password with your PDF reader and try again.",
+		patterns: []string{"requires a password", "password did not work", "invalid password", "encrypted pdf"},
+	},
+	{
+		kind:     ErrorMissingDelegate,
+		sentinel: ErrMissingDelegate,
+		hint:     "ImageMagick needs a helper program or library that is missing or failed to run (often Ghostscript). Check the dependency screen.",
+		patterns: []string{"delegate library support not built-in", "failedtoexecutecommand", "delegate failed", "gs: command not found", "gs: not found"},
+	},
+	{
+		kind:     ErrorUnsupportedFormat,
+		sentinel: ErrUnsupportedFormat,
+		hint:     "Your ImageMagick build can't handle this format. Pick a different format or install a build with support for it.",
+		patterns: []string{"no decode delegate for this image format", "no encode delegate for this image format", "unrecognized image format", "unable to open module file", "nodecodedelegate", "noencodedelegate"},
+	},
+	{
+		kind:     ErrorCorruptInput,
+		sentinel: ErrCorruptInput,
+		hint:     "The input file looks damaged or isn't the type its extension suggests. Try opening it in another program.",
+		patterns: []string{"improper image header", "corrupt image", "premature end of", "insufficient image data", "unexpected end-of-file", "not a jpeg file", "crc error", "negative or zero image size", "startxref", "couldn't find trailer", "syntaxerror"},
+	},
+}
+
+// String returns a short description of the kind.
+func (k ErrorKind) String() string {
+	for _, info := range errorKinds {
+		if info.kind == k {
+			return info.sentinel.Error()
+		}
+	}
+	return "unknown error"
+}
+
+// ProcessError is a failed external command with its classification and
+// raw output.
+type ProcessError struct {
+	Kind    ErrorKind
+	Hint    string // User-facing advice, empty for ErrorUnknown
+	Output  string // Combined stdout and stderr
+	Command string // Command line that failed, for reproduction
+	Err     error  // Underlying error, usually from exec
+}
+
+// Error implements error.
+func (e *ProcessError) Error() string {
+	if e.Kind == ErrorUnknown {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ProcessError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel for this error's kind.
+func (e *ProcessError) Is(target error) bool {
+	for _, info := range errorKinds {
+		if info.kind == e.Kind {
+			return target == info.sentinel
+		}
+	}
+	return false
+}
+
+// Summary returns the kind description, or the underlying error when the
+// kind is unknown.
+func (e *ProcessError) Summary() string {
+	if e.Kind == ErrorUnknown {
+		return e.Err.Error()
+	}
+	return e.Kind.String()
+}
+
+// ClassifyError inspects a failed command's output and returns a typed
+// error with a hint for the user.
+func ClassifyError(err error, output string) *ProcessError {
+	perr := &ProcessError{Kind: ErrorUnknown, Output: strings.TrimSpace(output), Err: err}
+	if err == nil {
+		perr.Err = errors.New("command failed")
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		perr.Kind = ErrorTimeout
+	} else {
+		lower := strings.ToLower(output)
+		for _, info := range errorKinds {
+			if containsAny(lower, info.patterns) {
+				perr.Kind = info.kind
+				break
+			}
+		}
+	}
+
+	for _, info := range errorKinds {
+		if info.kind == perr.Kind {
+			perr.Hint = info.hint
+		}
+	}
+	return perr
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// failedResult builds the Result for a failed command, e.g.
+// "Conversion failed: unsupported format".
+func failedResult(action string, cmd *exec.Cmd, err error, output []byte) Result {
+	perr := ClassifyError(err, string(output))
+	if cmd != nil {
+		perr.Command = commandLine(cmd)
+	}
+	return Result{
+		Success: false,
+		Message: fmt.Sprintf("%s failed: %s", action, perr.Summary()),
+		Error:   perr,
+	}
+}
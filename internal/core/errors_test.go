@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+
+	tests := []struct {
+		output string
+		kind   ErrorKind
+	}{
+		{"convert: attempt to perform an operation not allowed by the security policy `PDF' @ error/constitute.c/IsCoderAuthorized/408.", ErrorPolicyDenied},
+		{"magick: not authorized `doc.pdf' @ error/constitute.c/ReadImage/412.", ErrorPolicyDenied},
+		{"magick: no decode delegate for this image format `XYZ' @ error/constitute.c/ReadImage/746.", ErrorUnsupportedFormat},
+		{"magick: no encode delegate for this image format `AVIF' @ error/constitute.c/WriteImage/1413.", ErrorUnsupportedFormat},
+		{"magick: FailedToExecuteCommand `'gs' -sstdout=%stderr' (-1) @ error/ghostscript-private.h/ExecuteGhostscriptCommand/74.", ErrorMissingDelegate},
+		{"magick: delegate library support not built-in `x.heic' (LIBHEIF) @ warning/heic.c/ReadHEICImage/1213.", ErrorMissingDelegate},
+		{"magick: improper image header `broken.png' @ error/png.c/ReadPNGImage/4092.", ErrorCorruptInput},
+		{"**** Error: Cannot find a 'startxref' anywhere in the file.", ErrorCorruptInput},
+		{"   **** This file requires a password for access.", ErrorPasswordProtected},
+		{"magick: cache resources exhausted `big.tif' @ error/cache.c/OpenPixelCache/4095.", ErrorOutOfMemory},
+		{"magick: memory allocation failed `huge.png' @ error/png.c/ReadPNGImage/4100.", ErrorOutOfMemory},
+		{"magick: unable to write blob `out.png': No space left on device @ error/blob.c/WriteBlob/5690.", ErrorDiskFull},
+		{"magick: time limit exceeded `slow.pdf' @ fatal/cache.c/GetImagePixelCache/1725.", ErrorTimeout},
+		{"something else entirely", ErrorUnknown},
+	}
+
+	for _, tt := range tests {
+		perr := ClassifyError(exitErr, tt.output)
+		if perr.Kind != tt.kind {
+			t.Errorf("ClassifyError(%q).Kind = %v; want %v", tt.output, perr.Kind, tt.kind)
+		}
+		if (perr.Hint == "") != (tt.kind == ErrorUnknown) {
+			t.Errorf("ClassifyError(%q).Hint = %q; want a hint only for known kinds", tt.output, perr.Hint)
+		}
+	}
+}
+
+func TestClassifyErrorDeadline(t *testing.T) {
+	err := fmt.Errorf("run: %w", context.DeadlineExceeded)
+	if perr := ClassifyError(err, ""); perr.Kind != ErrorTimeout {
+		t.Errorf("Kind = %v; want ErrorTimeout", perr.Kind)
+	}
+}
+
+func TestProcessErrorIs(t *testing.T) {
+	var err error = ClassifyError(errors.New("exit status 1"), "no space left on device")
+
+	if !errors.Is(err, ErrDiskFull) {
+		t.Error("errors.Is(err, ErrDiskFull) = false; want true")
+	}
+	if errors.Is(err, ErrTimeout) {
+		t.Error("errors.Is(err, ErrTimeout) = true; want false")
+	}
+
+	var perr *ProcessError
+	if !errors.As(err, &perr) || perr.Output != "no space left on device" {
+		t.Errorf("errors.As failed or lost output: %+v", perr)
+	}
+
+	result := failedResult("Conversion", nil, errors.New("exit status 1"), []byte("no space left on device"))
+	if result.Message != "Conversion failed: disk full" {
+		t.Errorf("Message = %q; want %q", result.Message, "Conversion failed: disk full")
+	}
+}
@@ -0,0 +1,420 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// manifestExt is the extension of batch manifests.
+const manifestExt = ".jsonl"
+
+var manifestDir struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// SetManifestDir sets the folder batch manifests are kept in. Until it is
+// called, or after it is called with "", they go to a folder in the
+// system temp directory.
+func SetManifestDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	manifestDir.mu.Lock()
+	defer manifestDir.mu.Unlock()
+	manifestDir.dir = dir
+	return nil
+}
+
+// manifestFolder returns the manifest folder, creating the default one.
+func manifestFolder() (string, error) {
+	manifestDir.mu.Lock()
+	dir := manifestDir.dir
+	manifestDir.mu.Unlock()
+	if dir != "" {
+		return dir, nil
+	}
+	dir = filepath.Join(os.TempDir(), "imagetool-manifests")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// BatchOperation is the kind of batch a manifest records.
+type BatchOperation string
+
+const (
+	BatchOpConvert  BatchOperation = "convert"
+	BatchOpCompress BatchOperation = "compress"
+)
+
+// ManifestHeader describes a batch run: what it does and to which inputs,
+// enough to run it again. PID is the process running the batch, or 0 when
+// none is.
+type ManifestHeader struct {
+	Operation BatchOperation        `json:"operation"`
+	Title     string                `json:"title"`
+	PID       int                   `json:"pid,omitempty"`
+	InputDir  string                `json:"input_dir,omitempty"`
+	Created   time.Time             `json:"created"`
+	Inputs    []string              `json:"inputs"`
+	Convert   *BatchConvertOptions  `json:"convert,omitempty"`
+	Compress  *BatchCompressOptions `json:"compress,omitempty"`
+}
+
+// ManifestStatus is how the processing of one input ended.
+type ManifestStatus string
+
+const (
+	ManifestDone    ManifestStatus = "done"
+	ManifestSkipped ManifestStatus = "skipped" // The output existed and the collision policy kept it
+	ManifestFailed  ManifestStatus = "failed"
+)
+
+// ManifestEntry records the processing of one input.
+type ManifestEntry struct {
+	Input   string         `json:"input"`
+	Status  ManifestStatus `json:"status"`
+	Output  string         `json:"output,omitempty"`
+	Outputs []OutputHash   `json:"outputs,omitempty"` // Every file written, with its SHA-256
+	Error   string         `json:"error,omitempty"`
+}
+
+// manifestOwner is the line recorded when a process takes over a batch,
+// or with PID 0 when it stops running it.
+type manifestOwner struct {
+	PID *int `json:"pid"`
+}
+
+// Manifest records the progress of a batch run so an interrupted run can
+// be resumed. It is a JSON-lines file: the header, then one entry per
+// processed input, appended as each finishes; a later entry for an input
+// replaces an earlier one. Owner lines between them change the header's
+// PID.
+type Manifest struct {
+	mu      sync.Mutex
+	path    string
+	header  ManifestHeader
+	entries map[string]ManifestEntry
+	file    *os.File // Open for appending once the first entry is recorded
+	err     error    // First failure to write an entry
+}
+
+// CreateManifest starts a manifest for a batch in the manifest folder.
+func CreateManifest(header ManifestHeader) (*Manifest, error) {
+	dir, err := manifestFolder()
+	if err != nil {
+		return nil, err
+	}
+	if header.Created.IsZero() {
+		header.Created = time.Now()
+	}
+	header.PID = os.Getpid()
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(dir, "batch-"+header.Created.Format("20060102-150405")+"-*"+manifestExt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &Manifest{
+		path:    f.Name(),
+		header:  header,
+		entries: make(map[string]ManifestEntry),
+		file:    f,
+	}, nil
+}
+
+// OpenManifest loads a manifest. Entry lines that can't be parsed, like a
+// line cut short by a crash, are ignored.
+func OpenManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{path: path, entries: make(map[string]ManifestEntry)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("manifest %s is empty", path)
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &m.header); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if m.header.Convert == nil && m.header.Compress == nil {
+		return nil, fmt.Errorf("invalid manifest %s: no batch options", path)
+	}
+	for scanner.Scan() {
+		var e ManifestEntry
+		var owner manifestOwner
+		switch {
+		case json.Unmarshal(scanner.Bytes(), &e) == nil && e.Input != "":
+			m.entries[e.Input] = e
+		case json.Unmarshal(scanner.Bytes(), &owner) == nil && owner.PID != nil:
+			m.header.PID = *owner.PID
+		}
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		// Start the next entry on a line of its own
+		if err := m.append([]byte{'\n'}); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// PendingManifests returns the manifests of batches that were interrupted
+// or had failures, oldest first. Unreadable manifests, and those of
+// batches a running process is still working on, are skipped.
+func PendingManifests() ([]*Manifest, error) {
+	dir, err := manifestFolder()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+manifestExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var manifests []*Manifest
+	for _, path := range paths {
+		m, err := OpenManifest(path)
+		if err != nil {
+			continue
+		}
+		m.close()
+		if pid := m.header.PID; pid != 0 && processAlive(pid) {
+			continue
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+// Path returns the manifest file.
+func (m *Manifest) Path() string {
+	return m.path
+}
+
+// Header returns what the batch does.
+func (m *Manifest) Header() ManifestHeader {
+	return m.header
+}
+
+// Progress counts the inputs that are finished (done or skipped) and that
+// failed, out of all inputs.
+func (m *Manifest) Progress() (finished, failed, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, input := range m.header.Inputs {
+		switch m.entries[input].Status {
+		case ManifestDone, ManifestSkipped:
+			finished++
+		case ManifestFailed:
+			failed++
+		}
+	}
+	return finished, failed, len(m.header.Inputs)
+}
+
+// completed returns the result for an input an earlier run finished. A
+// done input only counts while each of its outputs still has the recorded
+// hash.
+func (m *Manifest) completed(input string) (Result, bool) {
+	if m == nil {
+		return Result{}, false
+	}
+	m.mu.Lock()
+	e, ok := m.entries[input]
+	m.mu.Unlock()
+
+	switch {
+	case ok && e.Status == ManifestSkipped:
+	case ok && e.Status == ManifestDone:
+		if !outputsUnchanged(e.Outputs) {
+			return Result{}, false
+		}
+	default:
+		return Result{}, false
+	}
+	result := Result{
+		Success:    true,
+		Message:    "Finished by an earlier run",
+		OutputPath: e.Output,
+		UpToDate:   true,
+	}
+	if len(e.Outputs) > 1 {
+		for _, out := range e.Outputs {
+			result.OutputPaths = append(result.OutputPaths, out.Path)
+		}
+	}
+	return result, true
+}
+
+// record appends how the processing of an input ended.
+func (m *Manifest) record(input string, result Result) {
+	if m == nil {
+		return
+	}
+	e := ManifestEntry{Input: input, Status: ManifestDone, Output: result.OutputPath}
+	switch {
+	case result.Skipped:
+		e.Status = ManifestSkipped
+	case !result.Success:
+		e.Status = ManifestFailed
+		e.Error = result.Message
+	default:
+		outputs, err := hashOutputs(result)
+		if err != nil {
+			// Without hashes the outputs can't be trusted on resume
+			e.Status = ManifestFailed
+			e.Error = err.Error()
+		}
+		e.Outputs = outputs
+	}
+	line, err := json.Marshal(e)
+	if err == nil {
+		err = m.append(append(line, '\n'))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[input] = e
+	if err != nil && m.err == nil {
+		m.err = err
+	}
+}
+
+// setOwner records pid as the process running the batch, 0 for none.
+func (m *Manifest) setOwner(pid int) error {
+	line, err := json.Marshal(manifestOwner{PID: &pid})
+	if err != nil {
+		return err
+	}
+	if err := m.append(append(line, '\n')); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.header.PID = pid
+	return nil
+}
+
+// ErrManifestInUse is returned by Claim when another running process owns
+// the manifest.
+var ErrManifestInUse = errors.New("batch is running in another process")
+
+// Claim records this process as the one running the manifest's batch, so
+// other instances leave it alone until Finish. It fails with
+// ErrManifestInUse when another process that is still running owns it.
+func (m *Manifest) Claim() error {
+	if pid := m.Header().PID; pid != 0 && pid != os.Getpid() && processAlive(pid) {
+		return fmt.Errorf("%w (pid %d)", ErrManifestInUse, pid)
+	}
+	return m.setOwner(os.Getpid())
+}
+
+// append writes to the end of the manifest file, opening it if needed.
+func (m *Manifest) append(data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		f, err := os.OpenFile(m.path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		m.file = f
+	}
+	_, err := m.file.Write(data)
+	return err
+}
+
+// close closes the manifest file.
+func (m *Manifest) close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
+
+// Finish closes the manifest after a run. When every input is finished
+// the manifest is removed; otherwise it is kept, with no owner, so the run
+// can be resumed. It returns the first error met while recording entries.
+func (m *Manifest) Finish() error {
+	if m == nil {
+		return nil
+	}
+	var err error
+	if finished, _, total := m.Progress(); finished == total {
+		err = m.close()
+		if rmErr := os.Remove(m.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+			err = rmErr
+		}
+	} else {
+		err = errors.Join(m.setOwner(0), m.close())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	return err
+}
+
+// Discard closes and removes the manifest, giving up on the run.
+func (m *Manifest) Discard() error {
+	m.close()
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ResumeBatch runs the batch a manifest records again, skipping inputs an
+// earlier run finished and retrying the rest, then finishes the manifest.
+// The manifest names this process as its owner while it runs.
+func ResumeBatch(ctx context.Context, m *Manifest, progress func(done, total int)) (BatchResult, error) {
+	if err := m.Claim(); err != nil {
+		m.close()
+		return BatchResult{}, err
+	}
+	h := m.Header()
+	var batch BatchResult
+	switch {
+	case h.Convert != nil:
+		opts := *h.Convert
+		opts.Manifest, opts.Progress = m, progress
+		batch = BatchConvert(ctx, h.Inputs, opts)
+	case h.Compress != nil:
+		opts := *h.Compress
+		opts.Manifest, opts.Progress = m, progress
+		batch = BatchCompress(ctx, h.Inputs, opts)
+	}
+	return batch, m.Finish()
+}
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runLog returns the inputs the fake magick was run on, one per line.
+func runLog(t *testing.T, log string) []string {
+	t.Helper()
+	data, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, line := range strings.Fields(string(data)) {
+		names = append(names, filepath.Base(line))
+	}
+	return names
+}
+
+func TestBatchManifestResume(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetManifestDir(filepath.Join(t.TempDir(), "manifests")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetManifestDir("") })
+
+	var inputs []string
+	for _, name := range []string{"a", "bad", "c"} {
+		input := filepath.Join(dir, name+".png")
+		if err := os.WriteFile(input, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input)
+	}
+	log := filepath.Join(t.TempDir(), "runs.log")
+	fixed := filepath.Join(t.TempDir(), "fixed")
+	// Inputs named "bad" fail until the fixed flag exists
+	fakeMagick(t, fmt.Sprintf(`for last; do :; done
+echo "$1" >> %q
+case "$1" in *bad*) [ -e %q ] || exit 1 ;; esac
+cp "$1" "$last"
+`, log, fixed))
+
+	opts := BatchConvertOptions{OutputFormat: FormatWebP}
+	manifest, err := CreateManifest(ManifestHeader{
+		Operation: BatchOpConvert,
+		Title:     "Convert test",
+		Inputs:    inputs,
+		Convert:   &opts,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first run is interrupted after one file
+	ctx, cancel := context.WithCancel(context.Background())
+	run := opts
+	run.Manifest = manifest
+	run.Progress = func(done, total int) { cancel() }
+	BatchConvert(ctx, inputs, run)
+	if err := manifest.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := PendingManifests()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("PendingManifests = %d, %v; want the interrupted run", len(pending), err)
+	}
+	if finished, failed, total := pending[0].Progress(); finished != 1 || failed != 0 || total != 3 {
+		t.Errorf("progress = %d finished, %d failed of %d; want 1, 0 of 3", finished, failed, total)
+	}
+
+	// Resuming skips a and records bad's failure
+	os.Remove(log)
+	batch, err := ResumeBatch(context.Background(), pending[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.UpToDateCount != 1 || batch.SuccessCount != 1 || batch.FailCount != 1 {
+		t.Errorf("up to date/success/fail = %d/%d/%d; want 1/1/1", batch.UpToDateCount, batch.SuccessCount, batch.FailCount)
+	}
+	if got := strings.Join(runLog(t, log), " "); got != "bad.png c.png" {
+		t.Errorf("resumed run processed %q; want bad.png c.png", got)
+	}
+
+	// A changed output is redone; the failed input is retried
+	if err := os.WriteFile(filepath.Join(dir, "c_conv.webp"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fixed, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(log)
+	manifest, err = OpenManifest(manifest.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch, err = ResumeBatch(context.Background(), manifest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(runLog(t, log), " "); got != "bad.png c.png" {
+		t.Errorf("second resume processed %q; want bad.png c.png", got)
+	}
+	if pending, _ := PendingManifests(); len(pending) != 0 {
+		t.Errorf("a finished manifest was kept: %v", pending[0].Path())
+	}
+}
+
+func TestOpenManifestIgnoresCutLine(t *testing.T) {
+	if err := SetManifestDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetManifestDir("") })
+
+	m, err := CreateManifest(ManifestHeader{
+		Operation: BatchOpCompress,
+		Inputs:    []string{"a.png", "b.png"},
+		Compress:  &BatchCompressOptions{Method: CompressMethodQuality, MinSSIM: 0.95},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.record("a.png", Result{Success: false, Message: "boom"})
+	m.close()
+
+	// A crash cut the last entry short
+	f, err := os.OpenFile(m.Path(), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"input":"b.png","sta`)
+	f.Close()
+
+	reopened, err := OpenManifest(m.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, failed, _ := reopened.Progress(); failed != 1 {
+		t.Errorf("failed = %d; want a.png only", failed)
+	}
+	if h := reopened.Header(); h.Compress == nil || h.Compress.MinSSIM != 0.95 {
+		t.Errorf("header = %+v; want the compress options back", h)
+	}
+	reopened.record("b.png", Result{Success: false, Message: "again"})
+	reopened.close()
+	if again, err := OpenManifest(m.Path()); err != nil {
+		t.Fatal(err)
+	} else if _, failed, _ := again.Progress(); failed != 2 {
+		t.Errorf("failed = %d after appending past the cut line; want 2", failed)
+	}
+}
+
+func TestManifestChecksEveryOutput(t *testing.T) {
+	if err := SetManifestDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetManifestDir("") })
+
+	dir := t.TempDir()
+	frames := []string{filepath.Join(dir, "anim-0.png"), filepath.Join(dir, "anim-1.png")}
+	for _, f := range frames {
+		if err := os.WriteFile(f, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := CreateManifest(ManifestHeader{
+		Operation: BatchOpConvert,
+		Inputs:    []string{"anim.gif"},
+		Convert:   &BatchConvertOptions{OutputFormat: FormatPNG},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Discard()
+
+	m.record("anim.gif", Result{Success: true, OutputPath: frames[0], OutputPaths: frames})
+	result, ok := m.completed("anim.gif")
+	if !ok || len(result.OutputPaths) != 2 {
+		t.Fatalf("completed = %+v, %v; want both frames", result, ok)
+	}
+
+	// Editing any frame makes the input due again
+	if err := os.WriteFile(frames[1], []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.completed("anim.gif"); ok {
+		t.Error("an input whose second frame changed still counts as completed")
+	}
+}
+
+func TestPendingManifestsSkipsRunningBatches(t *testing.T) {
+	if err := SetManifestDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetManifestDir("") })
+
+	m, err := CreateManifest(ManifestHeader{
+		Operation: BatchOpConvert,
+		Inputs:    []string{"a.png"},
+		Convert:   &BatchConvertOptions{OutputFormat: FormatPNG},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Discard()
+
+	// This process is still running the batch
+	if pending, _ := PendingManifests(); len(pending) != 0 {
+		t.Fatalf("PendingManifests = %d; a running batch is not pending", len(pending))
+	}
+
+	// Its owner exited without finishing it
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.setOwner(exited.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	pending, _ := PendingManifests()
+	if len(pending) != 1 || pending[0].Header().PID != exited.Process.Pid {
+		t.Fatalf("PendingManifests = %d; want the batch of the exited process", len(pending))
+	}
+
+	// Finishing a run that left inputs undone releases the manifest
+	if err := m.setOwner(os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := PendingManifests(); len(pending) != 1 || pending[0].Header().PID != 0 {
+		t.Errorf("a finished run's manifest should be pending with no owner")
+	}
+}
+
+func TestManifestClaim(t *testing.T) {
+	if err := SetManifestDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetManifestDir("") })
+
+	m, err := CreateManifest(ManifestHeader{
+		Operation: BatchOpConvert,
+		Inputs:    []string{"a.png"},
+		Convert:   &BatchConvertOptions{OutputFormat: FormatPNG},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Discard()
+	if err := m.Finish(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry reopens the manifest and claims it
+	reopened, err := OpenManifest(m.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Claim(); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := PendingManifests(); len(pending) != 0 {
+		t.Errorf("PendingManifests = %d; a claimed batch is not pending", len(pending))
+	}
+
+	// Another process that is still running owns it
+	if err := reopened.setOwner(os.Getppid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Claim(); !errors.Is(err, ErrManifestInUse) {
+		t.Errorf("Claim of a batch another process runs = %v; want ErrManifestInUse", err)
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Encoder qualities searched by CompressMethodQuality.
+const (
+	minSearchQuality = 1
+	maxSearchQuality = 100
+)
+
+// qualityCandidate is one encoding tried by the quality search, kept in a
+// temp file until it is chosen or discarded.
+type qualityCandidate struct {
+	temp    string
+	format  ImageFormat
+	quality int
+	size    int64
+	score   float64 // SSIM against the input
+}
+
+// passes reports whether the candidate meets the quality floor.
+func (c *qualityCandidate) passes(minSSIM float64) bool {
+	return c.score >= minSSIM
+}
+
+// betterThan reports whether c should be kept over other: meeting the
+// floor comes first, then the smaller file, then the higher score.
+func (c *qualityCandidate) betterThan(other *qualityCandidate, minSSIM float64) bool {
+	if other == nil {
+		return true
+	}
+	if c.passes(minSSIM) != other.passes(minSSIM) {
+		return c.passes(minSSIM)
+	}
+	if c.passes(minSSIM) {
+		return c.size < other.size
+	}
+	return c.score > other.score
+}
+
+// discard removes the candidate's temp file.
+func (c *qualityCandidate) discard() {
+	if c != nil {
+		discardTemp(c.temp)
+	}
+}
+
+// QualityPreset is the {preset} value for a quality-floor compression,
+// e.g. "ssim95" for 0.95.
+func QualityPreset(minSSIM float64) string {
+	return "ssim" + strings.TrimPrefix(strconv.FormatFloat(minSSIM, 'f', -1, 64), "0.")
+}
+
+// compressToQuality writes the smallest encoding of the input whose SSIM
+// against it is at least opts.MinSSIM, trying each allowed format. When no
+// quality reaches the floor the best-scoring encoding is kept. The output
+// takes the extension of the chosen format; animated inputs keep only
+// their first frame. The timeout policy limits each read and encode
+// of the search rather than the search as a whole.
+func compressToQuality(ctx context.Context, opts CompressOptions, inputSize int64) Result {
+	if IsPDFFile(opts.InputPath) {
+		err := fmt.Errorf("quality-floor compression works on images only")
+		return Result{Success: false, Message: "Quality-floor compression works on images only", Error: err}
+	}
+	if opts.MinSSIM <= 0 || opts.MinSSIM >= 1 {
+		err := fmt.Errorf("minimum SSIM %g is not between 0 and 1", opts.MinSSIM)
+		return Result{Success: false, Message: fmt.Sprintf("Invalid quality floor: %v", err), Error: err}
+	}
+
+	formats := opts.Formats
+	if len(formats) == 0 {
+		formats = []ImageFormat{FormatJPG}
+	}
+	base := strings.TrimSuffix(opts.OutputPath, filepath.Ext(opts.OutputPath))
+
+	// With one format the output name is known up front, so an existing
+	// output is handled before any encoding
+	if len(formats) == 1 {
+		outputPath, skip, err := resolveOutput(base+"."+string(formats[0]), opts.Collision)
+		if err != nil {
+			return collisionFailedResult(err)
+		}
+		if skip {
+			return skippedResult(outputPath)
+		}
+		defer releaseOutput(outputPath)
+		if err := CheckNotInput(opts.InputPath, outputPath); err != nil {
+			return collisionFailedResult(err)
+		}
+		base = strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	}
+
+	refCtx, cancel := withTimeout(ctx, opts.Timeout, opts.InputPath)
+	ref, err := loadReference(refCtx, opts.InputPath)
+	cancel()
+	if err != nil {
+		return Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read input file: %v", err),
+			Error:   err,
+		}
+	}
+
+	var best *qualityCandidate
+	for _, format := range formats {
+		c, result := searchQuality(ctx, ref, opts, format, base)
+		if c == nil {
+			best.discard()
+			return result
+		}
+		if c.betterThan(best, opts.MinSSIM) {
+			best.discard()
+			best = c
+		} else {
+			c.discard()
+		}
+	}
+
+	outputPath := base + "." + string(best.format)
+	if len(formats) > 1 {
+		resolved, skip, err := resolveOutput(outputPath, opts.Collision)
+		if err == nil && !skip {
+			defer releaseOutput(resolved)
+			err = CheckNotInput(opts.InputPath, resolved)
+		}
+		if err != nil || skip {
+			best.discard()
+			if err != nil {
+				return collisionFailedResult(err)
+			}
+			return skippedResult(resolved)
+		}
+		outputPath = resolved
+	}
+
+	outputs, err := commitOutput(best.temp, outputPath, opts.Collision, opts.InputPath)
+	if errors.Is(err, errFramesExist) {
+		return skippedResult(outputs[0])
+	}
+	if err != nil {
+		return saveFailedResult(err)
+	}
+	outputSize := totalSize(outputs)
+
+	var msg string
+	switch {
+	case !best.passes(opts.MinSSIM):
+		msg = fmt.Sprintf("Compressed, but even quality %d only reaches SSIM %.4f", best.quality, best.score)
+	case outputSize > inputSize:
+		msg = fmt.Sprintf("Compressed to quality %d (SSIM %.4f), but the output is larger than the original", best.quality, best.score)
+	default:
+		reduction := 0.0
+		if inputSize > 0 {
+			reduction = float64(inputSize-outputSize) / float64(inputSize) * 100
+		}
+		msg = fmt.Sprintf("Compressed to quality %d (SSIM %.4f)! Reduced by %.1f%%", best.quality, best.score, reduction)
+	}
+
+	return Result{
+		Success:    true,
+		Message:    msg,
+		OutputPath: outputPath,
+		OutputSize: outputSize,
+		Quality:    best.quality,
+		Score:      best.score,
+	}
+}
+
+// searchQuality binary searches the lowest quality of format that meets
+// the floor. SSIM rises with quality, so the lowest passing quality gives
+// the smallest file. If none passes, the highest quality tried is
+// returned. A nil candidate comes with the failed result.
+func searchQuality(ctx context.Context, ref *reference, opts CompressOptions, format ImageFormat, base string) (*qualityCandidate, Result) {
+	var pass, fail *qualityCandidate
+	lo, hi := minSearchQuality, maxSearchQuality
+	for lo <= hi {
+		q := (lo + hi) / 2
+		c, result := encodeCandidate(ctx, ref, opts, format, base, q)
+		if c == nil {
+			pass.discard()
+			fail.discard()
+			return nil, result
+		}
+		if c.passes(opts.MinSSIM) {
+			pass.discard()
+			pass = c
+			hi = q - 1
+		} else {
+			fail.discard()
+			fail = c
+			lo = q + 1
+		}
+	}
+
+	if pass != nil {
+		fail.discard()
+		return pass, Result{}
+	}
+	return fail, Result{}
+}
+
+// encodeCandidate encodes the input as format at quality q and scores it,
+// within the time limit of one step of the search.
+func encodeCandidate(ctx context.Context, ref *reference, opts CompressOptions, format ImageFormat, base string, q int) (*qualityCandidate, Result) {
+	ctx, cancel := withTimeout(ctx, opts.Timeout, opts.InputPath)
+	defer cancel()
+
+	temp := newTempOutput(base + "." + string(format))
+	cmd := magickCommand(ctx, opts.Limits, opts.InputPath+"[0]", "-quality", strconv.Itoa(q), temp)
+	output, err := runCommand(ctx, cmd)
+	if err != nil {
+		discardTemp(temp)
+		return nil, failedResult("Compression", cmd, err, output)
+	}
+
+	info, err := os.Stat(temp)
+	if err != nil {
+		discardTemp(temp)
+		return nil, saveFailedResult(err)
+	}
+	pix, err := ref.read(ctx, temp)
+	if err != nil {
+		discardTemp(temp)
+		return nil, Result{
+			Success: false,
+			Message: fmt.Sprintf("Failed to measure quality %d: %v", q, err),
+			Error:   err,
+		}
+	}
+	score, _ := ssim(ref.luma(), luma(pix), ref.w, ref.h)
+
+	return &qualityCandidate{
+		temp:    temp,
+		format:  format,
+		quality: q,
+		size:    info.Size(),
+		score:   score,
+	}, Result{}
+}
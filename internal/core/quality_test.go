@@ -0,0 +1,185 @@
+package core
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEncoder installs a magick that "encodes" by copying the input, or a
+// degraded copy below quality cutoff, padded so higher qualities are
+// larger. Decoding copies the file unchanged; PNG readers ignore the
+// padding.
+func fakeEncoder(t *testing.T, degraded string, cutoff int) {
+	t.Helper()
+	fakeMagick(t, fmt.Sprintf(`for last; do :; done
+case "$1" in
+-ping) echo "8 8"; exit 0 ;;
+esac
+src="${1%%\[0\]}"
+if [ "$2" = "-quality" ]; then
+	if [ "$3" -lt %d ]; then src=%q; fi
+	cp "$src" "$last"
+	head -c $(($3 * 100)) /dev/zero >> "$last"
+else
+	cp "$src" "$last"
+fi
+`, cutoff, degraded))
+}
+
+func TestCompressToQuality(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "photo.png")
+	degraded := filepath.Join(t.TempDir(), "degraded.png")
+	writePNG(t, input, 8, 8, func(x, y int) color.Color { return color.NRGBA{uint8(x * 30), uint8(y * 30), 0, 255} })
+	writePNG(t, degraded, 8, 8, func(x, y int) color.Color { return color.NRGBA{uint8(x*30) ^ 0x40, 0, uint8(y * 30), 255} })
+	fakeEncoder(t, degraded, 60)
+
+	result := CompressFile(CompressOptions{
+		InputPath: input,
+		Method:    CompressMethodQuality,
+		MinSSIM:   0.95,
+	})
+	if !result.Success {
+		t.Fatalf("compression failed: %s", result.Message)
+	}
+	if result.Quality != 60 || result.Score < 0.95 {
+		t.Errorf("chose quality %d with SSIM %.4f; want the lowest passing quality 60", result.Quality, result.Score)
+	}
+	if want := filepath.Join(dir, "photo_comp.jpg"); result.OutputPath != want {
+		t.Errorf("output = %s; want %s", result.OutputPath, want)
+	}
+	if names := dirNames(t, dir); len(names) != 2 {
+		t.Errorf("files after the search = %v; want input and output only", names)
+	}
+
+	// A floor no quality reaches keeps the best attempt
+	fakeEncoder(t, degraded, 101)
+	result = CompressFile(CompressOptions{
+		InputPath:  input,
+		Method:     CompressMethodQuality,
+		MinSSIM:    0.95,
+		OutputPath: filepath.Join(dir, "never.jpg"),
+	})
+	if !result.Success || result.Quality != 100 || result.Score >= 0.95 {
+		t.Fatalf("result = %+v; want success at quality 100 below the floor", result)
+	}
+
+	fakeEncoder(t, degraded, 60)
+	if err := os.WriteFile(degraded, []byte("not a png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Unreadable candidates fail the compression and leave nothing behind
+	result = CompressFile(CompressOptions{
+		InputPath:  input,
+		Method:     CompressMethodQuality,
+		MinSSIM:    0.95,
+		OutputPath: filepath.Join(dir, "broken.jpg"),
+	})
+	if result.Success {
+		t.Error("expected a failure when a candidate can't be measured")
+	}
+	for _, name := range dirNames(t, dir) {
+		if strings.HasPrefix(name, TempPrefix) || name == "broken.jpg" {
+			t.Errorf("left %s behind", name)
+		}
+	}
+}
+
+func TestCompressToQualityFormats(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "photo.png")
+	writePNG(t, input, 8, 8, func(x, y int) color.Color { return color.NRGBA{uint8(x * 30), uint8(y * 30), 0, 255} })
+
+	// WebP candidates get less padding, so they win
+	fakeMagick(t, `for last; do :; done
+case "$1" in
+-ping) echo "8 8"; exit 0 ;;
+esac
+cp "${1%\[0\]}" "$last"
+if [ "$2" = "-quality" ]; then
+	case "$last" in
+	*.webp) head -c $3 /dev/zero >> "$last" ;;
+	*) head -c $(($3 * 100)) /dev/zero >> "$last" ;;
+	esac
+fi
+`)
+
+	result := CompressFile(CompressOptions{
+		InputPath: input,
+		Method:    CompressMethodQuality,
+		MinSSIM:   0.99,
+		Formats:   []ImageFormat{FormatJPG, FormatWebP},
+	})
+	if !result.Success || result.Quality != 1 {
+		t.Fatalf("result = %+v; want success at quality 1", result)
+	}
+	if want := filepath.Join(dir, "photo_comp.webp"); result.OutputPath != want {
+		t.Errorf("output = %s; want %s", result.OutputPath, want)
+	}
+	if names := dirNames(t, dir); len(names) != 2 {
+		t.Errorf("files after the search = %v; want input and output only", names)
+	}
+}
+
+func TestCompressToQualityTimeoutPerStep(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "photo.png")
+	writePNG(t, input, 8, 8, func(x, y int) color.Color { return color.NRGBA{uint8(x * 30), uint8(y * 30), 0, 255} })
+
+	// Every encode takes 150ms, so the seven of a search take longer than
+	// the limit while each one fits in it
+	fakeMagick(t, `for last; do :; done
+case "$1" in
+-ping) echo "8 8"; exit 0 ;;
+esac
+if [ "$2" = "-quality" ]; then sleep 0.15; fi
+cp "${1%\[0\]}" "$last"
+`)
+	start := time.Now()
+	result := CompressFile(CompressOptions{
+		InputPath: input,
+		Method:    CompressMethodQuality,
+		MinSSIM:   0.95,
+		Timeout:   TimeoutPolicy{Base: 500 * time.Millisecond},
+	})
+	if !result.Success {
+		t.Fatalf("compression failed: %s", result.Message)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Skipf("search took only %v; it no longer outlasts one step's limit", elapsed)
+	}
+}
+
+func TestCompressToQualityRejects(t *testing.T) {
+	dir := t.TempDir()
+	pdf := filepath.Join(dir, "doc.pdf")
+	img := filepath.Join(dir, "photo.png")
+	for _, p := range []string{pdf, img} {
+		if err := os.WriteFile(p, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fakeMagick(t, "exit 1\n")
+
+	if r := CompressFile(CompressOptions{InputPath: pdf, Method: CompressMethodQuality, MinSSIM: 0.95}); r.Success {
+		t.Error("quality-floor compression of a PDF succeeded")
+	}
+	for _, floor := range []float64{0, 1, 1.5} {
+		if r := CompressFile(CompressOptions{InputPath: img, Method: CompressMethodQuality, MinSSIM: floor}); r.Success {
+			t.Errorf("MinSSIM %g was accepted", floor)
+		}
+	}
+}
+
+func TestQualityPreset(t *testing.T) {
+	for floor, want := range map[float64]string{0.95: "ssim95", 0.985: "ssim985", 0.9: "ssim9"} {
+		if got := QualityPreset(floor); got != want {
+			t.Errorf("QualityPreset(%g) = %q; want %q", floor, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileType is a file format identified from content or extension.
+type FileType string
+
+const (
+	TypeUnknown FileType = ""
+	TypePNG     FileType = "png"
+	TypeJPEG    FileType = "jpeg"
+	TypeGIF     FileType = "gif"
+	TypeBMP     FileType = "bmp"
+	TypeTIFF    FileType = "tiff"
+	TypeWebP    FileType = "webp"
+	TypeAVIF    FileType = "avif"
+	TypeHEIC    FileType = "heic"
+	TypePDF     FileType = "pdf"
+)
+
+// sniffLen is how many leading bytes SniffFile reads. PDF allows junk
+// before the %PDF- marker within the first 1024 bytes.
+const sniffLen = 1024
+
+// extensionTypes maps lower-case extensions to the type they promise.
+var extensionTypes = map[string]FileType{
+	".png":  TypePNG,
+	".jpg":  TypeJPEG,
+	".jpeg": TypeJPEG,
+	".gif":  TypeGIF,
+	".bmp":  TypeBMP,
+	".tif":  TypeTIFF,
+	".tiff": TypeTIFF,
+	".webp": TypeWebP,
+	".avif": TypeAVIF,
+	".heic": TypeHEIC,
+	".heif": TypeHEIC,
+	".pdf":  TypePDF,
+}
+
+// IsImage reports whether the type is a raster image format.
+func (t FileType) IsImage() bool {
+	return t != TypeUnknown && t != TypePDF
+}
+
+// String returns the upper-case format name, e.g. "PNG".
+func (t FileType) String() string {
+	if t == TypeUnknown {
+		return "unknown"
+	}
+	return strings.ToUpper(string(t))
+}
+
+// SniffBytes identifies a format from the leading bytes of a file.
+func SniffBytes(header []byte) FileType {
+	switch {
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return TypePNG
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return TypeJPEG
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return TypeGIF
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")),
+		bytes.HasPrefix(header, []byte("II+\x00")), bytes.HasPrefix(header, []byte("MM\x00+")):
+		return TypeTIFF
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && string(header[8:12]) == "WEBP":
+		return TypeWebP
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return sniffFtyp(header)
+	case len(header) >= 14 && bytes.HasPrefix(header, []byte("BM")) &&
+		bytes.Equal(header[6:10], []byte{0, 0, 0, 0}):
+		// BMP's two reserved header fields are always zero
+		return TypeBMP
+	}
+
+	limit := len(header)
+	if limit > sniffLen {
+		limit = sniffLen
+	}
+	if bytes.Contains(header[:limit], []byte("%PDF-")) {
+		return TypePDF
+	}
+	return TypeUnknown
+}
+
+// sniffFtyp identifies an ISO base media file from its ftyp box brands.
+func sniffFtyp(header []byte) FileType {
+	size := int(header[0])<<24 | int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+	if size < 16 || size > len(header) {
+		size = len(header)
+	}
+
+	// Major brand at 8, minor version at 12, compatible brands from 16
+	brands := []string{string(header[8:12])}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, string(header[i:i+4]))
+	}
+
+	heif := false
+	for _, b := range brands {
+		switch b {
+		case "avif", "avis":
+			return TypeAVIF
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+			heif = true
+		}
+	}
+	if heif {
+		return TypeHEIC
+	}
+	return TypeUnknown
+}
+
+// SniffFile identifies a file's format from its content.
+func SniffFile(path string) (FileType, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return TypeUnknown, err
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return TypeUnknown, err
+	}
+	return SniffBytes(header[:n]), nil
+}
+
+// ExtensionType returns the format a path's extension claims.
+func ExtensionType(path string) FileType {
+	return extensionTypes[strings.ToLower(filepath.Ext(path))]
+}
+
+// DetectType returns a file's format from its content, falling back to
+// the extension when the content isn't recognised (e.g. an empty or
+// unreadable file).
+func DetectType(path string) FileType {
+	if t, err := SniffFile(path); err == nil && t != TypeUnknown {
+		return t
+	}
+	return ExtensionType(path)
+}
+
+// ExtensionMismatch reports whether a file's content is a known format
+// that differs from the one its extension claims. Files without a known
+// extension never mismatch.
+func ExtensionMismatch(path string, detected FileType) bool {
+	ext := ExtensionType(path)
+	return ext != TypeUnknown && detected != TypeUnknown && ext != detected
+}
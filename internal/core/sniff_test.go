@@ -0,0 +1,85 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ftyp builds an ISO base media ftyp box with the given brands.
+func ftyp(major string, compatible ...string) []byte {
+	size := 16 + 4*len(compatible)
+	box := []byte{0, 0, 0, byte(size)}
+	box = append(box, "ftyp"+major+"\x00\x00\x00\x00"...)
+	for _, b := range compatible {
+		box = append(box, b...)
+	}
+	return box
+}
+
+func TestSniffBytes(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   FileType
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), TypePNG},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F'}, TypeJPEG},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), TypeGIF},
+		{"bmp", []byte("BM\x36\x00\x0c\x00\x00\x00\x00\x00\x36\x00\x00\x00"), TypeBMP},
+		{"tiff le", []byte("II*\x00\x08\x00\x00\x00"), TypeTIFF},
+		{"tiff be", []byte("MM\x00*\x00\x00\x00\x08"), TypeTIFF},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), TypeWebP},
+		{"avif", ftyp("avif", "mif1", "miaf"), TypeAVIF},
+		{"avif compatible", ftyp("mif1", "avif", "miaf"), TypeAVIF},
+		{"heic", ftyp("heic", "mif1", "heic"), TypeHEIC},
+		{"heif generic", ftyp("mif1", "heic"), TypeHEIC},
+		{"mp4", ftyp("isom", "iso2", "mp41"), TypeUnknown},
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3"), TypePDF},
+		{"pdf after junk", append([]byte("garbage\r\n"), "%PDF-1.4"...), TypePDF},
+		{"riff wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), TypeUnknown},
+		{"text", []byte("BM is not enough"), TypeUnknown},
+		{"empty", nil, TypeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := SniffBytes(tt.header); got != tt.want {
+			t.Errorf("%s: SniffBytes = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectType(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	pngData := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	lying := write("photo.jpg", pngData)
+	if got := DetectType(lying); got != TypePNG {
+		t.Errorf("DetectType(png named .jpg) = %q; want png", got)
+	}
+	if !ExtensionMismatch(lying, TypePNG) {
+		t.Error("ExtensionMismatch should report a PNG named .jpg")
+	}
+
+	download := write("download", pngData)
+	if got := DetectType(download); got != TypePNG {
+		t.Errorf("DetectType(extensionless png) = %q; want png", got)
+	}
+	if ExtensionMismatch(download, TypePNG) {
+		t.Error("files without a known extension should not mismatch")
+	}
+
+	// Unrecognised content falls back to the extension
+	empty := write("empty.webp", nil)
+	if got := DetectType(empty); got != TypeWebP {
+		t.Errorf("DetectType(empty .webp) = %q; want webp", got)
+	}
+}
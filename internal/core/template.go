@@ -0,0 +1,265 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name template tokens. A template is the output file name without its
+// extension, which is always the output format.
+//
+//	{name}         input file name without extension
+//	{ext}          input extension without the dot
+//	{format}       output format
+//	{page}         page number of a PDF page; {page:03} pads to 3 digits
+//	{width}        output width in pixels
+//	{height}       output height in pixels
+//	{date}         current date as 2006-01-02; {date:20060102} sets the layout
+//	{hash8}        first 8 hex digits of the input's SHA-256
+//	{preset}       short label for the operation's settings, e.g. 75pct
+const (
+	TokenName   = "name"
+	TokenExt    = "ext"
+	TokenFormat = "format"
+	TokenPage   = "page"
+	TokenWidth  = "width"
+	TokenHeight = "height"
+	TokenDate   = "date"
+	TokenHash8  = "hash8"
+	TokenPreset = "preset"
+)
+
+// defaultDateLayout is used by {date} without a layout.
+const defaultDateLayout = "2006-01-02"
+
+// TemplateData holds the values substituted into a name template.
+type TemplateData struct {
+	InputPath string
+	Format    string
+	Page      int
+	Width     int
+	Height    int
+	Date      time.Time
+	Preset    string
+
+	hash string // Cached {hash8} value
+}
+
+// NewTemplateData returns data for an input file and output format, dated
+// now.
+func NewTemplateData(inputPath, format, preset string) *TemplateData {
+	return &TemplateData{
+		InputPath: inputPath,
+		Format:    format,
+		Date:      time.Now(),
+		Preset:    preset,
+	}
+}
+
+// templateToken is a parsed {token:arg}.
+type templateToken struct {
+	name string
+	arg  string
+}
+
+// parseTemplate splits a template into literal text and tokens, calling
+// literal or token for each part in order.
+func parseTemplate(tmpl string, literal func(string), token func(templateToken) error) error {
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			literal(tmpl)
+			return nil
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return fmt.Errorf("unclosed { in template")
+		}
+		literal(tmpl[:start])
+
+		name, arg, _ := strings.Cut(tmpl[start+1:start+end], ":")
+		if err := token(templateToken{name: name, arg: arg}); err != nil {
+			return err
+		}
+		tmpl = tmpl[start+end+1:]
+	}
+}
+
+// ValidateTemplate checks that a template only uses known tokens with
+// valid arguments.
+func ValidateTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return fmt.Errorf("template is empty")
+	}
+	_, err := (&TemplateData{hash: "00000000"}).Expand(tmpl)
+	return err
+}
+
+// TemplateUses reports whether a template contains the token.
+func TemplateUses(tmpl, token string) bool {
+	found := false
+	parseTemplate(tmpl, func(string) {}, func(t templateToken) error {
+		if t.name == token {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// Expand substitutes the data into a template and returns a file name
+// that is safe on every platform.
+func (d *TemplateData) Expand(tmpl string) (string, error) {
+	var b strings.Builder
+
+	err := parseTemplate(tmpl, func(s string) { b.WriteString(s) }, func(t templateToken) error {
+		value, err := d.tokenValue(t)
+		if err != nil {
+			return err
+		}
+		b.WriteString(value)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	name := sanitizeFileName(b.String())
+	if name == "" {
+		return "", fmt.Errorf("template produces an empty file name")
+	}
+	return name, nil
+}
+
+// tokenValue returns the value for a single token.
+func (d *TemplateData) tokenValue(t templateToken) (string, error) {
+	noArg := func(v string) (string, error) {
+		if t.arg != "" {
+			return "", fmt.Errorf("{%s} takes no argument", t.name)
+		}
+		return v, nil
+	}
+
+	switch t.name {
+	case TokenName:
+		return noArg(strings.TrimSuffix(filepath.Base(d.InputPath), filepath.Ext(d.InputPath)))
+	case TokenExt:
+		return noArg(strings.TrimPrefix(filepath.Ext(d.InputPath), "."))
+	case TokenFormat:
+		return noArg(d.Format)
+	case TokenWidth:
+		return noArg(strconv.Itoa(d.Width))
+	case TokenHeight:
+		return noArg(strconv.Itoa(d.Height))
+	case TokenPreset:
+		return noArg(d.Preset)
+	case TokenPage:
+		if t.arg == "" {
+			return strconv.Itoa(d.Page), nil
+		}
+		width, err := strconv.Atoi(t.arg)
+		if err != nil || width < 1 || width > 9 {
+			return "", fmt.Errorf("{page:%s} needs a width from 1 to 9, e.g. {page:03}", t.arg)
+		}
+		return fmt.Sprintf("%0*d", width, d.Page), nil
+	case TokenDate:
+		layout := t.arg
+		if layout == "" {
+			layout = defaultDateLayout
+		}
+		return d.Date.Format(layout), nil
+	case TokenHash8:
+		if t.arg != "" {
+			return "", fmt.Errorf("{hash8} takes no argument")
+		}
+		if d.hash == "" {
+			hash, err := HashFile(d.InputPath)
+			if err != nil {
+				return "", fmt.Errorf("failed to hash input: %w", err)
+			}
+			d.hash = hash[:8]
+		}
+		return d.hash, nil
+	}
+	return "", fmt.Errorf("unknown token {%s}", t.name)
+}
+
+// sanitizeFileName replaces characters that are invalid in file names on
+// Windows or Unix, and trims trailing dots and spaces.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.TrimRight(strings.TrimSpace(name), ". ")
+}
+
+// TemplateOutputPath returns dir joined with the expanded template and the
+// output format as extension.
+func TemplateOutputPath(dir, tmpl string, data *TemplateData) (string, error) {
+	name, err := data.Expand(tmpl)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+"."+data.Format), nil
+}
+
+// HashFile returns the hex SHA-256 of a file's content.
+func HashFile(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("no file to hash")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ImageDimensions returns the width and height of an image's first frame
+// as reported by ImageMagick.
+func ImageDimensions(path string) (width, height int, err error) {
+	return imageDimensions(context.Background(), path)
+}
+
+// imageDimensions is ImageDimensions with a context.
+func imageDimensions(ctx context.Context, path string) (width, height int, err error) {
+	cmd := magickCommand(ctx, ResourceLimits{}, "-ping", path+"[0]", "-format", "%w %h", "info:")
+	output, err := runCommand(ctx, cmd)
+	if err != nil {
+		return 0, 0, ClassifyError(err, string(output))
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d %d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("unexpected identify output %q", output)
+	}
+	return width, height, nil
+}
+
+// outputFromTemplate expands tmpl for an input into dir, probing the
+// input's dimensions only when the template uses them.
+func outputFromTemplate(ctx context.Context, dir, tmpl, inputPath, format, preset string) (string, error) {
+	data := NewTemplateData(inputPath, format, preset)
+	if TemplateUses(tmpl, TokenWidth) || TemplateUses(tmpl, TokenHeight) {
+		var err error
+		if data.Width, data.Height, err = imageDimensions(ctx, inputPath); err != nil {
+			return "", err
+		}
+	}
+	return TemplateOutputPath(dir, tmpl, data)
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	data := &TemplateData{
+		InputPath: filepath.Join("in", "Holiday Photo.HEIC"),
+		Format:    "jpg",
+		Page:      7,
+		Width:     1920,
+		Height:    1080,
+		Date:      time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC),
+		Preset:    "75pct",
+		hash:      "deadbeef",
+	}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{name}_conv", "Holiday Photo_conv"},
+		{"{name}.{ext}", "Holiday Photo.HEIC"},
+		{"{format}-{preset}", "jpg-75pct"},
+		{"Page-{page}", "Page-7"},
+		{"Page-{page:03}", "Page-007"},
+		{"{width}x{height}", "1920x1080"},
+		{"{date}", "2024-03-09"},
+		{"{date:20060102}", "20240309"},
+		{"{name}-{hash8}", "Holiday Photo-deadbeef"},
+		{"a/b\\c:d*e?", "a_b_c_d_e_"},
+		{"{name}...", "Holiday Photo"},
+	}
+
+	for _, tt := range tests {
+		got, err := data.Expand(tt.tmpl)
+		if err != nil {
+			t.Errorf("Expand(%q) error: %v", tt.tmpl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Expand(%q) = %q; want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	valid := []string{"{name}_conv", "Page-{page:03}", "{date:2006}-{hash8}", "plain"}
+	for _, tmpl := range valid {
+		if err := ValidateTemplate(tmpl); err != nil {
+			t.Errorf("ValidateTemplate(%q) error: %v", tmpl, err)
+		}
+	}
+
+	invalid := []string{"", "  ", "{nme}", "{name", "{page:x}", "{page:0}", "{name:up}", "{hash8:4}", "..."}
+	for _, tmpl := range invalid {
+		if err := ValidateTemplate(tmpl); err == nil {
+			t.Errorf("ValidateTemplate(%q) should fail", tmpl)
+		}
+	}
+}
+
+func TestTemplateUses(t *testing.T) {
+	if !TemplateUses("{name}-{page:02}", TokenPage) {
+		t.Error("TemplateUses should find {page:02}")
+	}
+	if TemplateUses("{name}-page", TokenPage) {
+		t.Error("TemplateUses matched literal text")
+	}
+	if got := PageTemplate("{name}"); got != "{name}-{page}" {
+		t.Errorf("PageTemplate = %q; want {name}-{page}", got)
+	}
+	if got := PageTemplate("p{page:03}"); got != "p{page:03}" {
+		t.Errorf("PageTemplate changed a template with {page}: %q", got)
+	}
+}
+
+func TestTemplateHash(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.png")
+	if err := os.WriteFile(input, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := TemplateOutputPath(dir, "{name}-{hash8}", NewTemplateData(input, "webp", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sha256("hello") = 2cf24dba...
+	if want := filepath.Join(dir, "in-2cf24dba.webp"); path != want {
+		t.Errorf("TemplateOutputPath = %q; want %q", path, want)
+	}
+
+	missing := NewTemplateData(filepath.Join(dir, "missing.png"), "png", "")
+	if _, err := missing.Expand("{hash8}"); err == nil {
+		t.Error("Expand should fail to hash a missing input")
+	}
+}
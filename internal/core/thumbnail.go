@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxThumbnails bounds the thumbnail cache; the oldest are removed first.
+const maxThumbnails = 500
+
+var thumbnailDir struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// SetThumbnailDir sets the folder thumbnails are cached in. Until it is
+// called, or after it is called with "", they go to a folder in the
+// system temp directory.
+func SetThumbnailDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	thumbnailDir.mu.Lock()
+	defer thumbnailDir.mu.Unlock()
+	thumbnailDir.dir = dir
+	return nil
+}
+
+// thumbnailCacheDir returns the cache folder, creating the default one.
+func thumbnailCacheDir() (string, error) {
+	thumbnailDir.mu.Lock()
+	dir := thumbnailDir.dir
+	thumbnailDir.mu.Unlock()
+	if dir != "" {
+		return dir, nil
+	}
+	dir = filepath.Join(os.TempDir(), "imagetool-thumbnails")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// Thumbnail returns a PNG of the first page or frame of an image or PDF,
+// scaled to fit size×size pixels. Thumbnails are cached by path, size and
+// modification time, so a changed file gets a new one.
+func Thumbnail(ctx context.Context, path string, size int) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	dir, err := thumbnailCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	thumb := filepath.Join(dir, thumbnailKey(path, info, size)+".png")
+	if fileExists(thumb) {
+		// Mark it recently used so pruning keeps it
+		now := time.Now()
+		os.Chtimes(thumb, now, now)
+		return thumb, nil
+	}
+
+	// Flatten transparency onto white so every renderer shows the same
+	// thing
+	temp := newTempOutput(thumb)
+	cmd := magickCommand(ctx, ResourceLimits{}, path+"[0]", "-auto-orient",
+		"-thumbnail", fmt.Sprintf("%dx%d>", size, size),
+		"-background", "white", "-alpha", "remove", "-alpha", "off", temp)
+	output, err := runCommand(ctx, cmd)
+	if err != nil {
+		discardTemp(temp)
+		return "", ClassifyError(err, string(output))
+	}
+	if _, err := commitOutput(temp, thumb, CollisionOverwrite, ""); err != nil {
+		return "", err
+	}
+
+	pruneThumbnails(dir, maxThumbnails)
+	return thumb, nil
+}
+
+// thumbnailKey identifies a thumbnail of a file version at a size.
+func thumbnailKey(path string, info os.FileInfo, size int) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%d", path, info.Size(), info.ModTime().UnixNano(), size)))
+	return hex.EncodeToString(sum[:])[:24]
+}
+
+// pruneThumbnails removes the least recently used thumbnails beyond max.
+func pruneThumbnails(dir string, max int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type thumb struct {
+		name string
+		used int64
+	}
+	var thumbs []thumb
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".png") || strings.HasPrefix(e.Name(), TempPrefix) {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			thumbs = append(thumbs, thumb{e.Name(), info.ModTime().UnixNano()})
+		}
+	}
+	if len(thumbs) <= max {
+		return
+	}
+
+	sort.Slice(thumbs, func(i, j int) bool { return thumbs[i].used < thumbs[j].used })
+	for _, t := range thumbs[:len(thumbs)-max] {
+		os.Remove(filepath.Join(dir, t.name))
+	}
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThumbnailCache(t *testing.T) {
+	if err := SetThumbnailDir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer SetThumbnailDir("")
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.jpg")
+	if err := os.WriteFile(input, []byte("jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each run appends to a counter file so cache hits can be told apart
+	counter := filepath.Join(dir, "runs")
+	fakeMagick(t, fmt.Sprintf(`echo run >> %q; for last; do :; done; printf png > "$last"`+"\n", counter))
+	runs := func() int {
+		data, _ := os.ReadFile(counter)
+		return len(data) / len("run\n")
+	}
+
+	first, err := Thumbnail(context.Background(), input, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(first); string(data) != "png" {
+		t.Errorf("thumbnail = %q; want png", data)
+	}
+
+	second, err := Thumbnail(context.Background(), input, 64)
+	if err != nil || second != first || runs() != 1 {
+		t.Errorf("second call = %s, %v after %d runs; want cached %s", second, err, runs(), first)
+	}
+
+	// Another size or a modified input makes a new thumbnail
+	if other, _ := Thumbnail(context.Background(), input, 128); other == first {
+		t.Error("a different size reused the cached thumbnail")
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(input, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := Thumbnail(context.Background(), input, 64); changed == first || runs() != 3 {
+		t.Errorf("modified input reused %s after %d runs", changed, runs())
+	}
+}
+
+func TestPruneThumbnails(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.png", i))
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		used := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(path, used, used)
+	}
+
+	pruneThumbnails(dir, 3)
+	names := dirNames(t, dir)
+	if len(names) != 3 || names[0] != "2.png" {
+		t.Errorf("kept %v; want the 3 most recently used", names)
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"imagetool/internal/logging"
+)
+
+// killWaitDelay is how long to wait for output pipes to close after a
+// timed-out process is killed.
+const killWaitDelay = 5 * time.Second
+
+// maxPageScanBytes caps how much of a PDF is read to estimate its pages.
+const maxPageScanBytes = 64 << 20
+
+// TimeoutPolicy scales a job's time limit with its input: Base plus PerMB
+// for each MB of input plus PerPage for each PDF page, capped at Max. A
+// zero Base disables the timeout.
+type TimeoutPolicy struct {
+	Base    time.Duration
+	PerMB   time.Duration
+	PerPage time.Duration
+	Max     time.Duration // 0 means no cap
+}
+
+// For returns the time limit for an input of the given size and page
+// count, or 0 when timeouts are disabled.
+func (p TimeoutPolicy) For(sizeBytes int64, pages int) time.Duration {
+	if p.Base <= 0 {
+		return 0
+	}
+
+	limit := p.Base
+	limit += time.Duration(float64(p.PerMB) * float64(sizeBytes) / (1024 * 1024))
+	limit += p.PerPage * time.Duration(pages)
+	if p.Max > 0 && limit > p.Max {
+		limit = p.Max
+	}
+	return limit
+}
+
+// forFile returns the time limit for an input file, counting pages for
+// PDFs. Unreadable files get the base limit.
+func (p TimeoutPolicy) forFile(path string) time.Duration {
+	if p.Base <= 0 {
+		return 0
+	}
+
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+	pages := 0
+	if IsPDFFile(path) {
+		pages = PDFPageCount(path)
+	}
+	return p.For(size, pages)
+}
+
+var (
+	pdfPageRe  = regexp.MustCompile(`/Type\s*/Page[^s]`)
+	pdfCountRe = regexp.MustCompile(`/Count\s+(\d+)`)
+)
+
+// PDFPageCount estimates the number of pages in a PDF without running any
+// external tool. It returns 0 when the count can't be determined, e.g.
+// when page objects are inside compressed object streams.
+func PDFPageCount(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxPageScanBytes))
+	if err != nil {
+		return 0
+	}
+
+	if n := len(pdfPageRe.FindAll(data, -1)); n > 0 {
+		return n
+	}
+
+	// Fall back to the largest /Count, which belongs to the root page tree
+	count := 0
+	for _, m := range pdfCountRe.FindAllSubmatch(data, -1) {
+		var n int
+		fmt.Sscanf(string(m[1]), "%d", &n)
+		if n > count {
+			count = n
+		}
+	}
+	return count
+}
+
+// withTimeout derives a context limited by the policy for the input file.
+// The cancel func must always be called.
+func withTimeout(ctx context.Context, policy TimeoutPolicy, inputPath string) (context.Context, context.CancelFunc) {
+	if limit := policy.forFile(inputPath); limit > 0 {
+		return context.WithTimeout(ctx, limit)
+	}
+	return context.WithCancel(ctx)
+}
+
+// runCommand runs cmd and returns its combined output. If ctx expires the
+// process tree is killed, the command line is logged for reproduction and
+// the error wraps context.DeadlineExceeded.
+func runCommand(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		logging.Error("Command timed out", map[string]interface{}{
+			"command": commandLine(cmd),
+			"elapsed": time.Since(start).Round(time.Millisecond),
+		})
+		err = fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return output, err
+}
+
+// commandLine formats cmd as a shell command, quoting arguments that
+// contain spaces or shell metacharacters.
+func commandLine(cmd *exec.Cmd) string {
+	parts := make([]string, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		if i == 0 {
+			arg = cmd.Path
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t\"'`$&|;<>()*?[]#~%!") {
+			arg = `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
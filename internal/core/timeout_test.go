@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTimeoutPolicyFor(t *testing.T) {
+	p := TimeoutPolicy{Base: time.Minute, PerMB: 2 * time.Second, PerPage: 5 * time.Second, Max: 5 * time.Minute}
+
+	tests := []struct {
+		size  int64
+		pages int
+		want  time.Duration
+	}{
+		{0, 0, time.Minute},
+		{10 * 1024 * 1024, 0, time.Minute + 20*time.Second},
+		{0, 4, time.Minute + 20*time.Second},
+		{0, 1000, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.For(tt.size, tt.pages); got != tt.want {
+			t.Errorf("For(%d, %d) = %v; want %v", tt.size, tt.pages, got, tt.want)
+		}
+	}
+
+	if got := (TimeoutPolicy{PerMB: time.Second}).For(1<<30, 10); got != 0 {
+		t.Errorf("disabled policy For() = %v; want 0", got)
+	}
+}
+
+func TestPDFPageCount(t *testing.T) {
+	dir := t.TempDir()
+
+	pages := filepath.Join(dir, "pages.pdf")
+	content := "%PDF-1.4\n1 0 obj << /Type /Pages /Kids [2 0 R 3 0 R] /Count 2 >> endobj\n" +
+		"2 0 obj << /Type /Page /Parent 1 0 R >> endobj\n3 0 obj << /Type/Page /Parent 1 0 R >> endobj\n"
+	if err := os.WriteFile(pages, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := PDFPageCount(pages); got != 2 {
+		t.Errorf("PDFPageCount(pages) = %d; want 2", got)
+	}
+
+	count := filepath.Join(dir, "count.pdf")
+	if err := os.WriteFile(count, []byte("%PDF-1.5\n<< /Type /Pages /Count 12 >>\n<< /Count 3 >>\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := PDFPageCount(count); got != 12 {
+		t.Errorf("PDFPageCount(count) = %d; want 12", got)
+	}
+
+	if got := PDFPageCount(filepath.Join(dir, "missing.pdf")); got != 0 {
+		t.Errorf("PDFPageCount(missing) = %d; want 0", got)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	cmd := exec.Command("magick", "in file.png", "-quality", "90", "out.jpg")
+	cmd.Path = "/usr/bin/magick"
+	want := `/usr/bin/magick "in file.png" -quality 90 out.jpg`
+	if got := commandLine(cmd); got != want {
+		t.Errorf("commandLine() = %s; want %s", got, want)
+	}
+}
+
+func TestConvertImageTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake magick script requires a POSIX shell")
+	}
+
+	// A fake magick that starts a long-running child, like a stuck delegate
+	bin := t.TempDir()
+	script := "#!/bin/sh\nsleep 30 &\nwait\n"
+	if err := os.WriteFile(filepath.Join(bin, "magick"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	input := filepath.Join(t.TempDir(), "in.png")
+	if err := os.WriteFile(input, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	result := ConvertImage(ConvertImageOptions{
+		InputPath:    input,
+		OutputFormat: FormatJPG,
+		Timeout:      TimeoutPolicy{Base: 200 * time.Millisecond},
+	})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("conversion took %v; the process tree should be killed at the timeout", elapsed)
+	}
+
+	if result.Success {
+		t.Fatal("expected the conversion to fail")
+	}
+	if !errors.Is(result.Error, ErrTimeout) {
+		t.Errorf("error = %v; want ErrTimeout", result.Error)
+	}
+	var perr *ProcessError
+	if errors.As(result.Error, &perr) && perr.Command == "" {
+		t.Error("timed-out error should record the command line")
+	}
+}
@@ -0,0 +1,254 @@
+package core
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UnlimitedDepth makes Walk descend into every subdirectory.
+const UnlimitedDepth = -1
+
+// SymlinkPolicy controls how Walk treats symbolic links.
+type SymlinkPolicy int
+
+const (
+	// SymlinksSkip ignores symbolic links entirely.
+	SymlinksSkip SymlinkPolicy = iota
+	// SymlinksFiles follows links to files but not to directories.
+	SymlinksFiles
+	// SymlinksFollow follows links to files and directories. Each real
+	// directory is visited once, so link cycles are safe.
+	SymlinksFollow
+)
+
+// WalkOptions filters the files Walk returns. The zero value lists every
+// non-hidden file directly inside the root.
+type WalkOptions struct {
+	// MaxDepth is how many directory levels below the root are entered:
+	// 0 lists only the root, UnlimitedDepth recurses fully.
+	MaxDepth int
+
+	// Include and Exclude are glob patterns matched against the path
+	// relative to the root, using "/" separators. "*" and "?" stay within
+	// one path segment and "**" matches any number of segments. A pattern
+	// without "/" matches the file name at any depth. Matching ignores
+	// case. Empty Include matches everything; a directory matching Exclude
+	// is not entered.
+	Include []string
+	Exclude []string
+
+	// IncludeImages and IncludePDFs restrict results to those types,
+	// detected from file content. If neither is set, every file matches.
+	IncludeImages bool
+	IncludePDFs   bool
+
+	// Hidden includes files and directories whose names start with ".".
+	Hidden bool
+
+	Symlinks SymlinkPolicy
+
+	// Size bounds in bytes and modification-time bounds; zero values
+	// don't filter.
+	MinSize        int64
+	MaxSize        int64
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+// WalkEntry is a file found by Walk.
+type WalkEntry struct {
+	Path    string // Full path
+	Rel     string // Path relative to the walk root
+	Size    int64
+	ModTime time.Time
+}
+
+// Walk lists the files under root that match opts, sorted by relative
+// path. Only an unreadable root is an error; unreadable subdirectories
+// are skipped.
+func Walk(root string, opts WalkOptions) ([]WalkEntry, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	w := walker{root: root, opts: opts, visited: map[string]bool{}}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		w.visited[real] = true
+	}
+	w.walkEntries(root, "", 0, entries)
+
+	sort.Slice(w.files, func(i, j int) bool {
+		return w.files[i].Rel < w.files[j].Rel
+	})
+	return w.files, nil
+}
+
+// walker holds the state of a single Walk.
+type walker struct {
+	root    string
+	opts    WalkOptions
+	visited map[string]bool // Real paths of directories already walked
+	files   []WalkEntry
+}
+
+// walkDir reads and walks a subdirectory, skipping it on error.
+func (w *walker) walkDir(dir, rel string, depth int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	w.walkEntries(dir, rel, depth, entries)
+}
+
+// walkEntries filters the entries of one directory and recurses.
+func (w *walker) walkEntries(dir, rel string, depth int, entries []os.DirEntry) {
+	for _, entry := range entries {
+		name := entry.Name()
+		if !w.opts.Hidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		// Partial outputs of running or crashed jobs are never inputs
+		if strings.HasPrefix(name, TempPrefix) {
+			continue
+		}
+
+		full := filepath.Join(dir, name)
+		relPath := path.Join(rel, name)
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if w.opts.Symlinks == SymlinksSkip {
+				continue
+			}
+			// Describe the link's target instead of the link
+			if info, err = os.Stat(full); err != nil {
+				continue
+			}
+			if info.IsDir() && w.opts.Symlinks != SymlinksFollow {
+				continue
+			}
+		}
+
+		if info.IsDir() {
+			if w.opts.MaxDepth != UnlimitedDepth && depth >= w.opts.MaxDepth {
+				continue
+			}
+			if matchesAny(w.opts.Exclude, relPath) {
+				continue
+			}
+			real, err := filepath.EvalSymlinks(full)
+			if err != nil || w.visited[real] {
+				continue
+			}
+			w.visited[real] = true
+			w.walkDir(full, relPath, depth+1)
+			continue
+		}
+
+		if !info.Mode().IsRegular() || !w.matchFile(full, relPath, info) {
+			continue
+		}
+		w.files = append(w.files, WalkEntry{
+			Path:    full,
+			Rel:     filepath.FromSlash(relPath),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+}
+
+// matchFile applies the type, glob, size and date filters to a file.
+func (w *walker) matchFile(full, rel string, info os.FileInfo) bool {
+	o := w.opts
+
+	if len(o.Include) > 0 && !matchesAny(o.Include, rel) {
+		return false
+	}
+	if matchesAny(o.Exclude, rel) {
+		return false
+	}
+
+	size := info.Size()
+	if (o.MinSize > 0 && size < o.MinSize) || (o.MaxSize > 0 && size > o.MaxSize) {
+		return false
+	}
+	mod := info.ModTime()
+	if (!o.ModifiedAfter.IsZero() && mod.Before(o.ModifiedAfter)) ||
+		(!o.ModifiedBefore.IsZero() && !mod.Before(o.ModifiedBefore)) {
+		return false
+	}
+
+	// Sniff content last, it's the only filter that opens the file
+	if o.IncludeImages || o.IncludePDFs {
+		t := DetectType(full)
+		if !(o.IncludeImages && t.IsImage()) && !(o.IncludePDFs && t == TypePDF) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether rel matches any of the glob patterns.
+func matchesAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob reports whether the slash-separated relative path matches
+// pattern, as described for WalkOptions.Include. Malformed patterns never
+// match.
+func MatchGlob(pattern, rel string) bool {
+	pattern = strings.ToLower(filepath.ToSlash(pattern))
+	rel = strings.ToLower(filepath.ToSlash(rel))
+
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches path segments, letting "**" consume zero or more.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], parts[0]); err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// MirrorPath returns where a walked file goes when the input tree is
+// mirrored under outputRoot, with its extension replaced by ext (without
+// the dot). An empty ext keeps the original extension.
+func MirrorPath(outputRoot string, entry WalkEntry, ext string) string {
+	rel := entry.Rel
+	if ext != "" {
+		rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + "." + ext
+	}
+	return filepath.Join(outputRoot, rel)
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// makeTree creates files (slash-separated, relative to root) with content.
+func makeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// walkRels returns the slash-separated relative paths Walk finds.
+func walkRels(t *testing.T, root string, opts WalkOptions) []string {
+	t.Helper()
+	entries, err := Walk(root, opts)
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	rels := []string{}
+	for _, e := range entries {
+		rels = append(rels, filepath.ToSlash(e.Rel))
+	}
+	return rels
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"*.png", "a.png", true},
+		{"*.png", "x/y/a.PNG", true},
+		{"*.png", "a.jpg", false},
+		{"icons/*.png", "icons/a.png", true},
+		{"icons/*.png", "icons/sub/a.png", false},
+		{"icons/**/*.png", "icons/a.png", true},
+		{"icons/**/*.png", "icons/sub/deep/a.png", true},
+		{"**/raw/**", "shoot/raw/a.cr2", true},
+		{"build", "a/build", true},
+		{"[", "a", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v; want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{
+		"a.png":              "x",
+		"doc.pdf":            "x",
+		"notes.txt":          "x",
+		".hidden.png":        "x",
+		"sub/b.jpg":          "xxxxxxxxxx",
+		"sub/deep/c.webp":    "x",
+		"sub/cache/d.png":    "x",
+		".git/objects/e.png": "x",
+		"thumbs/small_1.png": "x",
+	})
+
+	tests := []struct {
+		name string
+		opts WalkOptions
+		want []string
+	}{
+		{"top level only", WalkOptions{}, []string{"a.png", "doc.pdf", "notes.txt"}},
+		{"depth one", WalkOptions{MaxDepth: 1, IncludeImages: true}, []string{"a.png", "sub/b.jpg", "thumbs/small_1.png"}},
+		{"recursive images", WalkOptions{MaxDepth: UnlimitedDepth, IncludeImages: true},
+			[]string{"a.png", "sub/b.jpg", "sub/cache/d.png", "sub/deep/c.webp", "thumbs/small_1.png"}},
+		{"hidden", WalkOptions{MaxDepth: UnlimitedDepth, IncludeImages: true, Hidden: true, Exclude: []string{".git"}},
+			[]string{".hidden.png", "a.png", "sub/b.jpg", "sub/cache/d.png", "sub/deep/c.webp", "thumbs/small_1.png"}},
+		{"include and exclude", WalkOptions{MaxDepth: UnlimitedDepth, Include: []string{"sub/**"}, Exclude: []string{"cache"}},
+			[]string{"sub/b.jpg", "sub/deep/c.webp"}},
+		{"size", WalkOptions{MaxDepth: UnlimitedDepth, MinSize: 5}, []string{"sub/b.jpg"}},
+		{"pdf only", WalkOptions{MaxDepth: UnlimitedDepth, IncludePDFs: true}, []string{"doc.pdf"}},
+	}
+
+	for _, tt := range tests {
+		if got := walkRels(t, root, tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Walk = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWalkModifiedFilter(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, map[string]string{"old.png": "x", "new.png": "x"})
+
+	past := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(root, "old.png"), past, past)
+
+	got := walkRels(t, root, WalkOptions{ModifiedAfter: time.Now().Add(-24 * time.Hour)})
+	if !reflect.DeepEqual(got, []string{"new.png"}) {
+		t.Errorf("ModifiedAfter: Walk = %v; want [new.png]", got)
+	}
+	got = walkRels(t, root, WalkOptions{ModifiedBefore: time.Now().Add(-24 * time.Hour)})
+	if !reflect.DeepEqual(got, []string{"old.png"}) {
+		t.Errorf("ModifiedBefore: Walk = %v; want [old.png]", got)
+	}
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on Windows")
+	}
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	makeTree(t, root, map[string]string{"a.png": "x"})
+	makeTree(t, outside, map[string]string{"linked/b.png": "x", "c.png": "x"})
+
+	if err := os.Symlink(filepath.Join(outside, "linked"), filepath.Join(root, "dirlink")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "c.png"), filepath.Join(root, "filelink.png")); err != nil {
+		t.Fatal(err)
+	}
+	// A cycle back to the root must not loop forever
+	if err := os.Symlink(root, filepath.Join(root, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy SymlinkPolicy
+		want   []string
+	}{
+		{SymlinksSkip, []string{"a.png"}},
+		{SymlinksFiles, []string{"a.png", "filelink.png"}},
+		{SymlinksFollow, []string{"a.png", "dirlink/b.png", "filelink.png"}},
+	}
+	for _, tt := range tests {
+		got := walkRels(t, root, WalkOptions{MaxDepth: UnlimitedDepth, Symlinks: tt.policy})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("policy %d: Walk = %v; want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestMirrorOutputPath(t *testing.T) {
+	in := filepath.Join("assets", "icons", "app", "logo.png")
+	got, err := mirrorOutputPath("assets", "out", in, "webp")
+	if err != nil {
+		t.Fatalf("mirrorOutputPath failed: %v", err)
+	}
+	if want := filepath.Join("out", "icons", "app", "logo.webp"); got != want {
+		t.Errorf("mirrorOutputPath = %s; want %s", got, want)
+	}
+
+	if _, err := mirrorOutputPath("assets", "out", filepath.Join("other", "x.png"), "webp"); err == nil {
+		t.Error("expected an error for an input outside the root")
+	}
+}
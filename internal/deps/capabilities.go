@@ -0,0 +1,191 @@
+package deps
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// FormatSupport describes what ImageMagick can do with a single format.
+type FormatSupport struct {
+	Name        string
+	Read        bool
+	Write       bool
+	MultiFrame  bool
+	Description string
+}
+
+// Delegate is an external program ImageMagick runs for a conversion,
+// e.g. Ghostscript for reading PostScript and PDF.
+type Delegate struct {
+	Decode  string
+	Encode  string
+	Command string
+}
+
+// Capabilities is the format and delegate support reported by ImageMagick.
+type Capabilities struct {
+	Probed    bool // False if ImageMagick could not be queried
+	Formats   map[string]FormatSupport
+	Delegates []Delegate
+	Error     error
+}
+
+// formatAliases maps common extensions to the names ImageMagick lists.
+var formatAliases = map[string]string{
+	"JPG":  "JPEG",
+	"TIF":  "TIFF",
+	"HEIF": "HEIC",
+}
+
+// ProbeCapabilities queries ImageMagick for its supported formats and delegates.
+func ProbeCapabilities(magickPath string) Capabilities {
+	caps := Capabilities{Formats: map[string]FormatSupport{}}
+
+	output, err := exec.Command(magickPath, "-list", "format").Output()
+	if err != nil {
+		caps.Error = fmt.Errorf("failed to list formats: %w", err)
+		return caps
+	}
+	caps.Formats = parseFormatList(string(output))
+	if len(caps.Formats) == 0 {
+		caps.Error = fmt.Errorf("could not parse format list")
+		return caps
+	}
+
+	// Delegates are informational; a failure here doesn't invalidate formats
+	if output, err := exec.Command(magickPath, "-list", "delegate").Output(); err == nil {
+		caps.Delegates = parseDelegateList(string(output))
+	}
+
+	caps.Probed = true
+	return caps
+}
+
+// formatLineRe matches "-list format" rows. ImageMagick 7 prints a module
+// column that ImageMagick 6 lacks, e.g.:
+//
+//	AVIF  HEIC      rw+   AV1 Image File Format (1.0.9)
+//	JPEG* JPEG      rw-   Joint Photographic Experts Group JFIF format
+var formatLineRe = regexp.MustCompile(`^\s*([A-Za-z0-9_+-]+)\*?\s+(?:[A-Za-z0-9_+-]+\s+)?([r-])([w-])([+-])\s+(.*)$`)
+
+// parseFormatList parses the output of "magick -list format".
+func parseFormatList(output string) map[string]FormatSupport {
+	formats := map[string]FormatSupport{}
+	for _, line := range strings.Split(output, "\n") {
+		m := formatLineRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		name := strings.ToUpper(m[1])
+		formats[name] = FormatSupport{
+			Name:        name,
+			Read:        m[2] == "r",
+			Write:       m[3] == "w",
+			MultiFrame:  m[4] == "+",
+			Description: strings.TrimSpace(m[5]),
+		}
+	}
+	return formats
+}
+
+// delegateLineRe matches "-list delegate" rows such as
+//
+//	bpg =>          "bpgenc" -b 12 -o "%o" "%i"
+//	eps<=>pdf       "gs" -q -dQUIET ...
+var delegateLineRe = regexp.MustCompile(`^\s*([\w:.+-]*)\s*(<=>|=>)\s*([\w:.+-]*)\s+(.*)$`)
+
+// parseDelegateList parses the output of "magick -list delegate".
+func parseDelegateList(output string) []Delegate {
+	var delegates []Delegate
+	for _, line := range strings.Split(output, "\n") {
+		m := delegateLineRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		delegates = append(delegates, Delegate{
+			Decode:  strings.ToLower(m[1]),
+			Encode:  strings.ToLower(m[3]),
+			Command: strings.TrimSpace(m[4]),
+		})
+		if m[2] == "<=>" {
+			// Bidirectional delegates also convert the other way
+			delegates = append(delegates, Delegate{
+				Decode:  strings.ToLower(m[3]),
+				Encode:  strings.ToLower(m[1]),
+				Command: strings.TrimSpace(m[4]),
+			})
+		}
+	}
+	return delegates
+}
+
+// lookup finds a format by name or common alias.
+func (c Capabilities) lookup(format string) (FormatSupport, bool) {
+	name := strings.ToUpper(strings.TrimPrefix(format, "."))
+	if fs, ok := c.Formats[name]; ok {
+		return fs, true
+	}
+	if alias, ok := formatAliases[name]; ok {
+		fs, ok := c.Formats[alias]
+		return fs, ok
+	}
+	return FormatSupport{}, false
+}
+
+// CanRead reports whether ImageMagick can read the format. Formats are
+// assumed readable when capabilities could not be probed.
+func (c Capabilities) CanRead(format string) bool {
+	if !c.Probed {
+		return true
+	}
+	fs, ok := c.lookup(format)
+	return ok && fs.Read
+}
+
+// CanWrite reports whether ImageMagick can write the format. Formats are
+// assumed writable when capabilities could not be probed.
+func (c Capabilities) CanWrite(format string) bool {
+	if !c.Probed {
+		return true
+	}
+	fs, ok := c.lookup(format)
+	return ok && fs.Write
+}
+
+// HasDecodeDelegate reports whether a delegate decodes the given input,
+// matching either the full name ("ps:alpha") or its prefix ("ps").
+func (c Capabilities) HasDecodeDelegate(name string) bool {
+	name = strings.ToLower(name)
+	for _, d := range c.Delegates {
+		if d.Decode == name || strings.HasPrefix(d.Decode, name+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// CanReadPDF reports whether PDFs can be rasterised, which requires
+// ImageMagick's PDF coder plus a working Ghostscript delegate. The returned
+// string explains why not.
+func (r CheckResult) CanReadPDF() (bool, string) {
+	if r.Ghostscript.Status != StatusOK {
+		return false, "Ghostscript is not available, so PDFs cannot be read"
+	}
+	if r.Policy.PDFBlocked() {
+		return false, "PDF reading is blocked by ImageMagick's security policy (policy.xml)"
+	}
+	caps := r.Capabilities
+	if !caps.Probed {
+		return true, ""
+	}
+	if !caps.CanRead("PDF") {
+		return false, "This ImageMagick build cannot read PDF files"
+	}
+	// ImageMagick renders PDF through its PostScript (ps:*) Ghostscript delegates
+	if len(caps.Delegates) > 0 && !caps.HasDecodeDelegate("ps") && !caps.HasDecodeDelegate("pdf") {
+		return false, "ImageMagick has no Ghostscript delegate configured for PDF"
+	}
+	return true, ""
+}
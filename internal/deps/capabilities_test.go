@@ -0,0 +1,139 @@
+package deps
+
+import (
+	"testing"
+)
+
+const sampleFormatListIM7 = `   Format  Module    Mode  Description
+-------------------------------------------------------------------------------
+      3FR  DNG       r--   Hasselblad CFV/H3D39II Raw Format (0.21.1-Release)
+     AVIF  HEIC      r--   AV1 Image File Format (1.12.0)
+     HEIC  HEIC      rw+   High Efficiency Image Format (1.12.0)
+     JPEG* JPEG      rw-   Joint Photographic Experts Group JFIF format (80)
+      JPG* JPEG      rw-   Joint Photographic Experts Group JFIF format (80)
+      PDF* PDF       rw+   Portable Document Format
+      PNG* PNG       rw-   Portable Network Graphics (libpng 1.6.40)
+           See http://www.libpng.org/ for details about the PNG format.
+
+* native blob support
+r read support
+w write support
++ support for multiple images
+`
+
+const sampleFormatListIM6 = `   Format  Mode  Description
+-------------------------------------------------------------------------------
+     TIFF* rw+   Tagged Image File Format (LIBTIFF, Version 4.2.0)
+     WEBP* rw-   WebP Image Format (libwebp 0.6.1[0208])
+`
+
+func TestParseFormatList(t *testing.T) {
+	formats := parseFormatList(sampleFormatListIM7)
+
+	tests := []struct {
+		name   string
+		read   bool
+		write  bool
+		exists bool
+	}{
+		{"AVIF", true, false, true},
+		{"HEIC", true, true, true},
+		{"JPEG", true, true, true},
+		{"PDF", true, true, true},
+		{"3FR", true, false, true},
+		{"WEBP", false, false, false},
+	}
+
+	for _, tt := range tests {
+		fs, ok := formats[tt.name]
+		if ok != tt.exists {
+			t.Errorf("format %s exists = %v; want %v", tt.name, ok, tt.exists)
+			continue
+		}
+		if fs.Read != tt.read || fs.Write != tt.write {
+			t.Errorf("format %s read/write = %v/%v; want %v/%v", tt.name, fs.Read, fs.Write, tt.read, tt.write)
+		}
+	}
+
+	im6 := parseFormatList(sampleFormatListIM6)
+	if fs, ok := im6["WEBP"]; !ok || !fs.Write {
+		t.Errorf("IM6 WEBP = %+v, %v; want writable", fs, ok)
+	}
+	if fs := im6["TIFF"]; !fs.MultiFrame {
+		t.Error("IM6 TIFF should support multiple images")
+	}
+}
+
+func TestParseDelegateList(t *testing.T) {
+	output := `Path: /etc/ImageMagick-7/delegates.xml
+
+Delegate                Command
+-------------------------------------------------------------------------------
+        bpg =>          "bpgenc" -b 12 -o "%o" "%i"
+   ps:alpha =>          "gs" -sstdout=%%stderr -dQUIET -dSAFER -dBATCH
+     eps<=>pdf          "gs" -sstdout=%%stderr -dQUIET -dSAFER
+`
+	delegates := parseDelegateList(output)
+	if len(delegates) != 4 {
+		t.Fatalf("parseDelegateList() returned %d delegates; want 4", len(delegates))
+	}
+
+	caps := Capabilities{Probed: true, Delegates: delegates}
+	if !caps.HasDecodeDelegate("ps") {
+		t.Error("expected ps:* decode delegate")
+	}
+	if !caps.HasDecodeDelegate("pdf") {
+		t.Error("expected pdf decode delegate from eps<=>pdf")
+	}
+	if caps.HasDecodeDelegate("svg") {
+		t.Error("unexpected svg decode delegate")
+	}
+}
+
+func TestCapabilitiesCanWrite(t *testing.T) {
+	caps := Capabilities{Probed: true, Formats: parseFormatList(sampleFormatListIM7)}
+
+	tests := []struct {
+		format string
+		want   bool
+	}{
+		{"jpg", true},
+		{".png", true},
+		{"avif", false},
+		{"webp", false},
+		{"heif", true},
+	}
+	for _, tt := range tests {
+		if got := caps.CanWrite(tt.format); got != tt.want {
+			t.Errorf("CanWrite(%q) = %v; want %v", tt.format, got, tt.want)
+		}
+	}
+
+	// Unprobed capabilities must not block anything
+	if !(Capabilities{}).CanWrite("avif") {
+		t.Error("unprobed capabilities should allow all formats")
+	}
+}
+
+func TestCanReadPDF(t *testing.T) {
+	caps := Capabilities{
+		Probed:    true,
+		Formats:   parseFormatList(sampleFormatListIM7),
+		Delegates: []Delegate{{Decode: "ps:alpha", Command: "gs"}},
+	}
+
+	ok, _ := CheckResult{Ghostscript: Dependency{Status: StatusOK}, Capabilities: caps}.CanReadPDF()
+	if !ok {
+		t.Error("CanReadPDF() = false; want true with Ghostscript and ps delegate")
+	}
+
+	ok, reason := CheckResult{Ghostscript: Dependency{Status: StatusNotFound}, Capabilities: caps}.CanReadPDF()
+	if ok || reason == "" {
+		t.Error("CanReadPDF() should fail with a reason when Ghostscript is missing")
+	}
+
+	caps.Delegates = []Delegate{{Decode: "bpg", Command: "bpgenc"}}
+	if ok, _ := (CheckResult{Ghostscript: Dependency{Status: StatusOK}, Capabilities: caps}).CanReadPDF(); ok {
+		t.Error("CanReadPDF() = true; want false without a Ghostscript delegate")
+	}
+}
@@ -5,9 +5,13 @@ package deps
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 )
 
 // Status represents the availability status of a dependency.
@@ -31,29 +35,166 @@ type Dependency struct {
 	Status      Status
 	Version     string
 	Error       error
+	Warnings    []string // Non-fatal issues, e.g. reduced capabilities
 	DownloadURL string
 	Description string
 }
 
 // CheckResult contains the results of all dependency checks.
 type CheckResult struct {
-	ImageMagick Dependency
-	Ghostscript Dependency
-	AllOK       bool
+	ImageMagick  Dependency
+	Ghostscript  Dependency
+	Capabilities Capabilities
+	Policy       SecurityPolicy
+	AllOK        bool
 }
 
-// Check verifies all required dependencies are available.
+// legacyImageMagickMinVersion is the oldest ImageMagick 6 accepted through
+// the "convert" fallback.
+const legacyImageMagickMinVersion = "6.9.0"
+
+// lookPath resolves a command name to an executable path. It is a variable
+// so tests can substitute a fake PATH lookup.
+var lookPath = exec.LookPath
+
+// resolved holds the commands found by the most recent Check, so callers
+// don't have to probe the system again.
+var resolved struct {
+	mu          sync.RWMutex
+	imageMagick string
+	ghostscript string
+}
+
+// Options configures dependency detection.
+type Options struct {
+	// MagickPath is an explicit ImageMagick executable; empty searches PATH.
+	MagickPath string
+	// GhostscriptPath is an explicit Ghostscript executable; empty searches PATH.
+	GhostscriptPath string
+}
+
+// Check verifies all required dependencies are available in PATH.
 func Check() CheckResult {
+	return CheckWith(Options{})
+}
+
+// CheckWith verifies all required dependencies, using explicit executable
+// paths from opts where given instead of searching PATH.
+func CheckWith(opts Options) CheckResult {
 	result := CheckResult{
-		ImageMagick: checkImageMagick(),
-		Ghostscript: checkGhostscript(),
+		ImageMagick: checkImageMagick(opts.MagickPath),
+		Ghostscript: checkGhostscript(opts.GhostscriptPath),
 	}
 	result.AllOK = result.ImageMagick.Status == StatusOK && result.Ghostscript.Status == StatusOK
+
+	if result.ImageMagick.Status == StatusOK {
+		result.Capabilities = ProbeCapabilities(result.ImageMagick.Command)
+		result.Policy = ProbeSecurityPolicy(result.ImageMagick.Command)
+
+		if result.Policy.PDFBlocked() {
+			where := "ImageMagick's security policy"
+			if file := result.Policy.PolicyFile(); file != "" {
+				where = file
+			}
+			result.ImageMagick.Warnings = append(result.ImageMagick.Warnings,
+				fmt.Sprintf("PDF reading is blocked by %s; PDF conversion is disabled until the PDF coder policy allows read.", where))
+		}
+	}
+
+	resolved.mu.Lock()
+	resolved.imageMagick = ""
+	if result.ImageMagick.Status == StatusOK {
+		resolved.imageMagick = result.ImageMagick.Command
+	}
+	resolved.ghostscript = ""
+	if result.Ghostscript.Status == StatusOK {
+		resolved.ghostscript = result.Ghostscript.Command
+	}
+	resolved.mu.Unlock()
+
 	return result
 }
 
+// HasWarnings reports whether any dependency has non-fatal warnings.
+func (r CheckResult) HasWarnings() bool {
+	return len(r.ImageMagick.Warnings) > 0 || len(r.Ghostscript.Warnings) > 0
+}
+
+// imageMagickCandidates lists the commands probed for ImageMagick, in order.
+// ImageMagick 7 ships a single "magick" binary; ImageMagick 6 only provides
+// "convert". On Windows "convert" is the unrelated FAT-to-NTFS system tool,
+// so the legacy fallback is not attempted there.
+func imageMagickCandidates() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"magick"}
+	}
+	return []string{"magick", "convert"}
+}
+
+// ghostscriptCandidates lists the commands probed for Ghostscript, in order.
+func ghostscriptCandidates() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"gswin64c", "gswin32c", "gs"}
+	}
+	return []string{"gs", "gswin64c", "gswin32c"}
+}
+
+// ValidatePath checks that path points to an existing executable file.
+func ValidatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("path is empty")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("file not found: %s", path)
+		}
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory, not an executable", path)
+	}
+	// LookPath on a path with a separator only checks that it is executable
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
+
+// commandName returns the lowercase base name of an executable without extension.
+func commandName(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	return strings.TrimSuffix(base, strings.ToLower(filepath.Ext(base)))
+}
+
+// resolveCommand returns the explicit path if set and valid, otherwise the
+// first candidate found in PATH.
+func resolveCommand(explicit string, candidates []string) (name, path string, err error) {
+	if explicit != "" {
+		if err := ValidatePath(explicit); err != nil {
+			return "", "", fmt.Errorf("configured path is not usable: %w", err)
+		}
+		return commandName(explicit), explicit, nil
+	}
+	name, path, ok := findCommand(candidates)
+	if !ok {
+		return "", "", fmt.Errorf("not found in PATH")
+	}
+	return name, path, nil
+}
+
+// findCommand returns the path of the first candidate found in PATH.
+func findCommand(candidates []string) (name, path string, ok bool) {
+	for _, c := range candidates {
+		if p, err := lookPath(c); err == nil {
+			return c, p, true
+		}
+	}
+	return "", "", false
+}
+
 // checkImageMagick verifies ImageMagick is installed and meets minimum version.
-func checkImageMagick() Dependency {
+func checkImageMagick(explicitPath string) Dependency {
 	dep := Dependency{
 		Name:        "ImageMagick",
 		Command:     "magick",
@@ -63,16 +204,17 @@ func checkImageMagick() Dependency {
 		Description: "Required for image format conversion and compression",
 	}
 
-	// Check if magick command exists
-	cmd := exec.Command("where", "magick")
-	if err := cmd.Run(); err != nil {
+	name, path, err := resolveCommand(explicitPath, imageMagickCandidates())
+	if err != nil {
 		dep.Status = StatusNotFound
-		dep.Error = fmt.Errorf("ImageMagick not found in PATH")
+		dep.Error = fmt.Errorf("ImageMagick %w", err)
 		return dep
 	}
+	dep.Command = path
+	legacy := name == "convert"
 
 	// Get version
-	cmd = exec.Command(dep.Command, dep.VersionArgs...)
+	cmd := exec.Command(dep.Command, dep.VersionArgs...)
 	output, err := cmd.Output()
 	if err != nil {
 		dep.Status = StatusVersionError
@@ -90,10 +232,15 @@ func checkImageMagick() Dependency {
 		return dep
 	}
 
-	// Check minimum version (must be 7.x)
-	if !strings.HasPrefix(version, "7.") {
-		dep.Status = StatusVersionError
-		dep.Error = fmt.Errorf("version %s is below minimum required %s", version, dep.MinVersion)
+	// ImageMagick 6 is accepted through the legacy "convert" entry point,
+	// but newer formats and options may be unavailable.
+	if legacy && strings.HasPrefix(version, "6.") {
+		dep.MinVersion = legacyImageMagickMinVersion
+		dep.Warnings = append(dep.Warnings,
+			fmt.Sprintf("Using legacy ImageMagick %s via 'convert'; some formats (e.g. AVIF) may be unavailable. ImageMagick 7 is recommended.", version))
+	}
+
+	if !checkMinimumVersion(&dep) {
 		return dep
 	}
 
@@ -102,52 +249,77 @@ func checkImageMagick() Dependency {
 }
 
 // checkGhostscript verifies Ghostscript is installed.
-func checkGhostscript() Dependency {
+func checkGhostscript(explicitPath string) Dependency {
 	dep := Dependency{
 		Name:        "Ghostscript",
-		Command:     "gswin64c",
+		Command:     "gs",
 		VersionArgs: []string{"-version"},
-		MinVersion:  "",
+		MinVersion:  "9.50",
 		DownloadURL: "https://ghostscript.com/releases/gsdnld.html",
 		Description: "Required for PDF processing and conversion",
 	}
 
-	// Try 64-bit version first
-	cmd := exec.Command("where", "gswin64c")
-	if err := cmd.Run(); err != nil {
-		// Try 32-bit version
-		dep.Command = "gswin32c"
-		cmd = exec.Command("where", "gswin32c")
-		if err := cmd.Run(); err != nil {
-			dep.Status = StatusNotFound
-			dep.Error = fmt.Errorf("Ghostscript not found in PATH")
-			return dep
+	_, path, err := resolveCommand(explicitPath, ghostscriptCandidates())
+	if err != nil {
+		dep.Status = StatusNotFound
+		dep.Error = fmt.Errorf("Ghostscript %w", err)
+		return dep
+	}
+	dep.Command = path
+
+	// Get version. "-version" prints a banner; "--version" prints only the
+	// number and is tried when the banner can't be parsed.
+	var version string
+	var lastErr error
+	for _, args := range [][]string{dep.VersionArgs, {"--version"}} {
+		output, err := exec.Command(dep.Command, args...).Output()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if version = parseGhostscriptVersion(string(output)); version != "" {
+			break
 		}
 	}
 
-	// Get version
-	cmd = exec.Command(dep.Command, dep.VersionArgs...)
-	output, err := cmd.Output()
-	if err != nil {
-		// Ghostscript might output version to stderr or have different behavior
-		// If command exists in PATH, we consider it OK
-		dep.Status = StatusOK
-		dep.Version = "detected"
+	if version == "" {
+		dep.Status = StatusVersionError
+		if lastErr != nil {
+			dep.Error = fmt.Errorf("failed to get version: %w", lastErr)
+		} else {
+			dep.Error = fmt.Errorf("could not parse version from output")
+		}
 		return dep
 	}
+	dep.Version = version
 
-	// Parse version from output
-	version := parseGhostscriptVersion(string(output))
-	if version != "" {
-		dep.Version = version
-	} else {
-		dep.Version = "detected"
+	if !checkMinimumVersion(&dep) {
+		return dep
 	}
 
 	dep.Status = StatusOK
 	return dep
 }
 
+// checkMinimumVersion enforces dep.MinVersion and records known-bad version
+// warnings. It returns false and sets the error status if the check fails.
+func checkMinimumVersion(dep *Dependency) bool {
+	ok, err := versionAtLeast(dep.Version, dep.MinVersion)
+	if err != nil {
+		dep.Status = StatusVersionError
+		dep.Error = fmt.Errorf("could not compare version %s: %w", dep.Version, err)
+		return false
+	}
+	if !ok {
+		dep.Status = StatusVersionError
+		dep.Error = fmt.Errorf("version %s is below minimum required %s", dep.Version, dep.MinVersion)
+		return false
+	}
+
+	dep.Warnings = append(dep.Warnings, knownIssueWarnings(dep.Name, dep.Version)...)
+	return true
+}
+
 // parseImageMagickVersion extracts version number from ImageMagick output.
 func parseImageMagickVersion(output string) string {
 	// Match "Version: ImageMagick 7.1.0-62" pattern
@@ -167,6 +339,12 @@ func parseGhostscriptVersion(output string) string {
 	if len(matches) >= 2 {
 		return matches[1]
 	}
+	// "gs --version" prints only the number
+	re = regexp.MustCompile(`^\s*(\d+\.\d+(?:\.\d+)?)\s*$`)
+	matches = re.FindStringSubmatch(output)
+	if len(matches) >= 2 {
+		return matches[1]
+	}
 	return ""
 }
 
@@ -227,13 +405,21 @@ func formatMissingDependency(dep Dependency) string {
 	return b.String()
 }
 
-// GetGhostscriptCommand returns the correct Ghostscript command for the system.
+// GetGhostscriptCommand returns the Ghostscript executable resolved by the
+// last Check, or an empty string if Ghostscript was not found.
 func GetGhostscriptCommand() string {
-	// Try 64-bit first
-	cmd := exec.Command("where", "gswin64c")
-	if cmd.Run() == nil {
-		return "gswin64c"
+	resolved.mu.RLock()
+	defer resolved.mu.RUnlock()
+	return resolved.ghostscript
+}
+
+// GetImageMagickCommand returns the ImageMagick executable resolved by the
+// last Check, falling back to "magick" if Check has not found one.
+func GetImageMagickCommand() string {
+	resolved.mu.RLock()
+	defer resolved.mu.RUnlock()
+	if resolved.imageMagick == "" {
+		return "magick"
 	}
-	// Fall back to 32-bit
-	return "gswin32c"
+	return resolved.imageMagick
 }
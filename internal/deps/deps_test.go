@@ -1,6 +1,10 @@
 package deps
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -92,3 +96,112 @@ func TestDependencyFormatStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestFindCommandOrder(t *testing.T) {
+	orig := lookPath
+	defer func() { lookPath = orig }()
+
+	available := map[string]string{
+		"gswin32c": "/bin/gswin32c",
+		"gs":       "/usr/bin/gs",
+	}
+	lookPath = func(name string) (string, error) {
+		if p, ok := available[name]; ok {
+			return p, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	name, path, ok := findCommand([]string{"gswin64c", "gswin32c", "gs"})
+	if !ok || name != "gswin32c" || path != "/bin/gswin32c" {
+		t.Errorf("findCommand() = %q, %q, %v; want gswin32c, /bin/gswin32c, true", name, path, ok)
+	}
+
+	if _, _, ok := findCommand([]string{"magick"}); ok {
+		t.Error("findCommand() found a command that does not exist")
+	}
+}
+
+func TestGhostscriptCandidates(t *testing.T) {
+	candidates := ghostscriptCandidates()
+	want := map[string]bool{"gs": false, "gswin64c": false, "gswin32c": false}
+	for _, c := range candidates {
+		want[c] = true
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("ghostscriptCandidates() missing %q", name)
+		}
+	}
+}
+
+func TestCheckResolvesGhostscript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fixtures are not executable on Windows")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'GPL Ghostscript 10.02.1 (2023-11-01)'\n"
+	if err := os.WriteFile(filepath.Join(dir, "gs"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake gs: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	result := Check()
+	if result.Ghostscript.Status != StatusOK {
+		t.Fatalf("Ghostscript status = %v (%v); want StatusOK", result.Ghostscript.Status, result.Ghostscript.Error)
+	}
+	if result.Ghostscript.Version != "10.02.1" {
+		t.Errorf("Ghostscript version = %q; want 10.02.1", result.Ghostscript.Version)
+	}
+	if got := GetGhostscriptCommand(); got != filepath.Join(dir, "gs") {
+		t.Errorf("GetGhostscriptCommand() = %q; want %q", got, filepath.Join(dir, "gs"))
+	}
+	if result.ImageMagick.Status != StatusNotFound {
+		t.Errorf("ImageMagick status = %v; want StatusNotFound", result.ImageMagick.Status)
+	}
+}
+
+func TestCheckWithExplicitPaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script fixtures are not executable on Windows")
+	}
+
+	dir := t.TempDir()
+	gsPath := filepath.Join(dir, "portable-gs")
+	script := "#!/bin/sh\necho 'GPL Ghostscript 10.03.1 (2024-05-02)'\n"
+	if err := os.WriteFile(gsPath, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake gs: %v", err)
+	}
+	t.Setenv("PATH", t.TempDir())
+
+	result := CheckWith(Options{
+		MagickPath:      filepath.Join(dir, "missing-magick"),
+		GhostscriptPath: gsPath,
+	})
+	if result.Ghostscript.Status != StatusOK || result.Ghostscript.Command != gsPath {
+		t.Errorf("Ghostscript = %v %q; want StatusOK %q", result.Ghostscript.Status, result.Ghostscript.Command, gsPath)
+	}
+	if result.ImageMagick.Status != StatusNotFound || result.ImageMagick.Error == nil {
+		t.Errorf("ImageMagick status = %v; want StatusNotFound with error", result.ImageMagick.Status)
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	dir := t.TempDir()
+	if err := ValidatePath(""); err == nil {
+		t.Error("ValidatePath(\"\") should fail")
+	}
+	if err := ValidatePath(dir); err == nil {
+		t.Error("ValidatePath(dir) should fail for a directory")
+	}
+	if err := ValidatePath(filepath.Join(dir, "nope")); err == nil {
+		t.Error("ValidatePath() should fail for a missing file")
+	}
+}
+
+func TestParseGhostscriptBareVersion(t *testing.T) {
+	if got := parseGhostscriptVersion("10.03.1\n"); got != "10.03.1" {
+		t.Errorf("parseGhostscriptVersion(bare) = %q; want 10.03.1", got)
+	}
+}
@@ -0,0 +1,173 @@
+package deps
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Policy is one rule from ImageMagick's active security policy (policy.xml).
+type Policy struct {
+	Domain  string // Coder, Delegate, Path, Resource, System, Module
+	Name    string
+	Rights  string
+	Pattern string
+	Value   string
+}
+
+// SecurityPolicy is the active ImageMagick policy and resource limits.
+type SecurityPolicy struct {
+	Probed    bool
+	Paths     []string // Policy files in effect
+	Policies  []Policy
+	Resources map[string]string // Lowercase resource name to limit, e.g. "memory": "256MiB"
+}
+
+// ProbeSecurityPolicy reads the active policy ("-list policy") and resource
+// limits ("-list resource") from ImageMagick.
+func ProbeSecurityPolicy(magickPath string) SecurityPolicy {
+	policy := SecurityPolicy{Resources: map[string]string{}}
+
+	output, err := exec.Command(magickPath, "-list", "policy").Output()
+	if err != nil {
+		return policy
+	}
+	policy.Paths, policy.Policies = parsePolicyList(string(output))
+
+	if output, err := exec.Command(magickPath, "-list", "resource").Output(); err == nil {
+		policy.Resources = parseResourceList(string(output))
+	}
+
+	policy.Probed = true
+	return policy
+}
+
+// parsePolicyList parses "-list policy" output such as
+//
+//	Path: /etc/ImageMagick-6/policy.xml
+//	  Policy: Coder
+//	    rights: None
+//	    pattern: PDF
+func parsePolicyList(output string) ([]string, []Policy) {
+	var paths []string
+	var policies []Policy
+	var current *Policy
+
+	flush := func() {
+		if current != nil {
+			policies = append(policies, *current)
+			current = nil
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(key) {
+		case "path":
+			flush()
+			paths = append(paths, value)
+		case "policy":
+			flush()
+			current = &Policy{Domain: value}
+		case "name":
+			if current != nil {
+				current.Name = value
+			}
+		case "rights":
+			if current != nil {
+				current.Rights = value
+			}
+		case "pattern":
+			if current != nil {
+				current.Pattern = value
+			}
+		case "value":
+			if current != nil {
+				current.Value = value
+			}
+		}
+	}
+	flush()
+
+	return paths, policies
+}
+
+// parseResourceList parses "-list resource" output such as
+//
+//	Resource limits:
+//	  Memory: 256MiB
+//	  List length: unlimited
+func parseResourceList(output string) map[string]string {
+	resources := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			continue
+		}
+		resources[strings.ToLower(key)] = value
+	}
+	return resources
+}
+
+// braceRe matches a single {a,b,c} alternation in a policy pattern.
+var braceRe = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// policyPatternMatches reports whether an ImageMagick policy pattern such
+// as "PDF", "*" or "{PS,PDF,XPS}" matches name. Matching is case-insensitive.
+func policyPatternMatches(pattern, name string) bool {
+	pattern = strings.ToUpper(pattern)
+	name = strings.ToUpper(name)
+
+	if loc := braceRe.FindStringSubmatchIndex(pattern); loc != nil {
+		for _, alt := range strings.Split(pattern[loc[2]:loc[3]], ",") {
+			if policyPatternMatches(pattern[:loc[0]]+alt+pattern[loc[1]:], name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ok, err := filepath.Match(pattern, name)
+	return err == nil && ok
+}
+
+// Allows reports whether the policy grants a right ("read", "write",
+// "execute") for a name in a domain ("coder", "delegate", ...). As in
+// ImageMagick, later matching rules override earlier ones.
+func (p SecurityPolicy) Allows(domain, name, right string) bool {
+	allowed := true
+	for _, rule := range p.Policies {
+		if !strings.EqualFold(rule.Domain, domain) || rule.Pattern == "" {
+			continue
+		}
+		if !policyPatternMatches(rule.Pattern, name) {
+			continue
+		}
+		rights := strings.ToLower(rule.Rights)
+		allowed = strings.Contains(rights, strings.ToLower(right)) || strings.Contains(rights, "all")
+	}
+	return allowed
+}
+
+// PDFBlocked reports whether the policy forbids reading PDF files.
+func (p SecurityPolicy) PDFBlocked() bool {
+	return !p.Allows("coder", "PDF", "read")
+}
+
+// PolicyFile returns the first policy.xml path in effect, if any.
+func (p SecurityPolicy) PolicyFile() string {
+	for _, path := range p.Paths {
+		if !strings.HasPrefix(path, "[") {
+			return path
+		}
+	}
+	return ""
+}
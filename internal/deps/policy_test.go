@@ -0,0 +1,92 @@
+package deps
+
+import (
+	"testing"
+)
+
+const samplePolicyList = `Path: /etc/ImageMagick-6/policy.xml
+  Policy: Resource
+    name: disk
+    value: 1GiB
+  Policy: Coder
+    rights: None
+    pattern: {PS,PS2,PS3,EPS,PDF,XPS}
+Path: [built-in]
+  Policy: Undefined
+    rights: None
+`
+
+func TestParsePolicyList(t *testing.T) {
+	paths, policies := parsePolicyList(samplePolicyList)
+
+	if len(paths) != 2 || paths[0] != "/etc/ImageMagick-6/policy.xml" {
+		t.Errorf("paths = %v; want policy.xml and [built-in]", paths)
+	}
+	if len(policies) != 3 {
+		t.Fatalf("len(policies) = %d; want 3", len(policies))
+	}
+	if policies[0].Domain != "Resource" || policies[0].Name != "disk" || policies[0].Value != "1GiB" {
+		t.Errorf("policies[0] = %+v; want disk resource of 1GiB", policies[0])
+	}
+	if policies[1].Pattern != "{PS,PS2,PS3,EPS,PDF,XPS}" || policies[1].Rights != "None" {
+		t.Errorf("policies[1] = %+v; want coder rule denying PDF", policies[1])
+	}
+}
+
+func TestPolicyPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"PDF", "PDF", true},
+		{"pdf", "PDF", true},
+		{"*", "PDF", true},
+		{"{PS,PDF,XPS}", "PDF", true},
+		{"{PS,EPS}", "PDF", false},
+		{"P*", "PNG", true},
+		{"JPEG", "PDF", false},
+	}
+
+	for _, tt := range tests {
+		if got := policyPatternMatches(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("policyPatternMatches(%q, %q) = %v; want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSecurityPolicyPDFBlocked(t *testing.T) {
+	_, policies := parsePolicyList(samplePolicyList)
+	if !(SecurityPolicy{Policies: policies}).PDFBlocked() {
+		t.Error("PDFBlocked() = false; want true for rights None on PDF")
+	}
+
+	// A later rule granting read overrides the earlier denial
+	policies = append(policies, Policy{Domain: "coder", Rights: "read|write", Pattern: "PDF"})
+	if (SecurityPolicy{Policies: policies}).PDFBlocked() {
+		t.Error("PDFBlocked() = true; want false after read is granted")
+	}
+
+	if (SecurityPolicy{}).PDFBlocked() {
+		t.Error("empty policy should not block PDF")
+	}
+}
+
+func TestParseResourceList(t *testing.T) {
+	output := `Resource limits:
+  Width: 16KP
+  List length: unlimited
+  Memory: 256MiB
+  Time: unlimited
+`
+	resources := parseResourceList(output)
+	if resources["memory"] != "256MiB" {
+		t.Errorf("memory = %q; want 256MiB", resources["memory"])
+	}
+	if resources["list length"] != "unlimited" {
+		t.Errorf("list length = %q; want unlimited", resources["list length"])
+	}
+	if _, ok := resources["resource limits"]; ok {
+		t.Error("header line should not be parsed as a resource")
+	}
+}
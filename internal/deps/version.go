@@ -0,0 +1,182 @@
+package deps
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed dependency version. ImageMagick appends a patch level
+// after a dash ("7.1.0-62"), which is kept as Release.
+type Version struct {
+	Major   int
+	Minor   int
+	Patch   int
+	Release int
+}
+
+// versionRe matches "7", "7.1", "10.02.1" and "7.1.0-62" style versions.
+var versionRe = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-(\d+))?$`)
+
+// ParseVersion parses a version string. Leading zeros are ignored, so
+// Ghostscript's "10.02.1" equals "10.2.1".
+func ParseVersion(s string) (Version, error) {
+	m := versionRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+
+	parts := make([]int, 4)
+	for i, p := range m[1:] {
+		if p == "" {
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		parts[i] = n
+	}
+
+	return Version{Major: parts[0], Minor: parts[1], Patch: parts[2], Release: parts[3]}, nil
+}
+
+// Compare returns -1, 0 or 1 if v is lower than, equal to or higher than o.
+func (v Version) Compare(o Version) int {
+	a := []int{v.Major, v.Minor, v.Patch, v.Release}
+	b := []int{o.Major, o.Minor, o.Patch, o.Release}
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// String formats the version, including the release only when set.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Release > 0 {
+		s += fmt.Sprintf("-%d", v.Release)
+	}
+	return s
+}
+
+// CompareVersions compares two version strings like Version.Compare.
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// versionAtLeast reports whether version >= min. An empty min always passes.
+func versionAtLeast(version, min string) (bool, error) {
+	if min == "" {
+		return true, nil
+	}
+	c, err := CompareVersions(version, min)
+	if err != nil {
+		return false, err
+	}
+	return c >= 0, nil
+}
+
+// FeatureRequirement is the minimum dependency version a feature needs.
+type FeatureRequirement struct {
+	Feature    string
+	Dependency string
+	MinVersion string
+}
+
+// featureRequirements lists features that need more than the base minimum.
+var featureRequirements = []FeatureRequirement{
+	{Feature: "avif", Dependency: "ImageMagick", MinVersion: "7.0.10"},
+	{Feature: "heic", Dependency: "ImageMagick", MinVersion: "7.0.7-22"},
+}
+
+// knownIssue flags dependency versions with published vulnerabilities.
+// Versions in [Since, FixedIn) are affected; an empty Since means all
+// versions below FixedIn.
+type knownIssue struct {
+	Dependency string
+	Since      string
+	FixedIn    string
+	Advisory   string
+}
+
+var knownIssues = []knownIssue{
+	{Dependency: "ImageMagick", Since: "6.0.0", FixedIn: "6.9.3-10", Advisory: "CVE-2016-3714 (ImageTragick): remote command execution via crafted images"},
+	{Dependency: "ImageMagick", Since: "7.0.0", FixedIn: "7.0.1-1", Advisory: "CVE-2016-3714 (ImageTragick): remote command execution via crafted images"},
+	{Dependency: "Ghostscript", FixedIn: "10.01.2", Advisory: "CVE-2023-36664: command execution via crafted PDF/EPS pipe paths"},
+	{Dependency: "Ghostscript", FixedIn: "10.03.1", Advisory: "CVE-2024-29510: -dSAFER sandbox bypass via format string injection"},
+}
+
+// knownIssueWarnings returns warnings for advisories affecting the version.
+func knownIssueWarnings(name, version string) []string {
+	var warnings []string
+	for _, issue := range knownIssues {
+		if issue.Dependency != name {
+			continue
+		}
+		if issue.Since != "" {
+			if ok, err := versionAtLeast(version, issue.Since); err != nil || !ok {
+				continue
+			}
+		}
+		if ok, err := versionAtLeast(version, issue.FixedIn); err != nil || ok {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("%s %s is affected by %s. Upgrade to %s or later.",
+			name, version, issue.Advisory, issue.FixedIn))
+	}
+	return warnings
+}
+
+// SupportsFeature reports whether the detected dependency versions meet a
+// feature's minimum, e.g. "avif". The returned string explains a failure.
+func (r CheckResult) SupportsFeature(feature string) (bool, string) {
+	feature = strings.ToLower(strings.TrimPrefix(feature, "."))
+	for _, req := range featureRequirements {
+		if req.Feature != feature {
+			continue
+		}
+
+		dep := r.ImageMagick
+		if req.Dependency == r.Ghostscript.Name {
+			dep = r.Ghostscript
+		}
+		if dep.Status != StatusOK {
+			return false, fmt.Sprintf("%s requires %s", strings.ToUpper(feature), req.Dependency)
+		}
+		ok, err := versionAtLeast(dep.Version, req.MinVersion)
+		if err != nil {
+			// Unknown versions shouldn't block; the conversion will report errors
+			continue
+		}
+		if !ok {
+			return false, fmt.Sprintf("%s requires %s %s or later (found %s)",
+				strings.ToUpper(feature), req.Dependency, req.MinVersion, dep.Version)
+		}
+	}
+	return true, ""
+}
+
+// CanWriteFormat combines the capability matrix and feature minimums to
+// decide whether a conversion to format can succeed.
+func (r CheckResult) CanWriteFormat(format string) (bool, string) {
+	if !r.Capabilities.CanWrite(format) {
+		return false, fmt.Sprintf("%s is not supported for writing by your ImageMagick build (missing delegate library)",
+			strings.ToUpper(strings.TrimPrefix(format, ".")))
+	}
+	return r.SupportsFeature(format)
+}
@@ -0,0 +1,110 @@
+package deps
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{"7.1.0-62", Version{7, 1, 0, 62}, false},
+		{"10.02.1", Version{10, 2, 1, 0}, false},
+		{"9.56", Version{9, 56, 0, 0}, false},
+		{"7", Version{7, 0, 0, 0}, false},
+		{"detected", Version{}, true},
+		{"", Version{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVersion(%q) error = %v; wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v; want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"7.1.0-62", "7.1.0-61", 1},
+		{"7.1.0", "7.1.0-1", -1},
+		{"7.0.10", "7.0.9-99", 1},
+		{"10.02.1", "10.2.1", 0},
+		{"9.56.1", "10.0.0", -1},
+		{"6.9.11-60", "7.0.0", -1},
+	}
+
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("CompareVersions(%q, %q) error: %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d; want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckMinimumVersion(t *testing.T) {
+	dep := Dependency{Name: "ImageMagick", Version: "6.9.11-60", MinVersion: "7.0.0"}
+	if checkMinimumVersion(&dep) || dep.Status != StatusVersionError {
+		t.Errorf("6.9.11-60 should fail minimum 7.0.0, status = %v", dep.Status)
+	}
+
+	dep = Dependency{Name: "ImageMagick", Version: "7.1.1-21", MinVersion: "7.0.0"}
+	if !checkMinimumVersion(&dep) {
+		t.Errorf("7.1.1-21 should pass minimum 7.0.0: %v", dep.Error)
+	}
+	if len(dep.Warnings) != 0 {
+		t.Errorf("unexpected warnings for 7.1.1-21: %v", dep.Warnings)
+	}
+}
+
+func TestKnownIssueWarnings(t *testing.T) {
+	warnings := knownIssueWarnings("Ghostscript", "10.01.1")
+	if len(warnings) != 2 {
+		t.Fatalf("knownIssueWarnings(10.01.1) = %d warnings; want 2", len(warnings))
+	}
+	if !strings.Contains(warnings[0], "CVE-2023-36664") {
+		t.Errorf("warning %q should mention CVE-2023-36664", warnings[0])
+	}
+
+	if w := knownIssueWarnings("Ghostscript", "10.03.1"); len(w) != 0 {
+		t.Errorf("knownIssueWarnings(10.03.1) = %v; want none", w)
+	}
+	if w := knownIssueWarnings("ImageMagick", "7.0.1-0"); len(w) != 1 {
+		t.Errorf("knownIssueWarnings(IM 7.0.1-0) = %v; want ImageTragick warning", w)
+	}
+	if w := knownIssueWarnings("ImageMagick", "6.9.3-10"); len(w) != 0 {
+		t.Errorf("knownIssueWarnings(IM 6.9.3-10) = %v; want none", w)
+	}
+}
+
+func TestSupportsFeature(t *testing.T) {
+	result := CheckResult{
+		ImageMagick: Dependency{Name: "ImageMagick", Status: StatusOK, Version: "7.0.9-5"},
+		Ghostscript: Dependency{Name: "Ghostscript", Status: StatusOK, Version: "10.03.1"},
+	}
+
+	if ok, reason := result.SupportsFeature("avif"); ok || !strings.Contains(reason, "7.0.10") {
+		t.Errorf("SupportsFeature(avif) = %v, %q; want false mentioning 7.0.10", ok, reason)
+	}
+	if ok, _ := result.SupportsFeature("png"); !ok {
+		t.Error("SupportsFeature(png) = false; want true")
+	}
+
+	result.ImageMagick.Version = "7.1.0-62"
+	if ok, _ := result.SupportsFeature(".AVIF"); !ok {
+		t.Error("SupportsFeature(.AVIF) = false; want true on 7.1.0-62")
+	}
+}
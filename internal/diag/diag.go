@@ -0,0 +1,275 @@
+// Package diag collects environment details into a diagnostics bundle
+// that users can attach to bug reports.
+package diag
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"imagetool/internal/config"
+	"imagetool/internal/deps"
+	"imagetool/internal/logging"
+)
+
+// DefaultMaxLogFiles is how many of the newest log files are included.
+const DefaultMaxLogFiles = 3
+
+// File is a single entry in the bundle.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Bundle is the collected diagnostics, ready to be written as a zip.
+type Bundle struct {
+	Created time.Time
+	Files   []File
+}
+
+// Options controls what Collect gathers.
+type Options struct {
+	Config      *config.Config
+	Deps        deps.CheckResult
+	LogDir      string
+	MaxLogFiles int    // 0 uses DefaultMaxLogFiles
+	Home        string // Home directory to redact; empty uses the current user's
+}
+
+// Collect gathers system, config, dependency and log information. Every
+// file has the home directory replaced with "~".
+func Collect(opts Options) *Bundle {
+	if opts.Home == "" {
+		opts.Home, _ = os.UserHomeDir()
+	}
+	if opts.MaxLogFiles <= 0 {
+		opts.MaxLogFiles = DefaultMaxLogFiles
+	}
+
+	b := &Bundle{Created: time.Now()}
+	add := func(name, content string) {
+		b.Files = append(b.Files, File{Name: name, Data: []byte(Redact(content, opts.Home))})
+	}
+
+	add("system.txt", systemReport(opts, b.Created))
+	add("config.json", configReport(opts.Config))
+	add("dependencies.txt", dependencyReport(opts.Deps))
+	add("formats.txt", formatReport(opts.Deps.Capabilities))
+
+	if opts.LogDir != "" {
+		logs := logging.Files(opts.LogDir)
+		if len(logs) > opts.MaxLogFiles {
+			logs = logs[:opts.MaxLogFiles]
+		}
+		for _, path := range logs {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				add("logs/"+filepath.Base(path)+".error.txt", err.Error())
+				continue
+			}
+			add("logs/"+filepath.Base(path), string(data))
+		}
+	}
+
+	return b
+}
+
+// WriteZip writes the bundle as a zip archive.
+func (b *Bundle) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, f := range b.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: b.Created,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// WriteFile writes the bundle to a zip file at path.
+func (b *Bundle) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create diagnostics file: %w", err)
+	}
+	if err := b.WriteZip(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+	return file.Close()
+}
+
+// DefaultFileName returns a timestamped name for a diagnostics zip.
+func DefaultFileName(t time.Time) string {
+	return fmt.Sprintf("imagetool-diagnostics-%s.zip", t.Format("20060102-150405"))
+}
+
+// Redact replaces the home directory in s with "~". Forward-slash and
+// JSON-escaped spellings of the path are replaced too.
+func Redact(s, home string) string {
+	home = strings.TrimRight(home, `/\`)
+	if home == "" {
+		return s
+	}
+
+	variants := []string{
+		strings.ReplaceAll(home, `\`, `\\`),
+		home,
+		filepath.ToSlash(home),
+		strings.ReplaceAll(home, `\`, "/"),
+	}
+	for _, v := range variants {
+		// Stop at a word boundary so /home/al doesn't match /home/alice
+		re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(v) + `\b`)
+		s = re.ReplaceAllString(s, "~")
+	}
+	return s
+}
+
+// systemReport describes the OS, build and environment.
+func systemReport(opts Options, now time.Time) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Generated: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "CPUs: %d\n", runtime.NumCPU())
+	fmt.Fprintf(&b, "Go: %s\n", runtime.Version())
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintf(&b, "Module: %s %s\n", info.Main.Path, info.Main.Version)
+		for _, s := range info.Settings {
+			if strings.HasPrefix(s.Key, "vcs.") {
+				fmt.Fprintf(&b, "%s: %s\n", s.Key, s.Value)
+			}
+		}
+	}
+
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Config dir: %s\n", config.GetConfigDir())
+	fmt.Fprintf(&b, "Log dir: %s\n", opts.LogDir)
+	for _, env := range []string{config.EnvMagickPath, config.EnvGhostscriptPath, config.EnvLogLevel} {
+		if v, ok := os.LookupEnv(env); ok {
+			fmt.Fprintf(&b, "%s=%s\n", env, v)
+		}
+	}
+
+	return b.String()
+}
+
+// configReport returns the config as indented JSON.
+func configReport(cfg *config.Config) string {
+	if cfg == nil {
+		return "{}\n"
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("failed to encode config: %v\n", err)
+	}
+	return string(data) + "\n"
+}
+
+// dependencyReport describes the dependency check and security policy.
+func dependencyReport(r deps.CheckResult) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "All OK: %v\n\n", r.AllOK)
+	for _, dep := range []deps.Dependency{r.ImageMagick, r.Ghostscript} {
+		fmt.Fprintf(&b, "%s\n", dep.FormatStatus())
+		fmt.Fprintf(&b, "  Command: %s\n", dep.Command)
+		fmt.Fprintf(&b, "  Version: %s (minimum %s)\n", dep.Version, dep.MinVersion)
+		if dep.Error != nil {
+			fmt.Fprintf(&b, "  Error: %v\n", dep.Error)
+		}
+		for _, w := range dep.Warnings {
+			fmt.Fprintf(&b, "  Warning: %s\n", w)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("Security policy\n")
+	if !r.Policy.Probed {
+		b.WriteString("  Not probed\n")
+		return b.String()
+	}
+	for _, p := range r.Policy.Paths {
+		fmt.Fprintf(&b, "  Path: %s\n", p)
+	}
+	fmt.Fprintf(&b, "  PDF blocked: %v\n", r.Policy.PDFBlocked())
+	for _, p := range r.Policy.Policies {
+		fmt.Fprintf(&b, "  Policy: domain=%s name=%s rights=%s pattern=%s value=%s\n",
+			p.Domain, p.Name, p.Rights, p.Pattern, p.Value)
+	}
+	names := make([]string, 0, len(r.Policy.Resources))
+	for name := range r.Policy.Resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  Resource %s: %s\n", name, r.Policy.Resources[name])
+	}
+
+	return b.String()
+}
+
+// formatReport lists the formats and delegates ImageMagick reported.
+func formatReport(c deps.Capabilities) string {
+	var b strings.Builder
+
+	if !c.Probed {
+		b.WriteString("Capabilities not probed")
+		if c.Error != nil {
+			fmt.Fprintf(&b, ": %v", c.Error)
+		}
+		b.WriteString("\n")
+		return b.String()
+	}
+
+	names := make([]string, 0, len(c.Formats))
+	for name := range c.Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteString("Formats (r=read, w=write, +=multi-frame)\n")
+	for _, name := range names {
+		f := c.Formats[name]
+		mode := []byte("---")
+		if f.Read {
+			mode[0] = 'r'
+		}
+		if f.Write {
+			mode[1] = 'w'
+		}
+		if f.MultiFrame {
+			mode[2] = '+'
+		}
+		fmt.Fprintf(&b, "  %-10s %s  %s\n", name, mode, f.Description)
+	}
+
+	b.WriteString("\nDelegates\n")
+	for _, d := range c.Delegates {
+		fmt.Fprintf(&b, "  %s => %s  %s\n", d.Decode, d.Encode, d.Command)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package diag
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"imagetool/internal/config"
+	"imagetool/internal/deps"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		input string
+		home  string
+		want  string
+	}{
+		{"/home/alice/Pictures/a.png", "/home/alice", "~/Pictures/a.png"},
+		{"/home/alice2/a.png", "/home/alice", "/home/alice2/a.png"},
+		{`C:\Users\Bob\img.png`, `C:\Users\Bob`, `~\img.png`},
+		{`"C:\\Users\\Bob\\img.png"`, `C:\Users\Bob`, `"~\\img.png"`},
+		{"C:/Users/bob/img.png", `C:\Users\Bob`, "~/img.png"},
+		{"/tmp/a.png", "", "/tmp/a.png"},
+	}
+
+	for _, tt := range tests {
+		if got := Redact(tt.input, tt.home); got != tt.want {
+			t.Errorf("Redact(%q, %q) = %q; want %q", tt.input, tt.home, got, tt.want)
+		}
+	}
+}
+
+func TestCollectWriteZip(t *testing.T) {
+	home := t.TempDir()
+	logDir := filepath.Join(home, "logs")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	logLine := "[2024-05-01 10:00:00] ERROR: Failed map[path:" + filepath.Join(home, "a.png") + "]\n"
+	if err := os.WriteFile(filepath.Join(logDir, "imagetool_2024-05-01.log"), []byte(logLine), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.NewConfig()
+	cfg.LastDirectory = filepath.Join(home, "Pictures")
+
+	bundle := Collect(Options{
+		Config: cfg,
+		Deps: deps.CheckResult{
+			ImageMagick: deps.Dependency{Name: "ImageMagick", Version: "7.1.1-21"},
+			Capabilities: deps.Capabilities{
+				Probed:  true,
+				Formats: map[string]deps.FormatSupport{"PNG": {Name: "PNG", Read: true, Write: true}},
+			},
+		},
+		LogDir: logDir,
+		Home:   home,
+	})
+
+	var buf bytes.Buffer
+	if err := bundle.WriteZip(&buf); err != nil {
+		t.Fatalf("WriteZip failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+	}
+
+	for _, name := range []string{"system.txt", "config.json", "dependencies.txt", "formats.txt", "logs/imagetool_2024-05-01.log"} {
+		if _, ok := contents[name]; !ok {
+			t.Errorf("bundle is missing %s", name)
+		}
+	}
+	for name, data := range contents {
+		if strings.Contains(data, home) {
+			t.Errorf("%s contains the unredacted home directory", name)
+		}
+	}
+	if !strings.Contains(contents["dependencies.txt"], "7.1.1-21") {
+		t.Error("dependencies.txt should include the ImageMagick version")
+	}
+	if !strings.Contains(contents["formats.txt"], "PNG") {
+		t.Error("formats.txt should list PNG")
+	}
+}
@@ -0,0 +1,340 @@
+// Package history records the operations Image-Tool ran, so they can be
+// reviewed, run again or undone.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the history file in the config directory.
+const FileName = "history.json"
+
+// maxEntries bounds the history; the oldest entries are dropped first.
+const maxEntries = 500
+
+// Operation is the kind of work an entry records.
+type Operation string
+
+const (
+	OpConvert  Operation = "convert"
+	OpPDF      Operation = "pdf"
+	OpCompress Operation = "compress"
+)
+
+// Options are the settings an operation ran with, enough to run it again.
+// Fields that don't apply to the operation are left empty.
+type Options struct {
+	Format       string   `json:"format,omitempty"`
+	Density      int      `json:"density,omitempty"`
+	Quality      int      `json:"quality,omitempty"`
+	Method       string   `json:"method,omitempty"` // "percent", "size" or "quality"
+	Percent      int      `json:"percent,omitempty"`
+	TargetBytes  int64    `json:"target_bytes,omitempty"`
+	MinSSIM      float64  `json:"min_ssim,omitempty"`
+	Formats      []string `json:"formats,omitempty"`
+	NameTemplate string   `json:"name_template,omitempty"`
+	Collision    string   `json:"collision,omitempty"`
+	Output       string   `json:"output,omitempty"` // Chosen output file, or folder for PDF pages
+}
+
+// Entry is one recorded operation.
+type Entry struct {
+	ID        int       `json:"id"`
+	Time      time.Time `json:"time"`
+	Operation Operation `json:"operation"`
+	Title     string    `json:"title"`
+	Inputs    []string  `json:"inputs"`
+	InputDir  string    `json:"input_dir,omitempty"` // Set for folder runs
+	Options   Options   `json:"options"`
+	// Outputs lists only files the operation wrote; outputs that already
+	// existed and were skipped are left out, so undo never removes them
+	Outputs []string `json:"outputs,omitempty"`
+	// Stamps holds each output's size and modification time when the
+	// entry was recorded, so undo can tell it was replaced since
+	Stamps map[string]Stamp `json:"stamps,omitempty"`
+	// CreatedOutput is set when the operation created the Options.Output
+	// folder, the only case in which undo removes it
+	CreatedOutput bool          `json:"created_output,omitempty"`
+	InputSize     int64         `json:"input_size"`
+	OutputSize    int64         `json:"output_size"`
+	Duration      time.Duration `json:"duration_ns"`
+	Success       bool          `json:"success"`
+	Message       string        `json:"message"`
+	Undone        bool          `json:"undone,omitempty"`
+}
+
+// Stamp identifies an output file as the operation left it.
+type Stamp struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// stampOf returns the stamp of the file at path.
+func stampOf(path string) (Stamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Stamp{}, err
+	}
+	return Stamp{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// matches reports whether the file still has the stamp.
+func (s Stamp) matches(info os.FileInfo) bool {
+	return info.Size() == s.Size && info.ModTime().Equal(s.ModTime)
+}
+
+// ErrNothingToUndo is returned by Undo for entries without outputs left
+// to remove.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Store keeps the history in a JSON file.
+type Store struct {
+	mu      sync.Mutex
+	path    string
+	entries []Entry // Oldest first
+	nextID  int
+}
+
+// Open loads the history file at path. A missing file gives an empty
+// history.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.entries); err != nil {
+		return nil, fmt.Errorf("invalid history file %s: %w", path, err)
+	}
+	for _, e := range s.entries {
+		s.nextID = max(s.nextID, e.ID)
+	}
+	return s, nil
+}
+
+// Path returns the history file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Add records an entry and saves the history, returning the entry with
+// its ID and, if unset, its time and output stamps filled in.
+func (s *Store) Add(e Entry) (Entry, error) {
+	if e.Stamps == nil && len(e.Outputs) > 0 {
+		e.Stamps = make(map[string]Stamp, len(e.Outputs))
+		for _, out := range e.Outputs {
+			if stamp, err := stampOf(out); err == nil {
+				e.Stamps[out] = stamp
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	e.ID = s.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.entries = append(s.entries, e)
+	if len(s.entries) > maxEntries {
+		s.entries = append([]Entry(nil), s.entries[len(s.entries)-maxEntries:]...)
+	}
+	return e, s.save()
+}
+
+// Entries returns the recorded entries, newest first.
+func (s *Store) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, len(s.entries))
+	for i, e := range s.entries {
+		entries[len(entries)-1-i] = e
+	}
+	return entries
+}
+
+// Get returns the entry with id.
+func (s *Store) Get(id int) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.find(id); i >= 0 {
+		return s.entries[i], true
+	}
+	return Entry{}, false
+}
+
+// find returns the index of the entry with id, or -1. s.mu must be held.
+func (s *Store) find(id int) int {
+	for i, e := range s.entries {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Undo deletes the outputs an entry wrote and marks it undone, returning
+// how many files were removed and how many were kept because they changed
+// since the entry was recorded, or have no stamp to check them against.
+// Outputs that are already gone are ignored; inputs are never touched. A
+// PDF page folder the operation created is removed too once empty.
+func (s *Store) Undo(id int) (removed, kept int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(id)
+	if i < 0 {
+		return 0, 0, fmt.Errorf("no history entry %d", id)
+	}
+	e := &s.entries[i]
+	if e.Undone || len(e.Outputs) == 0 {
+		return 0, 0, ErrNothingToUndo
+	}
+
+	inputs := make(map[string]bool, len(e.Inputs))
+	for _, in := range e.Inputs {
+		inputs[filepath.Clean(in)] = true
+	}
+	var errs []error
+	for _, out := range e.Outputs {
+		if inputs[filepath.Clean(out)] {
+			continue
+		}
+		info, err := os.Stat(out)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if stamp, ok := e.Stamps[out]; !ok || !stamp.matches(info) {
+			kept++ // Replaced or edited since; no longer ours to remove
+			continue
+		}
+		err = os.Remove(out)
+		switch {
+		case err == nil:
+			removed++
+		case !errors.Is(err, os.ErrNotExist):
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return removed, kept, err
+	}
+
+	if e.Operation == OpPDF && e.CreatedOutput && e.Options.Output != "" {
+		// Fails, as intended, when the folder holds anything else
+		os.Remove(e.Options.Output)
+	}
+	e.Undone = true
+	return removed, kept, s.save()
+}
+
+// save writes the history through a uniquely named temp file in the same
+// directory, so a crash never leaves it truncated and two instances saving
+// at once never write the same temp. s.mu must be held.
+func (s *Store) save() error {
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	temp := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(temp, s.path)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// Package-level functions for the default store
+
+var (
+	defaultMu    sync.Mutex
+	defaultStore *Store
+)
+
+// Init opens the default store at path. Until it is called, Record does
+// nothing and Entries is empty.
+func Init(path string) error {
+	s, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defaultMu.Lock()
+	defaultStore = s
+	defaultMu.Unlock()
+	return nil
+}
+
+// current returns the default store, or nil.
+func current() *Store {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultStore
+}
+
+// Record adds an entry to the default store.
+func Record(e Entry) (Entry, error) {
+	if s := current(); s != nil {
+		return s.Add(e)
+	}
+	return e, nil
+}
+
+// Entries returns the default store's entries, newest first.
+func Entries() []Entry {
+	if s := current(); s != nil {
+		return s.Entries()
+	}
+	return nil
+}
+
+// Get returns the default store's entry with id.
+func Get(id int) (Entry, bool) {
+	if s := current(); s != nil {
+		return s.Get(id)
+	}
+	return Entry{}, false
+}
+
+// Undo undoes an entry of the default store.
+func Undo(id int) (removed, kept int, err error) {
+	if s := current(); s != nil {
+		return s.Undo(id)
+	}
+	return 0, 0, fmt.Errorf("history is not initialized")
+}
@@ -0,0 +1,176 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// touch creates a file with some content.
+func touch(t *testing.T, path string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", FileName)
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Entries()) != 0 {
+		t.Fatal("a missing file should give an empty history")
+	}
+
+	first, err := s.Add(Entry{
+		Operation: OpCompress,
+		Title:     "Compress a.png",
+		Inputs:    []string{"a.png"},
+		Options:   Options{Method: "quality", MinSSIM: 0.95, Formats: []string{"jpg", "webp"}},
+		Duration:  1500 * time.Millisecond,
+		Success:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := s.Add(Entry{Operation: OpConvert, Title: "Convert b.png"})
+	if first.ID != 1 || second.ID != 2 || first.Time.IsZero() {
+		t.Errorf("IDs %d, %d and time %v; want 1, 2 and the time filled in", first.ID, second.ID, first.Time)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := reopened.Entries()
+	if len(entries) != 2 || entries[0].ID != 2 {
+		t.Fatalf("entries = %+v; want both, newest first", entries)
+	}
+	if got := entries[1]; got.Options.MinSSIM != 0.95 || len(got.Options.Formats) != 2 || got.Duration != 1500*time.Millisecond {
+		t.Errorf("reloaded entry = %+v; want its options and duration kept", got)
+	}
+	if third, _ := reopened.Add(Entry{}); third.ID != 3 {
+		t.Errorf("ID after reopening = %d; want 3", third.ID)
+	}
+	if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("config directory holds %d files; want only the history, no temps", len(files))
+	}
+}
+
+func TestStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("expected an error for a corrupt history file")
+	}
+}
+
+func TestStoreKeepsNewest(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxEntries+5; i++ {
+		if _, err := s.Add(Entry{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries := s.Entries()
+	if len(entries) != maxEntries || entries[len(entries)-1].ID != 6 {
+		t.Errorf("kept %d entries, oldest %d; want %d from 6", len(entries), entries[len(entries)-1].ID, maxEntries)
+	}
+}
+
+func TestStoreUndo(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.pdf")
+	pages := filepath.Join(dir, "doc_images")
+	if err := os.Mkdir(pages, 0755); err != nil {
+		t.Fatal(err)
+	}
+	page1, page2 := filepath.Join(pages, "Page-1.png"), filepath.Join(pages, "Page-2.png")
+	page3 := filepath.Join(pages, "Page-3.png")
+	for _, p := range []string{input, page1, page3} {
+		touch(t, p)
+	}
+
+	s, err := Open(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := s.Add(Entry{
+		Operation: OpPDF,
+		Inputs:    []string{input},
+		Options:   Options{Output: pages},
+		// page2 was removed by hand; the input is listed to check it is kept
+		Outputs: []string{page1, page2, page3, input},
+		Success: true,
+	})
+	// page3 is rewritten after the operation, so it is no longer its output
+	if err := os.WriteFile(page3, []byte("edited by hand"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Undo from a reloaded store, as the stamps must survive the file
+	if s, err = Open(s.Path()); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, kept, err := s.Undo(e.ID)
+	if err != nil || removed != 1 || kept != 1 {
+		t.Fatalf("Undo = %d removed, %d kept, %v; want 1 and 1", removed, kept, err)
+	}
+	if _, err := os.Stat(input); err != nil {
+		t.Error("undo removed the input")
+	}
+	if _, err := os.Stat(page3); err != nil {
+		t.Error("undo removed an output changed since the operation")
+	}
+	if _, err := os.Stat(page1); !errors.Is(err, os.ErrNotExist) {
+		t.Error("undo kept an unchanged output")
+	}
+	if got, _ := s.Get(e.ID); !got.Undone {
+		t.Error("entry not marked undone")
+	}
+	if _, _, err := s.Undo(e.ID); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("second Undo = %v; want ErrNothingToUndo", err)
+	}
+	if _, _, err := s.Undo(99); err == nil {
+		t.Error("Undo of a missing entry succeeded")
+	}
+}
+
+func TestStoreUndoRemovesOnlyCreatedFolder(t *testing.T) {
+	for _, created := range []bool{false, true} {
+		dir := t.TempDir()
+		pages := filepath.Join(dir, "pages")
+		if err := os.Mkdir(pages, 0755); err != nil {
+			t.Fatal(err)
+		}
+		page := filepath.Join(pages, "Page-1.png")
+		touch(t, page)
+
+		s, err := Open(filepath.Join(dir, FileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, _ := s.Add(Entry{
+			Operation:     OpPDF,
+			Options:       Options{Output: pages},
+			Outputs:       []string{page},
+			CreatedOutput: created,
+			Success:       true,
+		})
+		if _, _, err := s.Undo(e.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(pages); errors.Is(err, os.ErrNotExist) != created {
+			t.Errorf("created %v: folder removed = %v; want %v", created, !created, created)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+// Package jobs runs long operations in the background so Image-Tool stays
+// usable while they work.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Status is where a job is in its life.
+type Status int
+
+const (
+	StatusQueued Status = iota
+	StatusRunning
+	StatusDone
+	StatusFailed
+	StatusCancelled
+)
+
+// String returns the status as shown to users.
+func (s Status) String() string {
+	switch s {
+	case StatusRunning:
+		return "running"
+	case StatusDone:
+		return "done"
+	case StatusFailed:
+		return "failed"
+	case StatusCancelled:
+		return "cancelled"
+	}
+	return "queued"
+}
+
+// Finished reports whether the job will not change any more.
+func (s Status) Finished() bool {
+	return s == StatusDone || s == StatusFailed || s == StatusCancelled
+}
+
+// ErrCancelled is the outcome error of a job cancelled before it ran.
+var ErrCancelled = errors.New("cancelled")
+
+// Progress reports that done of total units of a job are finished.
+type Progress func(done, total int)
+
+// Func does the work of a job. It should stop early when ctx is cancelled.
+type Func func(ctx context.Context, progress Progress) Outcome
+
+// Outcome is how a job ended.
+type Outcome struct {
+	Message string
+	Err     error       // Non-nil marks the job failed
+	Value   interface{} // Passed back to whoever submitted the job
+}
+
+// Job is a snapshot of a job's state.
+type Job struct {
+	ID      int
+	Title   string
+	Status  Status
+	Done    int // Units finished, as last reported
+	Total   int // Units in all, 0 if unknown
+	Outcome Outcome
+	RetryOf int // The job this retries, or 0
+
+	Submitted time.Time
+	Started   time.Time
+	Finished  time.Time
+}
+
+// entry is a job with what the queue needs to run it.
+type entry struct {
+	Job
+	run    Func
+	cancel context.CancelFunc
+}
+
+// Counts is how many jobs are in each state.
+type Counts struct {
+	Queued    int
+	Running   int
+	Done      int
+	Failed    int
+	Cancelled int
+}
+
+// Active returns the jobs that are queued or running.
+func (c Counts) Active() int {
+	return c.Queued + c.Running
+}
+
+// Total returns the number of jobs.
+func (c Counts) Total() int {
+	return c.Queued + c.Running + c.Done + c.Failed + c.Cancelled
+}
+
+// Queue runs submitted jobs in order, a limited number at a time.
+type Queue struct {
+	mu      sync.Mutex
+	jobs    []*entry
+	nextID  int
+	workers int
+	running int
+	closed  bool
+	wg      sync.WaitGroup
+
+	// changes holds a pending change notice; notices that arrive while
+	// one is pending are merged into it
+	changes chan struct{}
+}
+
+// NewQueue creates a queue that runs up to workers jobs at once.
+func NewQueue(workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Queue{
+		workers: workers,
+		changes: make(chan struct{}, 1),
+	}
+}
+
+// Changes delivers a value after jobs were added or changed state or
+// progress. Several changes may be merged into one value.
+func (q *Queue) Changes() <-chan struct{} {
+	return q.changes
+}
+
+// notify signals a change without blocking.
+func (q *Queue) notify() {
+	select {
+	case q.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Submit queues a job and returns its ID. Jobs submitted after Close are
+// cancelled straight away.
+func (q *Queue) Submit(title string, run Func) int {
+	return q.submit(title, run, 0)
+}
+
+// submit queues a job, recording the job it retries.
+func (q *Queue) submit(title string, run Func, retryOf int) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	e := &entry{
+		Job: Job{
+			ID:        q.nextID,
+			Title:     title,
+			Status:    StatusQueued,
+			RetryOf:   retryOf,
+			Submitted: time.Now(),
+		},
+		run: run,
+	}
+	q.jobs = append(q.jobs, e)
+	if q.closed {
+		e.finish(Outcome{Err: ErrCancelled}, StatusCancelled)
+	}
+	q.startQueued()
+	q.notify()
+	return e.ID
+}
+
+// startQueued starts queued jobs while workers are free. q.mu must be held.
+func (q *Queue) startQueued() {
+	for _, e := range q.jobs {
+		if q.running >= q.workers {
+			return
+		}
+		if e.Status != StatusQueued {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		e.cancel = cancel
+		e.Status = StatusRunning
+		e.Started = time.Now()
+		q.running++
+		q.wg.Add(1)
+		go q.execute(ctx, e)
+	}
+}
+
+// execute runs a job and records how it ended.
+func (q *Queue) execute(ctx context.Context, e *entry) {
+	defer q.wg.Done()
+
+	progress := func(done, total int) {
+		q.mu.Lock()
+		e.Done, e.Total = done, total
+		q.mu.Unlock()
+		q.notify()
+	}
+	outcome := e.run(ctx, progress)
+
+	q.mu.Lock()
+	status := StatusDone
+	switch {
+	case ctx.Err() == context.Canceled:
+		status = StatusCancelled
+	case outcome.Err != nil:
+		status = StatusFailed
+	}
+	e.finish(outcome, status)
+	e.cancel()
+	q.running--
+	q.startQueued()
+	q.mu.Unlock()
+	q.notify()
+}
+
+// finish records the end of a job.
+func (e *entry) finish(outcome Outcome, status Status) {
+	e.Outcome = outcome
+	e.Status = status
+	e.Finished = time.Now()
+}
+
+// find returns the entry with id. q.mu must be held.
+func (q *Queue) find(id int) *entry {
+	for _, e := range q.jobs {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// Cancel stops a queued or running job. A running job is told to stop and
+// is marked cancelled once its work returns. It reports whether the job
+// was still active.
+func (q *Queue) Cancel(id int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.find(id)
+	if e == nil {
+		return false
+	}
+	switch e.Status {
+	case StatusQueued:
+		e.finish(Outcome{Err: ErrCancelled}, StatusCancelled)
+		q.notify()
+		return true
+	case StatusRunning:
+		e.cancel()
+		return true
+	}
+	return false
+}
+
+// Retry queues a failed or cancelled job again as a new job and returns
+// the new ID.
+func (q *Queue) Retry(id int) (int, bool) {
+	q.mu.Lock()
+	e := q.find(id)
+	if e == nil || (e.Status != StatusFailed && e.Status != StatusCancelled) {
+		q.mu.Unlock()
+		return 0, false
+	}
+	title, run := e.Title, e.run
+	q.mu.Unlock()
+
+	return q.submit(title, run, id), true
+}
+
+// Get returns a snapshot of the job with id.
+func (q *Queue) Get(id int) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e := q.find(id); e != nil {
+		return e.Job, true
+	}
+	return Job{}, false
+}
+
+// Jobs returns snapshots of all jobs, oldest first.
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, len(q.jobs))
+	for i, e := range q.jobs {
+		jobs[i] = e.Job
+	}
+	return jobs
+}
+
+// Ahead returns how many queued jobs will start before the job with id.
+func (q *Queue) Ahead(id int) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ahead := 0
+	for _, e := range q.jobs {
+		if e.ID == id {
+			return ahead
+		}
+		if e.Status == StatusQueued {
+			ahead++
+		}
+	}
+	return 0
+}
+
+// Counts returns how many jobs are in each state.
+func (q *Queue) Counts() Counts {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var c Counts
+	for _, e := range q.jobs {
+		switch e.Status {
+		case StatusQueued:
+			c.Queued++
+		case StatusRunning:
+			c.Running++
+		case StatusDone:
+			c.Done++
+		case StatusFailed:
+			c.Failed++
+		case StatusCancelled:
+			c.Cancelled++
+		}
+	}
+	return c
+}
+
+// ClearFinished forgets finished jobs and returns how many were removed.
+func (q *Queue) ClearFinished() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.jobs[:0]
+	for _, e := range q.jobs {
+		if !e.Status.Finished() {
+			kept = append(kept, e)
+		}
+	}
+	removed := len(q.jobs) - len(kept)
+	q.jobs = kept
+	if removed > 0 {
+		q.notify()
+	}
+	return removed
+}
+
+// Close cancels every job and waits for running ones to return, so their
+// partial outputs are cleaned up before the program exits.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	for _, e := range q.jobs {
+		switch e.Status {
+		case StatusQueued:
+			e.finish(Outcome{Err: ErrCancelled}, StatusCancelled)
+		case StatusRunning:
+			e.cancel()
+		}
+	}
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor polls until cond holds or fails the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// statusOf returns the status of a job.
+func statusOf(q *Queue, id int) Status {
+	job, _ := q.Get(id)
+	return job.Status
+}
+
+// blocker returns a job that runs until released or cancelled.
+func blocker(release <-chan struct{}) Func {
+	return func(ctx context.Context, progress Progress) Outcome {
+		select {
+		case <-release:
+			return Outcome{Message: "finished"}
+		case <-ctx.Done():
+			return Outcome{Err: ctx.Err()}
+		}
+	}
+}
+
+func TestQueueRunsInOrderWithinWorkerLimit(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+
+	release := make(chan struct{})
+	first := q.Submit("first", blocker(release))
+	second := q.Submit("second", blocker(release))
+
+	waitFor(t, "first to start", func() bool { return statusOf(q, first) == StatusRunning })
+	if got := statusOf(q, second); got != StatusQueued {
+		t.Errorf("second = %s while first runs; want queued", got)
+	}
+	if ahead := q.Ahead(second); ahead != 0 {
+		t.Errorf("Ahead(second) = %d; want 0", ahead)
+	}
+	if c := q.Counts(); c.Running != 1 || c.Queued != 1 || c.Active() != 2 {
+		t.Errorf("Counts = %+v; want 1 running, 1 queued", c)
+	}
+
+	release <- struct{}{}
+	waitFor(t, "second to start", func() bool { return statusOf(q, second) == StatusRunning })
+	if job, _ := q.Get(first); job.Status != StatusDone || job.Outcome.Message != "finished" {
+		t.Errorf("first = %+v; want done", job)
+	}
+
+	release <- struct{}{}
+	waitFor(t, "second to finish", func() bool { return statusOf(q, second) == StatusDone })
+}
+
+func TestQueueFailureProgressAndChanges(t *testing.T) {
+	q := NewQueue(2)
+	defer q.Close()
+
+	id := q.Submit("fails", func(ctx context.Context, progress Progress) Outcome {
+		progress(1, 3)
+		return Outcome{Message: "broke", Err: errors.New("boom"), Value: 42}
+	})
+
+	waitFor(t, "failure", func() bool { return statusOf(q, id) == StatusFailed })
+	job, _ := q.Get(id)
+	if job.Done != 1 || job.Total != 3 || job.Outcome.Value != 42 {
+		t.Errorf("job = %+v; want progress 1/3 and the value passed back", job)
+	}
+	select {
+	case <-q.Changes():
+	default:
+		t.Error("no change was signalled")
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+
+	release := make(chan struct{})
+	running := q.Submit("running", blocker(release))
+	queued := q.Submit("queued", blocker(release))
+	waitFor(t, "start", func() bool { return statusOf(q, running) == StatusRunning })
+
+	if !q.Cancel(queued) || statusOf(q, queued) != StatusCancelled {
+		t.Errorf("queued job = %s after Cancel; want cancelled", statusOf(q, queued))
+	}
+	if !q.Cancel(running) {
+		t.Error("Cancel of a running job reported it inactive")
+	}
+	waitFor(t, "running job to stop", func() bool { return statusOf(q, running) == StatusCancelled })
+	if q.Cancel(running) {
+		t.Error("Cancel of a finished job reported it active")
+	}
+
+	// A cancelled job can be retried as a new one
+	retry, ok := q.Retry(queued)
+	if !ok {
+		t.Fatal("Retry of a cancelled job failed")
+	}
+	if job, _ := q.Get(retry); job.RetryOf != queued || job.Title != "queued" {
+		t.Errorf("retry = %+v; want a copy of job %d", job, queued)
+	}
+	close(release)
+	waitFor(t, "retry to finish", func() bool { return statusOf(q, retry) == StatusDone })
+	if _, ok := q.Retry(retry); ok {
+		t.Error("Retry of a successful job succeeded")
+	}
+
+	if removed := q.ClearFinished(); removed != 3 || len(q.Jobs()) != 0 {
+		t.Errorf("ClearFinished removed %d, left %d; want all 3 removed", removed, len(q.Jobs()))
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	q := NewQueue(1)
+	running := q.Submit("running", blocker(make(chan struct{})))
+	queued := q.Submit("queued", blocker(make(chan struct{})))
+	waitFor(t, "start", func() bool { return statusOf(q, running) == StatusRunning })
+
+	q.Close()
+	if statusOf(q, running) != StatusCancelled || statusOf(q, queued) != StatusCancelled {
+		t.Errorf("after Close: running %s, queued %s; want both cancelled", statusOf(q, running), statusOf(q, queued))
+	}
+	if late := q.Submit("late", blocker(nil)); statusOf(q, late) != StatusCancelled {
+		t.Error("a job submitted after Close was not cancelled")
+	}
+}
@@ -3,11 +3,12 @@
 package logging
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -40,6 +41,62 @@ func (l Level) String() string {
 	return "UNKNOWN"
 }
 
+// ParseLevel parses a level name such as "debug" or "WARN".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// Format selects how log entries are written.
+type Format int
+
+const (
+	// FormatText writes human-readable lines.
+	FormatText Format = iota
+	// FormatJSON writes one JSON object per line (JSON Lines).
+	FormatJSON
+)
+
+// ParseFormat parses "text" or "json".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatText, fmt.Errorf("unknown log format %q", s)
+}
+
+// Options configures a Logger.
+type Options struct {
+	Level    Level
+	Format   Format
+	MaxSize  int64         // Rotate within a day once a file reaches this size; 0 disables
+	MaxFiles int           // Keep at most this many log files; 0 keeps all
+	MaxAge   time.Duration // Delete log files older than this at startup; 0 keeps all
+}
+
+// DefaultOptions returns the options used by Init.
+func DefaultOptions() Options {
+	return Options{
+		Level:    LevelInfo,
+		Format:   FormatText,
+		MaxSize:  10 * 1024 * 1024,
+		MaxFiles: 30,
+		MaxAge:   30 * 24 * time.Hour,
+	}
+}
+
 // Logger handles logging to file.
 type Logger struct {
 	mu       sync.Mutex
@@ -47,6 +104,14 @@ type Logger struct {
 	writer   io.Writer
 	minLevel Level
 	errors   []LogEntry // Collected errors for summary
+
+	// File rotation state
+	dir     string
+	opts    Options
+	day     string
+	index   int
+	size    int64
+	nowFunc func() time.Time
 }
 
 // LogEntry represents a single log entry.
@@ -64,9 +129,15 @@ var (
 
 // Init initializes the default logger. Safe to call multiple times.
 func Init(logDir string) error {
+	return InitWithOptions(logDir, DefaultOptions())
+}
+
+// InitWithOptions initializes the default logger with custom options.
+// Only the first call has any effect.
+func InitWithOptions(logDir string, opts Options) error {
 	var initErr error
 	once.Do(func() {
-		logger, err := NewLogger(logDir)
+		logger, err := NewLoggerWithOptions(logDir, opts)
 		if err != nil {
 			initErr = err
 			return
@@ -78,25 +149,29 @@ func Init(logDir string) error {
 
 // NewLogger creates a new logger instance.
 func NewLogger(logDir string) (*Logger, error) {
+	return NewLoggerWithOptions(logDir, DefaultOptions())
+}
+
+// NewLoggerWithOptions creates a new logger instance, removing log files
+// that fall outside the retention limits before opening today's file.
+func NewLoggerWithOptions(logDir string, opts Options) (*Logger, error) {
 	// Create log directory
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
 	}
 
-	// Create log file with timestamp
-	timestamp := time.Now().Format("2006-01-02")
-	logPath := filepath.Join(logDir, fmt.Sprintf("imagetool_%s.log", timestamp))
-
-	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open log file: %w", err)
-	}
-
 	logger := &Logger{
-		file:     file,
-		writer:   file,
-		minLevel: LevelInfo,
+		minLevel: opts.Level,
 		errors:   make([]LogEntry, 0),
+		dir:      logDir,
+		opts:     opts,
+		nowFunc:  time.Now,
+	}
+
+	removed := cleanupOldLogs(logDir, opts, logger.nowFunc())
+
+	if err := logger.openCurrent(); err != nil {
+		return nil, err
 	}
 
 	// Log startup
@@ -104,6 +179,11 @@ func NewLogger(logDir string) (*Logger, error) {
 		"os":   runtime.GOOS,
 		"arch": runtime.GOARCH,
 	})
+	if removed > 0 {
+		logger.Debug("Removed old log files", map[string]interface{}{
+			"count": removed,
+		})
+	}
 
 	return logger, nil
 }
@@ -128,8 +208,13 @@ func (l *Logger) log(level Level, message string, context map[string]interface{}
 		return
 	}
 
+	now := time.Now()
+	if l.nowFunc != nil {
+		now = l.nowFunc()
+	}
+
 	entry := LogEntry{
-		Time:    time.Now(),
+		Time:    now,
 		Level:   level,
 		Message: message,
 		Context: context,
@@ -140,7 +225,20 @@ func (l *Logger) log(level Level, message string, context map[string]interface{}
 		l.errors = append(l.errors, entry)
 	}
 
-	// Format log line
+	var line string
+	if l.opts.Format == FormatJSON {
+		line = formatJSON(entry)
+	} else {
+		line = formatText(entry)
+	}
+
+	l.rotateIfNeeded(entry.Time)
+	n, _ := l.writer.Write([]byte(line))
+	l.size += int64(n)
+}
+
+// formatText renders an entry as a human-readable line.
+func formatText(entry LogEntry) string {
 	line := fmt.Sprintf("[%s] %s: %s",
 		entry.Time.Format("2006-01-02 15:04:05"),
 		entry.Level.String(),
@@ -148,13 +246,62 @@ func (l *Logger) log(level Level, message string, context map[string]interface{}
 	)
 
 	// Add context if present
-	if len(context) > 0 {
-		line += fmt.Sprintf(" %v", context)
+	if len(entry.Context) > 0 {
+		line += fmt.Sprintf(" %v", entry.Context)
 	}
 
-	line += "\n"
+	return line + "\n"
+}
 
-	l.writer.Write([]byte(line))
+// jsonEntry is the on-disk shape of a JSON Lines log entry.
+type jsonEntry struct {
+	Time    string                 `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"msg"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// formatJSON renders an entry as a single JSON line. Context values keep
+// their JSON types; errors and durations are written as strings, and
+// values that can't be encoded fall back to their %v form.
+func formatJSON(entry LogEntry) string {
+	je := jsonEntry{
+		Time:    entry.Time.Format(time.RFC3339Nano),
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+	}
+
+	if len(entry.Context) > 0 {
+		je.Fields = make(map[string]interface{}, len(entry.Context))
+		for k, v := range entry.Context {
+			je.Fields[k] = jsonValue(v)
+		}
+	}
+
+	data, err := json.Marshal(je)
+	if err != nil {
+		data, _ = json.Marshal(jsonEntry{Time: je.Time, Level: je.Level, Message: je.Message})
+	}
+	return string(data) + "\n"
+}
+
+// jsonValue converts a context value into something json.Marshal encodes
+// meaningfully.
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return val.Error()
+	case time.Duration:
+		return val.String()
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return v
 }
 
 // Debug logs a debug message.
@@ -211,6 +358,11 @@ func (l *Logger) GetErrorSummary() string {
 	return summary
 }
 
+// Dir returns the directory log files are written to.
+func (l *Logger) Dir() string {
+	return l.dir
+}
+
 // Close closes the log file.
 func (l *Logger) Close() error {
 	l.mu.Lock()
@@ -268,6 +420,22 @@ func GetErrorSummary() string {
 	return ""
 }
 
+// SetLevel sets the minimum level of the default logger.
+func SetLevel(level Level) {
+	if defaultLogger != nil {
+		defaultLogger.SetLevel(level)
+	}
+}
+
+// Dir returns the log directory of the default logger, or "" if logging
+// is not initialized.
+func Dir() string {
+	if defaultLogger != nil {
+		return defaultLogger.Dir()
+	}
+	return ""
+}
+
 // Close closes the default logger.
 func Close() error {
 	if defaultLogger != nil {
@@ -0,0 +1,207 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Level
+		wantErr  bool
+	}{
+		{"debug", LevelDebug, false},
+		{"INFO", LevelInfo, false},
+		{"warning", LevelWarn, false},
+		{" error ", LevelError, false},
+		{"verbose", LevelInfo, true},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseLevel(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v; wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if result != tt.expected {
+			t.Errorf("ParseLevel(%q) = %v; want %v", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	entry := LogEntry{
+		Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Level:   LevelError,
+		Message: "Conversion failed",
+		Context: map[string]interface{}{
+			"error":    errors.New("exit status 1"),
+			"size":     int64(2048),
+			"ok":       false,
+			"duration": 1500 * time.Millisecond,
+		},
+	}
+
+	line := formatJSON(entry)
+	if !strings.HasSuffix(line, "\n") {
+		t.Error("JSON line should end with a newline")
+	}
+
+	var decoded struct {
+		Time   string                 `json:"time"`
+		Level  string                 `json:"level"`
+		Msg    string                 `json:"msg"`
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", line, err)
+	}
+
+	if decoded.Level != "ERROR" || decoded.Msg != "Conversion failed" {
+		t.Errorf("decoded = %+v; want ERROR / Conversion failed", decoded)
+	}
+	if decoded.Fields["error"] != "exit status 1" {
+		t.Errorf("error field = %v; want exit status 1", decoded.Fields["error"])
+	}
+	if decoded.Fields["size"] != float64(2048) {
+		t.Errorf("size field = %v; want number 2048", decoded.Fields["size"])
+	}
+	if decoded.Fields["ok"] != false {
+		t.Errorf("ok field = %v; want false", decoded.Fields["ok"])
+	}
+	if decoded.Fields["duration"] != "1.5s" {
+		t.Errorf("duration field = %v; want 1.5s", decoded.Fields["duration"])
+	}
+}
+
+func TestLoggerSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	opts := DefaultOptions()
+	opts.MaxSize = 200
+	logger, err := NewLoggerWithOptions(dir, opts)
+	if err != nil {
+		t.Fatalf("NewLoggerWithOptions failed: %v", err)
+	}
+	defer logger.Close()
+
+	for i := 0; i < 10; i++ {
+		logger.Info("a message long enough to fill the log file quickly", nil)
+	}
+
+	day := time.Now().Format("2006-01-02")
+	for _, name := range []string{logFileName(day, 0), logFileName(day, 1)} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected rotated file %s: %v", name, err)
+		}
+	}
+}
+
+func TestLoggerDayRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.Local)
+	logger := &Logger{dir: dir, opts: DefaultOptions(), nowFunc: func() time.Time { return now }}
+	if err := logger.openCurrent(); err != nil {
+		t.Fatalf("openCurrent failed: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Info("before midnight", nil)
+	now = now.Add(2 * time.Minute)
+	logger.Info("after midnight", nil)
+
+	data, err := os.ReadFile(filepath.Join(dir, "imagetool_2024-05-02.log"))
+	if err != nil {
+		t.Fatalf("expected new day's log file: %v", err)
+	}
+	if !strings.Contains(string(data), "after midnight") {
+		t.Errorf("new day's log = %q; want the post-midnight entry", data)
+	}
+}
+
+func TestCleanupOldLogs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	create := func(name string, age time.Duration) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mod := now.Add(-age)
+		os.Chtimes(path, mod, mod)
+	}
+	create("imagetool_2024-05-05.log", 1*time.Hour)
+	create("imagetool_2024-05-04.log", 24*time.Hour)
+	create("imagetool_2024-05-04.1.log", 25*time.Hour)
+	create("imagetool_2024-04-01.log", 40*24*time.Hour)
+	create("unrelated.txt", 90*24*time.Hour)
+
+	removed := cleanupOldLogs(dir, Options{MaxFiles: 2, MaxAge: 30 * 24 * time.Hour}, now)
+	if removed != 2 {
+		t.Errorf("cleanupOldLogs removed %d files; want 2", removed)
+	}
+
+	for name, want := range map[string]bool{
+		"imagetool_2024-05-05.log":   true,
+		"imagetool_2024-05-04.log":   true,
+		"imagetool_2024-05-04.1.log": false,
+		"imagetool_2024-04-01.log":   false,
+		"unrelated.txt":              true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists = %v; want %v", name, exists, want)
+		}
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	entry, ok := ParseLine("[2024-05-01 12:30:00] WARN: File not found map[path:/tmp/x.png]")
+	if !ok {
+		t.Fatal("ParseLine(text) failed")
+	}
+	if entry.Level != LevelWarn || entry.Message != "File not found map[path:/tmp/x.png]" {
+		t.Errorf("ParseLine(text) = %+v", entry)
+	}
+
+	entry, ok = ParseLine(`{"time":"2024-05-01T12:30:00Z","level":"ERROR","msg":"Boom","fields":{"code":3}}`)
+	if !ok {
+		t.Fatal("ParseLine(json) failed")
+	}
+	if entry.Level != LevelError || entry.Message != "Boom" || entry.Context["code"] != float64(3) {
+		t.Errorf("ParseLine(json) = %+v", entry)
+	}
+
+	if _, ok := ParseLine("garbage"); ok {
+		t.Error("ParseLine(garbage) should fail")
+	}
+}
+
+func TestReadRecent(t *testing.T) {
+	dir := t.TempDir()
+	older := "[2024-05-01 10:00:00] INFO: first\n[2024-05-01 10:00:01] INFO: second\n"
+	newer := "{\"time\":\"2024-05-02T10:00:00Z\",\"level\":\"ERROR\",\"msg\":\"third\"}\n"
+	if err := os.WriteFile(filepath.Join(dir, "imagetool_2024-05-01.log"), []byte(older), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "imagetool_2024-05-02.log"), []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "imagetool_2024-05-01.log"), past, past)
+
+	entries, err := ReadRecent(dir, 2)
+	if err != nil {
+		t.Fatalf("ReadRecent failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Message != "second" || entries[1].Message != "third" {
+		t.Errorf("ReadRecent(2) = %+v; want second, third", entries)
+	}
+}
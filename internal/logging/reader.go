@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// textLineRe matches lines written in FormatText.
+var textLineRe = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\] ([A-Z]+): (.*)$`)
+
+// ParseLine parses one log line in either text or JSON format. For text
+// lines the context is kept as part of the message.
+func ParseLine(line string) (LogEntry, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return LogEntry{}, false
+	}
+
+	if strings.HasPrefix(line, "{") {
+		var je jsonEntry
+		if err := json.Unmarshal([]byte(line), &je); err != nil {
+			return LogEntry{}, false
+		}
+		t, _ := time.Parse(time.RFC3339Nano, je.Time)
+		level, _ := ParseLevel(je.Level)
+		return LogEntry{Time: t, Level: level, Message: je.Message, Context: je.Fields}, true
+	}
+
+	m := textLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return LogEntry{}, false
+	}
+	t, _ := time.ParseInLocation("2006-01-02 15:04:05", m[1], time.Local)
+	level, _ := ParseLevel(m[2])
+	return LogEntry{Time: t, Level: level, Message: m[3]}, true
+}
+
+// ReadRecent returns up to limit of the most recent entries from the log
+// files in dir, oldest first. A limit of 0 or less returns everything.
+func ReadRecent(dir string, limit int) ([]LogEntry, error) {
+	var entries []LogEntry
+
+	for _, f := range listLogFiles(dir) {
+		fileEntries, err := readLogFile(f.path)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(fileEntries, entries...)
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// readLogFile parses every recognisable line of a log file.
+func readLogFile(path string) ([]LogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if entry, ok := ParseLine(scanner.Text()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,141 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// logFilePattern matches every file written by the logger, including
+// size-rotated files such as imagetool_2006-01-02.1.log.
+const logFilePattern = "imagetool_*.log"
+
+// logFileName returns the file name for a day and rotation index.
+// Index 0 is the first file of the day.
+func logFileName(day string, index int) string {
+	if index == 0 {
+		return fmt.Sprintf("imagetool_%s.log", day)
+	}
+	return fmt.Sprintf("imagetool_%s.%d.log", day, index)
+}
+
+// openCurrent opens the newest log file for today, starting a new rotation
+// index if the existing one is already full. Callers hold l.mu or own l.
+func (l *Logger) openCurrent() error {
+	day := l.nowFunc().Format("2006-01-02")
+
+	// Find the last file written today
+	index := 0
+	for {
+		if _, err := os.Stat(filepath.Join(l.dir, logFileName(day, index+1))); err != nil {
+			break
+		}
+		index++
+	}
+	if l.opts.MaxSize > 0 {
+		if info, err := os.Stat(filepath.Join(l.dir, logFileName(day, index))); err == nil && info.Size() >= l.opts.MaxSize {
+			index++
+		}
+	}
+
+	return l.openFile(day, index)
+}
+
+// openFile switches output to the given day and index, closing the
+// previous file only once the new one is open.
+func (l *Logger) openFile(day string, index int) error {
+	path := filepath.Join(l.dir, logFileName(day, index))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = file
+	l.writer = file
+	l.day = day
+	l.index = index
+	l.size = size
+	return nil
+}
+
+// rotateIfNeeded starts a new file when the day changes or the current
+// file has reached MaxSize. Errors keep the current file in use.
+func (l *Logger) rotateIfNeeded(now time.Time) {
+	if l.file == nil {
+		return
+	}
+
+	day := now.Format("2006-01-02")
+	switch {
+	case day != l.day:
+		l.openFile(day, 0)
+	case l.opts.MaxSize > 0 && l.size >= l.opts.MaxSize:
+		l.openFile(day, l.index+1)
+	}
+}
+
+// cleanupOldLogs deletes log files beyond the retention count or age and
+// returns how many were removed.
+func cleanupOldLogs(dir string, opts Options, now time.Time) int {
+	if opts.MaxFiles <= 0 && opts.MaxAge <= 0 {
+		return 0
+	}
+
+	files := listLogFiles(dir)
+	removed := 0
+	for i, f := range files {
+		tooMany := opts.MaxFiles > 0 && i >= opts.MaxFiles
+		tooOld := opts.MaxAge > 0 && now.Sub(f.modTime) > opts.MaxAge
+		if tooMany || tooOld {
+			if os.Remove(f.path) == nil {
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// logFile is a log file on disk.
+type logFile struct {
+	path    string
+	modTime time.Time
+}
+
+// listLogFiles returns the log files in dir, newest first.
+func listLogFiles(dir string) []logFile {
+	matches, _ := filepath.Glob(filepath.Join(dir, logFilePattern))
+
+	files := make([]logFile, 0, len(matches))
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, logFile{path: m, modTime: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files
+}
+
+// Files returns the paths of the log files in dir, newest first.
+func Files(dir string) []string {
+	files := listLogFiles(dir)
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths
+}
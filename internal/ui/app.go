@@ -1,12 +1,20 @@
 package ui
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"imagetool/internal/config"
+	"imagetool/internal/core"
 	"imagetool/internal/deps"
+	"imagetool/internal/diag"
+	"imagetool/internal/jobs"
 	"imagetool/internal/logging"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -21,8 +29,14 @@ const (
 	ViewFormatConverter
 	ViewCompressor
 	ViewFilePicker
+	ViewLogs
+	ViewJobs
+	ViewHistory
 )
 
+// jobWorkers is how many jobs run at once
+const jobWorkers = 2
+
 // MenuItem represents a main menu option
 type MenuItem struct {
 	Title       string
@@ -39,20 +53,47 @@ type App struct {
 	height      int
 	quitting    bool
 
+	// Configuration
+	cfg *config.Config
+
 	// Dependency status
 	depResult   deps.CheckResult
 	depChecked  bool
 	depBlocking bool
 
+	// Manual executable path entry on the dependency screen
+	depPathInput  textinput.Model
+	depPathTarget string // "magick" or "gs" while entering a path
+	depPathErr    error
+
 	// Sub-models
 	pdfConverter    *PDFConverterModel
 	formatConverter *FormatConverterModel
 	compressor      *CompressorModel
 	filePicker      *FilePickerModel
+	logViewer       *LogViewerModel
+	logReturnView   View
+	jobsView        *JobsModel
+	historyView     *HistoryModel
+
+	// Conversions and compressions run here, in the background
+	jobs      *jobs.Queue
+	quitArmed bool // Quit asked for while jobs were active
+	// The wizard that submitted each job, reopened on it from the Jobs view
+	jobWizards map[int]jobWizard
 
+	// Folder runs an earlier session left unfinished, offered for resuming
+	pendingBatches []*core.Manifest
+
+	// Kitty and Sixel previews are drawn after the frame they appear in
+	term      *Terminal
+	graphics  graphicsProtocol
+	placement []graphicsPlacement // In the last frame
+	drawn     []graphicsPlacement // On screen
 	// Shared state
 	statusMessage string
 	isError       bool
+	menuNotice    string // Non-error result of the last menu action
 }
 
 // KeyMap defines key bindings
@@ -93,20 +134,74 @@ var keys = KeyMap{
 }
 
 // NewApp creates a new application instance
-func NewApp() *App {
-	return &App{
-		currentView: ViewDependencyCheck,
+func NewApp(cfg *config.Config) *App {
+	if cfg == nil {
+		cfg = config.NewConfig()
+	}
+
+	pathInput := textinput.New()
+	pathInput.Placeholder = "Full path to executable..."
+	pathInput.CharLimit = 500
+	pathInput.Width = 60
+
+	app := &App{
+		cfg:          cfg,
+		depPathInput: pathInput,
+		currentView:  ViewDependencyCheck,
 		menuItems: []MenuItem{
 			{Title: "PDF to Image Converter", Description: "Convert PDF pages to images (PNG, JPG, etc.)", Icon: IconPDF},
 			{Title: "Convert Image Format", Description: "Convert images between formats (WebP, AVIF, etc.)", Icon: IconConvert},
 			{Title: "Compress Image/PDF", Description: "Reduce file size by percentage or target size", Icon: IconCompress},
+			{Title: "Jobs", Description: "Follow, cancel or retry background conversions and compressions", Icon: IconJobs},
+			{Title: "History", Description: "Re-run, open or undo past operations", Icon: IconHistory},
+			{Title: "View Logs", Description: "Browse recent log entries and session errors", Icon: IconLogs},
+			{Title: "Export Diagnostics", Description: "Save a zip with logs and environment details for bug reports", Icon: IconDiagnose},
 			{Title: "Exit", Description: "Quit the application", Icon: IconExit},
 		},
-		menuCursor:      0,
-		pdfConverter:    NewPDFConverterModel(),
-		formatConverter: NewFormatConverterModel(),
-		compressor:      NewCompressorModel(),
-		filePicker:      NewFilePickerModel(),
+		menuCursor: 0,
+		filePicker: NewFilePickerModel(cfg),
+		term:       NewTerminal(os.Stdout),
+		graphics:   detectGraphics(cfg.Preview),
+		jobs:       jobs.NewQueue(jobWorkers),
+		jobWizards: make(map[int]jobWizard),
+	}
+	app.pdfConverter = NewPDFConverterModel(cfg, app.jobs)
+	app.formatConverter = NewFormatConverterModel(cfg, deps.CheckResult{}, app.jobs)
+	app.compressor = NewCompressorModel(cfg, app.jobs)
+	app.jobsView = NewJobsModel(app.jobs)
+	return app
+}
+
+// Output returns the terminal the program writes to, which App draws
+// previews on
+func (a *App) Output() *Terminal {
+	return a.term
+}
+
+// Close cancels the jobs still queued or running and waits for them to
+// stop, so their partial outputs are removed
+func (a *App) Close() {
+	a.jobs.Close()
+}
+
+// diagnosticsMsg reports where the diagnostics bundle was written
+type diagnosticsMsg struct {
+	path string
+	err  error
+}
+
+// exportDiagnostics writes a diagnostics zip to the config directory
+func (a *App) exportDiagnostics() tea.Cmd {
+	cfg := a.cfg
+	result := a.depResult
+	return func() tea.Msg {
+		bundle := diag.Collect(diag.Options{
+			Config: cfg,
+			Deps:   result,
+			LogDir: logging.Dir(),
+		})
+		path := filepath.Join(config.GetConfigDir(), "diagnostics", diag.DefaultFileName(bundle.Created))
+		return diagnosticsMsg{path: path, err: bundle.WriteFile(path)}
 	}
 }
 
@@ -119,33 +214,134 @@ type dependencyCheckMsg struct {
 func (a *App) Init() tea.Cmd {
 	return tea.Batch(
 		tea.EnterAltScreen,
-		checkDependencies,
+		a.checkDependencies(),
+		waitForJobs(a.jobs),
+		loadPendingBatches,
 	)
 }
 
-// checkDependencies verifies ImageMagick and Ghostscript are available
-func checkDependencies() tea.Msg {
-	result := deps.Check()
-	return dependencyCheckMsg{result: result}
+// checkDependencies verifies ImageMagick and Ghostscript are available,
+// honouring executable paths from the config and environment
+func (a *App) checkDependencies() tea.Cmd {
+	opts := deps.Options{
+		MagickPath:      a.cfg.EffectiveMagickPath(),
+		GhostscriptPath: a.cfg.EffectiveGhostscriptPath(),
+	}
+	return func() tea.Msg {
+		return dependencyCheckMsg{result: deps.CheckWith(opts)}
+	}
 }
 
 // Update implements tea.Model
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(drawGraphicsMsg); ok {
+		return a, a.drawPreview()
+	}
+
+	model, cmd := a.update(msg)
+
+	// Anything that can move or change a preview redraws it
+	if a.graphics == graphicsKitty || a.graphics == graphicsSixel {
+		switch msg.(type) {
+		case tea.KeyMsg, tea.WindowSizeMsg, previewMsg:
+			cmd = tea.Batch(cmd, scheduleGraphics())
+		}
+	}
+	return model, cmd
+}
+
+// drawPreview draws the Kitty or Sixel previews of the last frame. Sixel
+// pixels are erased by text drawn over them, so they are redrawn every
+// time, and a hidden one is cleared with a full repaint before the rest
+// are drawn again.
+func (a *App) drawPreview() tea.Cmd {
+	p := a.placement
+	if a.graphics == graphicsSixel {
+		for _, d := range a.drawn {
+			if !hasSlot(p, d.slot) {
+				a.drawn = nil
+				return tea.Batch(tea.ClearScreen, scheduleGraphics())
+			}
+		}
+	}
+	if len(p) == 0 {
+		a.drawn = nil
+		return nil
+	}
+	if !samePlacements(p, a.drawn) || a.graphics == graphicsSixel {
+		a.drawn = p
+		a.term.draw(p)
+	}
+	return nil
+}
+
+// previewPanes returns the previews the current view may show
+func (a *App) previewPanes() []*previewPane {
+	switch a.currentView {
+	case ViewPDFConverter:
+		return a.pdfConverter.previewPanes()
+	case ViewFormatConverter:
+		return a.formatConverter.previewPanes()
+	case ViewCompressor:
+		return a.compressor.previewPanes()
+	case ViewFilePicker:
+		return a.filePicker.previewPanes()
+	}
+	return nil
+}
+
+// update handles a message for the current view
+func (a *App) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		// Global quit
-		if key.Matches(msg, keys.Quit) && (a.currentView == ViewMenu || a.currentView == ViewDependencyCheck) {
-			a.quitting = true
-			return a, tea.Quit
+		// Global quit (except while typing a path)
+		if key.Matches(msg, keys.Quit) && a.depPathTarget == "" &&
+			(a.currentView == ViewMenu || a.currentView == ViewDependencyCheck) {
+			return a, a.quit()
 		}
+		a.quitArmed = false
+
+	case jobsChangedMsg:
+		// The current view follows its job; keep listening for the next change
+		model, cmd := a.route(msg)
+		return model, tea.Batch(cmd, waitForJobs(a.jobs))
 
 	case tea.WindowSizeMsg:
 		a.width = msg.Width
 		a.height = msg.Height
+		a.filePicker.SetSize(a.width, a.height)
+		a.pdfConverter.SetSize(a.width, a.height)
+		a.formatConverter.SetSize(a.width, a.height)
+		a.compressor.SetSize(a.width, a.height)
+		a.jobsView.SetSize(a.width, a.height)
+		if a.historyView != nil {
+			a.historyView.SetSize(a.width, a.height)
+		}
+
+	case pendingBatchesMsg:
+		a.pendingBatches = msg.manifests
+		return a, nil
+
+	case diagnosticsMsg:
+		a.menuNotice = ""
+		if msg.err != nil {
+			a.statusMessage = msg.err.Error()
+			a.isError = true
+			logging.Error("Diagnostics export failed", map[string]interface{}{
+				"error": msg.err.Error(),
+			})
+			return a, nil
+		}
+		a.menuNotice = "Diagnostics saved to " + msg.path
+		logging.Info("Diagnostics written", map[string]interface{}{
+			"path": msg.path,
+		})
+		return a, nil
 
 	case dependencyCheckMsg:
 		a.depChecked = true
 		a.depResult = msg.result
+		a.depBlocking = false
 
 		logging.Info("Dependency check completed", map[string]interface{}{
 			"imagemagick": msg.result.ImageMagick.Status == deps.StatusOK,
@@ -168,6 +364,19 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					"error": msg.result.Ghostscript.Error,
 				})
 			}
+		} else if msg.result.HasWarnings() {
+			// Stay on the dependency screen so the warnings are seen
+			a.statusMessage = "Dependencies detected with warnings"
+			a.isError = false
+
+			for _, dep := range []deps.Dependency{msg.result.ImageMagick, msg.result.Ghostscript} {
+				for _, w := range dep.Warnings {
+					logging.Warn("Dependency warning", map[string]interface{}{
+						"dependency": dep.Name,
+						"warning":    w,
+					})
+				}
+			}
 		} else {
 			a.currentView = ViewMenu
 			a.statusMessage = "✓ All dependencies detected"
@@ -176,7 +385,11 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, nil
 	}
 
-	// Route to current view
+	return a.route(msg)
+}
+
+// route passes a message to the current view
+func (a *App) route(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch a.currentView {
 	case ViewDependencyCheck:
 		return a.updateDependencyCheck(msg)
@@ -190,22 +403,57 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a.updateCompressor(msg)
 	case ViewFilePicker:
 		return a.updateFilePicker(msg)
+	case ViewLogs:
+		return a.updateLogViewer(msg)
+	case ViewJobs:
+		return a.updateJobsView(msg)
+	case ViewHistory:
+		return a.updateHistoryView(msg)
 	}
 
 	return a, nil
 }
 
+// quit exits the app. While jobs are active it first asks the user to
+// press quit again.
+func (a *App) quit() tea.Cmd {
+	if active := a.jobs.Counts().Active(); active > 0 && !a.quitArmed {
+		a.quitArmed = true
+		a.menuNotice = ""
+		a.statusMessage = fmt.Sprintf("%d job(s) still running. Press Q again to cancel them and quit", active)
+		a.isError = true
+		return nil
+	}
+	a.quitting = true
+	return tea.Quit
+}
+
 // updateDependencyCheck handles dependency check view
 func (a *App) updateDependencyCheck(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if a.depPathTarget != "" {
+		return a.updateDependencyPathInput(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
+		case a.depBlocking && msg.String() == "m":
+			return a, a.startDependencyPathInput("magick")
+		case a.depBlocking && msg.String() == "g":
+			return a, a.startDependencyPathInput("gs")
+		case a.depBlocking && msg.String() == "r":
+			a.depChecked = false
+			return a, a.checkDependencies()
 		case key.Matches(msg, keys.Enter):
 			// If blocking, just quit
 			if a.depBlocking {
 				a.quitting = true
 				return a, tea.Quit
 			}
+			// Warnings acknowledged, continue to the menu
+			if a.depChecked {
+				a.currentView = ViewMenu
+			}
 		case key.Matches(msg, keys.Quit):
 			a.quitting = true
 			return a, tea.Quit
@@ -214,12 +462,98 @@ func (a *App) updateDependencyCheck(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, nil
 }
 
+// startDependencyPathInput switches the dependency screen to path entry
+func (a *App) startDependencyPathInput(target string) tea.Cmd {
+	a.depPathTarget = target
+	a.depPathErr = nil
+	a.depPathInput.SetValue("")
+	a.depPathInput.Focus()
+	return textinput.Blink
+}
+
+// updateDependencyPathInput handles typing an explicit executable path
+func (a *App) updateDependencyPathInput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "enter":
+			path := strings.TrimSpace(strings.Trim(a.depPathInput.Value(), "\"'`"))
+			if err := deps.ValidatePath(path); err != nil {
+				a.depPathErr = err
+				return a, nil
+			}
+
+			if a.depPathTarget == "magick" {
+				a.cfg.MagickPath = path
+			} else {
+				a.cfg.GhostscriptPath = path
+			}
+			if err := a.cfg.Save(); err != nil {
+				logging.Warn("Could not save config", map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+			logging.Info("Executable path configured", map[string]interface{}{
+				"target": a.depPathTarget,
+				"path":   path,
+			})
+
+			a.depPathTarget = ""
+			a.depPathInput.Blur()
+			a.depChecked = false
+			return a, a.checkDependencies()
+		case "esc":
+			a.depPathTarget = ""
+			a.depPathErr = nil
+			a.depPathInput.Blur()
+			return a, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	a.depPathInput, cmd = a.depPathInput.Update(msg)
+	return a, cmd
+}
+
+// updateResumePrompt handles the prompt for an unfinished folder run
+// shown above the menu, reporting whether the key was used
+func (a *App) updateResumePrompt(msg tea.KeyMsg) bool {
+	m := a.pendingBatches[0]
+	title := m.Header().Title
+	switch msg.String() {
+	case "y", "Y":
+		id := a.jobs.Submit(title+" (resumed)", resumeJob(m.Path()))
+		a.clearMenuError()
+		a.menuNotice = fmt.Sprintf("Resuming %s as job #%d", title, id)
+	case "d", "D":
+		a.clearMenuError()
+		if err := m.Discard(); err != nil {
+			a.statusMessage = err.Error()
+			a.isError = true
+		} else {
+			a.menuNotice = "Discarded the unfinished run " + title
+			logging.Info("Discarded unfinished folder run", map[string]interface{}{
+				"manifest": m.Path(),
+			})
+		}
+	case "n", "N": // Ask again next launch
+	default:
+		return false
+	}
+	a.pendingBatches = a.pendingBatches[1:]
+	return true
+}
+
 // updateMenu handles main menu navigation
 func (a *App) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && len(a.pendingBatches) > 0 && a.updateResumePrompt(msg) {
+		return a, nil
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, keys.Up):
+			a.clearMenuError()
 			if a.menuCursor > 0 {
 				a.menuCursor--
 			} else {
@@ -227,6 +561,7 @@ func (a *App) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case key.Matches(msg, keys.Down):
+			a.clearMenuError()
 			if a.menuCursor < len(a.menuItems)-1 {
 				a.menuCursor++
 			} else {
@@ -236,35 +571,78 @@ func (a *App) updateMenu(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, keys.Enter):
 			switch a.menuCursor {
 			case 0: // PDF to Image
+				if ok, reason := a.depResult.CanReadPDF(); !ok {
+					a.statusMessage = reason
+					a.isError = true
+					logging.Warn("PDF converter unavailable", map[string]interface{}{
+						"reason": reason,
+					})
+					return a, nil
+				}
 				a.currentView = ViewPDFConverter
-				a.pdfConverter = NewPDFConverterModel()
-				return a, nil
+				a.pdfConverter = NewPDFConverterModel(a.cfg, a.jobs)
+				a.pdfConverter.SetSize(a.width, a.height)
+				return a, a.pdfConverter.Init()
 			case 1: // Convert Format
 				a.currentView = ViewFormatConverter
-				a.formatConverter = NewFormatConverterModel()
-				return a, nil
+				a.formatConverter = NewFormatConverterModel(a.cfg, a.depResult, a.jobs)
+				a.formatConverter.SetSize(a.width, a.height)
+				return a, a.formatConverter.Init()
 			case 2: // Compress
 				a.currentView = ViewCompressor
-				a.compressor = NewCompressorModel()
+				a.compressor = NewCompressorModel(a.cfg, a.jobs)
+				a.compressor.SetSize(a.width, a.height)
+				return a, a.compressor.Init()
+			case 3: // Jobs
+				a.clearMenuError()
+				a.jobsView = NewJobsModel(a.jobs)
+				a.jobsView.SetSize(a.width, a.height)
+				a.currentView = ViewJobs
 				return a, nil
-			case 3: // Exit
-				a.quitting = true
-				return a, tea.Quit
+			case 4: // History
+				a.clearMenuError()
+				a.historyView = NewHistoryModel(a.cfg, a.jobs)
+				a.historyView.SetSize(a.width, a.height)
+				a.currentView = ViewHistory
+				return a, nil
+			case 5: // View Logs
+				a.openLogViewer(ViewMenu)
+				return a, nil
+			case 6: // Export Diagnostics
+				a.clearMenuError()
+				a.menuNotice = "Collecting diagnostics..."
+				return a, a.exportDiagnostics()
+			case 7: // Exit
+				return a, a.quit()
 			}
 		}
 	}
 	return a, nil
 }
 
+// clearMenuError dismisses an error or notice shown on the menu
+func (a *App) clearMenuError() {
+	if a.isError {
+		a.statusMessage = ""
+		a.isError = false
+	}
+	a.menuNotice = ""
+}
+
 // updatePDFConverter handles PDF converter view
 func (a *App) updatePDFConverter(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	a.pdfConverter, cmd = a.pdfConverter.Update(msg)
 
+	if a.pdfConverter.WantsLogs() {
+		a.openLogViewer(ViewPDFConverter)
+		return a, cmd
+	}
 	if a.pdfConverter.IsDone() {
 		if a.pdfConverter.BackToMenu() {
 			a.currentView = ViewMenu
 		}
+		a.noteSubmitted(a.pdfConverter)
 	}
 	return a, cmd
 }
@@ -274,10 +652,15 @@ func (a *App) updateFormatConverter(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	a.formatConverter, cmd = a.formatConverter.Update(msg)
 
+	if a.formatConverter.WantsLogs() {
+		a.openLogViewer(ViewFormatConverter)
+		return a, cmd
+	}
 	if a.formatConverter.IsDone() {
 		if a.formatConverter.BackToMenu() {
 			a.currentView = ViewMenu
 		}
+		a.noteSubmitted(a.formatConverter)
 	}
 	return a, cmd
 }
@@ -287,10 +670,137 @@ func (a *App) updateCompressor(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	a.compressor, cmd = a.compressor.Update(msg)
 
+	if a.compressor.WantsLogs() {
+		a.openLogViewer(ViewCompressor)
+		return a, cmd
+	}
 	if a.compressor.IsDone() {
 		if a.compressor.BackToMenu() {
 			a.currentView = ViewMenu
 		}
+		a.noteSubmitted(a.compressor)
+	}
+	return a, cmd
+}
+
+// jobWizard is a wizard that runs its work as a background job
+type jobWizard interface {
+	Submitted() int
+	Follow(id int)
+	SetSize(width, height int)
+}
+
+// noteSubmitted announces on the menu a job the wizard just submitted and
+// remembers the wizard, so the Jobs view can reopen it on the result
+func (a *App) noteSubmitted(w jobWizard) {
+	id := w.Submitted()
+	if id == 0 {
+		return
+	}
+	a.jobWizards[id] = w
+	title := "the job"
+	if job, ok := a.jobs.Get(id); ok {
+		title = job.Title
+	}
+	a.clearMenuError()
+	a.menuNotice = fmt.Sprintf("Started %s as job #%d. Open Jobs and press Enter on it for the result", title, id)
+}
+
+// wizardFor returns the wizard that submitted job id, or the job it
+// retries, or nil
+func (a *App) wizardFor(id int) jobWizard {
+	for id != 0 {
+		if w, ok := a.jobWizards[id]; ok {
+			return w
+		}
+		job, ok := a.jobs.Get(id)
+		if !ok {
+			break
+		}
+		id = job.RetryOf
+	}
+	return nil
+}
+
+// openJob reopens the wizard that submitted job id on it, reporting
+// whether there was one
+func (a *App) openJob(id int) (tea.Cmd, bool) {
+	w := a.wizardFor(id)
+	if w == nil {
+		return nil, false
+	}
+	w.Follow(id)
+	w.SetSize(a.width, a.height)
+	switch w := w.(type) {
+	case *PDFConverterModel:
+		a.pdfConverter = w
+		a.currentView = ViewPDFConverter
+	case *FormatConverterModel:
+		a.formatConverter = w
+		a.currentView = ViewFormatConverter
+	case *CompressorModel:
+		a.compressor = w
+		a.currentView = ViewCompressor
+	}
+	// Show the result right away if the job has finished
+	_, cmd := a.route(jobsChangedMsg{})
+	return cmd, true
+}
+
+// openLogViewer shows the log viewer, returning to the given view on exit
+func (a *App) openLogViewer(returnView View) {
+	a.logViewer = NewLogViewerModel()
+	a.logViewer.SetSize(a.width, a.height)
+	a.logReturnView = returnView
+	a.currentView = ViewLogs
+}
+
+// updateLogViewer handles log viewer view
+func (a *App) updateLogViewer(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	a.logViewer, cmd = a.logViewer.Update(msg)
+
+	if a.logViewer.IsDone() {
+		a.currentView = a.logReturnView
+	}
+	return a, cmd
+}
+
+// updateJobsView handles the jobs view
+func (a *App) updateJobsView(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	a.jobsView, cmd = a.jobsView.Update(msg)
+
+	// Forget the wizards of cleared jobs
+	for id := range a.jobWizards {
+		if _, ok := a.jobs.Get(id); !ok {
+			delete(a.jobWizards, id)
+		}
+	}
+
+	if id := a.jobsView.Opened(); id != 0 {
+		if open, ok := a.openJob(id); ok {
+			return a, tea.Batch(cmd, open)
+		}
+		a.jobsView.notice = "This job has no result screen"
+	}
+	if a.jobsView.IsDone() {
+		a.currentView = ViewMenu
+	}
+	return a, cmd
+}
+
+// updateHistoryView handles the history view
+func (a *App) updateHistoryView(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	a.historyView, cmd = a.historyView.Update(msg)
+
+	// Re-runs reopen from the Jobs view like jobs started from a wizard
+	if id, w := a.historyView.Rerun(); w != nil {
+		a.jobWizards[id] = w
+	}
+	if a.historyView.IsDone() {
+		a.currentView = ViewMenu
 	}
 	return a, cmd
 }
@@ -304,10 +814,34 @@ func (a *App) updateFilePicker(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 // View implements tea.Model
 func (a *App) View() string {
+	frame, placement := findGraphics(a.viewContent(), a.previewPanes())
+	a.placement = placement
+	if a.graphics == graphicsKitty {
+		for slot := previewSlots - 1; slot >= 0; slot-- {
+			if !hasSlot(a.placement, slot) {
+				frame = kittyDelete(slot) + frame
+			}
+		}
+	}
+	return frame
+}
+
+// viewContent renders the current view, followed by the jobs status bar
+// once any job was submitted
+func (a *App) viewContent() string {
 	if a.quitting {
 		return "\n  👋 Thanks for using Image Tool!\n\n"
 	}
 
+	content := a.viewCurrent()
+	if status := jobsStatusLine(a.jobs.Counts()); status != "" && a.currentView != ViewDependencyCheck {
+		content += "\n\n" + status
+	}
+	return content
+}
+
+// viewCurrent renders the current view
+func (a *App) viewCurrent() string {
 	switch a.currentView {
 	case ViewDependencyCheck:
 		return a.viewDependencyCheck()
@@ -321,6 +855,12 @@ func (a *App) View() string {
 		return a.compressor.View()
 	case ViewFilePicker:
 		return a.filePicker.View()
+	case ViewLogs:
+		return a.logViewer.View()
+	case ViewJobs:
+		return a.jobsView.View()
+	case ViewHistory:
+		return a.historyView.View()
 	}
 
 	return ""
@@ -352,20 +892,16 @@ func (a *App) viewDependencyCheck() string {
 	b.WriteString("\n\n")
 
 	// ImageMagick status
-	if a.depResult.ImageMagick.Status == deps.StatusOK {
-		b.WriteString(depOKStyle.Render("  " + a.depResult.ImageMagick.FormatStatus()))
-	} else {
-		b.WriteString(depErrorStyle.Render("  " + a.depResult.ImageMagick.FormatStatus()))
-	}
-	b.WriteString("\n")
+	b.WriteString(renderDependencyStatus(a.depResult.ImageMagick))
 
 	// Ghostscript status
-	if a.depResult.Ghostscript.Status == deps.StatusOK {
-		b.WriteString(depOKStyle.Render("  " + a.depResult.Ghostscript.FormatStatus()))
-	} else {
-		b.WriteString(depErrorStyle.Render("  " + a.depResult.Ghostscript.FormatStatus()))
+	b.WriteString(renderDependencyStatus(a.depResult.Ghostscript))
+	b.WriteString("\n")
+
+	if !a.depBlocking && a.depResult.HasWarnings() {
+		b.WriteString(helpStyle.Render("Press Enter to continue • Q to quit"))
+		return b.String()
 	}
-	b.WriteString("\n\n")
 
 	if a.depBlocking {
 		b.WriteString(errorStyle.Render("─────────────────────────────────────────────────"))
@@ -389,14 +925,56 @@ func (a *App) viewDependencyCheck() string {
 			b.WriteString(descriptionStyle.Render("  Purpose: " + a.depResult.Ghostscript.Description))
 			b.WriteString("\n")
 			b.WriteString(descriptionStyle.Render("  Download: " + a.depResult.Ghostscript.DownloadURL))
+			b.WriteString("\n")
+			b.WriteString(descriptionStyle.Render("  Minimum version: v" + a.depResult.Ghostscript.MinVersion))
 			b.WriteString("\n\n")
 		}
 
-		b.WriteString(descriptionStyle.Render("After installation, ensure the tools are in your PATH."))
+		if a.depPathTarget != "" {
+			name := "ImageMagick"
+			if a.depPathTarget == "gs" {
+				name = "Ghostscript"
+			}
+			b.WriteString(inputLabelStyle.Render("Path to " + name + " executable: "))
+			b.WriteString("\n")
+			b.WriteString(a.depPathInput.View())
+			b.WriteString("\n")
+			if a.depPathErr != nil {
+				b.WriteString(errorStyle.Render("Error: " + a.depPathErr.Error()))
+				b.WriteString("\n")
+			}
+			b.WriteString(helpStyle.Render("Enter to save and re-check • Esc to cancel"))
+			return b.String()
+		}
+
+		b.WriteString(descriptionStyle.Render("After installation, ensure the tools are in your PATH,"))
 		b.WriteString("\n")
-		b.WriteString(descriptionStyle.Render("Then restart this application."))
+		b.WriteString(descriptionStyle.Render("or point Image Tool at a portable install below."))
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Press Enter or Q to exit"))
+		b.WriteString(helpStyle.Render("M Set ImageMagick path • G Set Ghostscript path • R Re-check • Enter/Q Exit"))
+	}
+
+	return b.String()
+}
+
+// renderDependencyStatus renders one dependency line followed by its warnings
+func renderDependencyStatus(dep deps.Dependency) string {
+	var b strings.Builder
+
+	if dep.Status == deps.StatusOK {
+		b.WriteString(depOKStyle.Render("  " + dep.FormatStatus()))
+	} else {
+		b.WriteString(depErrorStyle.Render("  " + dep.FormatStatus()))
+		if dep.Status == deps.StatusNotFound && dep.Error != nil {
+			b.WriteString("\n")
+			b.WriteString(descriptionStyle.Render("    " + dep.Error.Error()))
+		}
+	}
+	b.WriteString("\n")
+
+	for _, w := range dep.Warnings {
+		b.WriteString(warningStyle.Render("    " + IconWarning + "  " + w))
+		b.WriteString("\n")
 	}
 
 	return b.String()
@@ -429,6 +1007,10 @@ func (a *App) viewMenu() string {
 	b.WriteString(hint)
 	b.WriteString("\n\n")
 
+	if len(a.pendingBatches) > 0 {
+		b.WriteString(resumePrompt(a.pendingBatches[0]))
+	}
+
 	// Menu items
 	for i, item := range a.menuItems {
 		cursor := "  "
@@ -452,23 +1034,43 @@ func (a *App) viewMenu() string {
 		b.WriteString("\n")
 	}
 
+	// Error from the last menu action
+	if a.isError && a.statusMessage != "" {
+		b.WriteString(errorStyle.Render(IconError + " " + a.statusMessage))
+		b.WriteString("\n")
+	}
+	if a.menuNotice != "" {
+		b.WriteString(successStyle.Render(a.menuNotice))
+		b.WriteString("\n")
+	}
+
 	// Status bar with dependency info
 	if a.depChecked {
 		b.WriteString("\n")
 		b.WriteString(descriptionStyle.Render("───────────────────────────────────────────"))
 		b.WriteString("\n")
 
-		depStatus := depOKStyle.Render("  ✔ ImageMagick")
-		if a.depResult.ImageMagick.Version != "" && a.depResult.ImageMagick.Version != "detected" {
-			depStatus += depOKStyle.Render(" (" + a.depResult.ImageMagick.Version + ")")
-		}
+		depStatus := "  " + menuDependencyStatus(a.depResult.ImageMagick)
 		depStatus += "  "
-		depStatus += depOKStyle.Render("✔ Ghostscript")
-		if a.depResult.Ghostscript.Version != "" && a.depResult.Ghostscript.Version != "detected" {
-			depStatus += depOKStyle.Render(" (" + a.depResult.Ghostscript.Version + ")")
-		}
+		depStatus += menuDependencyStatus(a.depResult.Ghostscript)
 		b.WriteString(depStatus)
 	}
 
 	return b.String()
 }
+
+// menuDependencyStatus renders the compact dependency status for the menu footer
+func menuDependencyStatus(dep deps.Dependency) string {
+	style := depOKStyle
+	mark := "✔ "
+	if len(dep.Warnings) > 0 {
+		style = warningStyle
+		mark = "⚠ "
+	}
+
+	status := style.Render(mark + dep.Name)
+	if dep.Version != "" && dep.Version != "detected" {
+		status += style.Render(" (" + dep.Version + ")")
+	}
+	return status
+}
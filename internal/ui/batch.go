@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"imagetool/internal/core"
+	"imagetool/internal/logging"
+)
+
+// maxBatchFailures limits the failures listed after a batch run
+const maxBatchFailures = 5
+
+// folderFiles lists the files of the given types directly in dir, as the
+// file picker shows them
+func folderFiles(dir string, images, pdfs bool) ([]string, error) {
+	entries, err := core.Walk(dir, core.WalkOptions{
+		IncludeImages: images,
+		IncludePDFs:   pdfs,
+		Symlinks:      core.SymlinksFiles,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no matching files in %s", dir)
+	}
+
+	files := make([]string, len(entries))
+	for i, e := range entries {
+		files[i] = e.Path
+	}
+	return files, nil
+}
+
+// batchMessage summarizes a batch run, e.g. "Converted 8 of 10 file(s),
+// skipped 1 existing, 1 failed"
+func batchMessage(verb string, r core.BatchResult) string {
+	msg := fmt.Sprintf("%s %d of %d file(s)", verb, r.SuccessCount, r.TotalFiles)
+	if r.UpToDateCount > 0 {
+		msg += fmt.Sprintf(", %d already done", r.UpToDateCount)
+	}
+	if r.SkippedCount > 0 {
+		msg += fmt.Sprintf(", skipped %d existing", r.SkippedCount)
+	}
+	if r.FailCount > 0 {
+		msg += fmt.Sprintf(", %d failed", r.FailCount)
+	}
+	if len(r.Results) < r.TotalFiles {
+		msg += fmt.Sprintf(", %d not started", r.TotalFiles-len(r.Results))
+	}
+	return msg
+}
+
+// batchError returns the first failure of a batch in which nothing
+// succeeded, or nil
+func batchError(r core.BatchResult) error {
+	if r.SuccessCount > 0 || r.SkippedCount > 0 || r.UpToDateCount > 0 {
+		return nil
+	}
+	for _, res := range r.Results {
+		if !res.Success {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// batchManifest returns the manifest for a folder run: on a retry the one
+// the earlier attempt left, so finished files are skipped, otherwise a new
+// one. A reopened manifest is claimed for this process, so other instances
+// don't resume it meanwhile; it fails when one of them already has. Without
+// a manifest the run still works but can't be resumed.
+func batchManifest(previous *core.Manifest, header core.ManifestHeader) (*core.Manifest, error) {
+	if previous != nil {
+		if m, err := core.OpenManifest(previous.Path()); err == nil {
+			if err := m.Claim(); err != nil {
+				return nil, err
+			}
+			return m, nil
+		}
+	}
+	m, err := core.CreateManifest(header)
+	if err != nil {
+		logging.Warn("Could not create batch manifest", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, nil
+	}
+	return m, nil
+}
+
+// finishManifest closes a folder run's manifest, keeping it if the run
+// can be resumed
+func finishManifest(m *core.Manifest) {
+	if err := m.Finish(); err != nil {
+		logging.Warn("Could not update batch manifest", map[string]interface{}{
+			"manifest": m.Path(),
+			"error":    err.Error(),
+		})
+	}
+}
+
+// firstOutput returns the first file a batch wrote, or ""
+func firstOutput(r core.BatchResult) string {
+	for _, res := range r.Results {
+		if res.Success && !res.Skipped && res.OutputPath != "" {
+			return res.OutputPath
+		}
+	}
+	return ""
+}
+
+// batchFailures lists the files that failed with their messages. Results
+// are in the same order as inputs.
+func batchFailures(inputs []string, r core.BatchResult) string {
+	var lines []string
+	for i, res := range r.Results {
+		if res.Success || i >= len(inputs) {
+			continue
+		}
+		if len(lines) == maxBatchFailures {
+			lines = append(lines, fmt.Sprintf("  ... and %d more (see logs)", r.FailCount-maxBatchFailures))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s: %s", IconCross, filepath.Base(inputs[i]), res.Message))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"imagetool/internal/core"
+)
+
+// outputCheckLimit is how many files of a folder the wizards work out the
+// outputs of before starting. Templates using {width}, {height} or {hash8}
+// probe or hash every file they are expanded for, which would stall the
+// UI on a large folder; the job still applies the policy to every file.
+const outputCheckLimit = 5
+
+// checkedInputs returns the inputs whose outputs are checked up front,
+// and how many are left unchecked
+func checkedInputs(inputs []string) ([]string, int) {
+	n := min(len(inputs), outputCheckLimit)
+	return inputs[:n], len(inputs) - n
+}
+
+// collisionWarning describes outputs that already exist and what the
+// policy will do with them, or "" when nothing exists. unchecked counts
+// the folder files past outputCheckLimit whose outputs weren't looked at
+func collisionWarning(existing []string, unchecked int, policy core.CollisionPolicy) string {
+	switch {
+	case len(existing) == 0:
+		return ""
+	case unchecked > 0:
+		return fmt.Sprintf("%s  %d of the first %d output files already exist and %s",
+			IconWarning, len(existing), outputCheckLimit, policy.Describe())
+	case len(existing) == 1:
+		return fmt.Sprintf("%s  %s already exists and %s", IconWarning, filepath.Base(existing[0]), policy.Describe())
+	}
+	return fmt.Sprintf("%s  %d output files already exist and %s", IconWarning, len(existing), policy.Describe())
+}
@@ -0,0 +1,165 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"imagetool/internal/config"
+	"imagetool/internal/core"
+)
+
+// compareMsg carries the metrics of a finished comparison
+type compareMsg struct {
+	original string
+	output   string
+	metrics  core.Metrics
+	err      error
+}
+
+// comparisonView shows an original and its output side by side with
+// quality metrics
+type comparisonView struct {
+	original string
+	output   string
+	before   previewPane
+	after    previewPane
+	metrics  core.Metrics
+	err      error
+	loading  bool
+}
+
+// newComparisonView creates an empty comparison
+func newComparisonView(cfg *config.Config) comparisonView {
+	c := comparisonView{
+		before: newPreviewPane(cfg),
+		after:  newPreviewPane(cfg),
+	}
+	c.after.slot = 1
+	return c
+}
+
+// start compares output with original, loading both previews and the
+// metrics in the background
+func (c *comparisonView) start(original, output string) tea.Cmd {
+	c.original = original
+	c.output = output
+	c.metrics = core.Metrics{}
+	c.err = nil
+	c.loading = true
+	return tea.Batch(
+		c.before.request(original),
+		c.after.request(output),
+		func() tea.Msg {
+			m, err := core.Compare(context.Background(), core.CompareOptions{Original: original, Processed: output})
+			return compareMsg{original: original, output: output, metrics: m, err: err}
+		},
+	)
+}
+
+// clear hides the previews
+func (c *comparisonView) clear() {
+	c.before.request("")
+	c.after.request("")
+	c.original = ""
+	c.output = ""
+}
+
+// update takes loaded previews and metrics for the current comparison
+func (c *comparisonView) update(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case previewMsg:
+		c.before.update(msg)
+		c.after.update(msg)
+	case compareMsg:
+		if msg.original != c.original || msg.output != c.output {
+			return
+		}
+		c.loading = false
+		c.metrics = msg.metrics
+		c.err = msg.err
+	}
+}
+
+// View renders the previews with their sizes above them, then the metrics
+func (c *comparisonView) View(originalSize, outputSize int64) string {
+	var b strings.Builder
+
+	left := inputLabelStyle.Render(fmt.Sprintf("Original (%s)", core.FormatSize(originalSize)))
+	right := inputLabelStyle.Render(fmt.Sprintf("Compressed (%s)", core.FormatSize(outputSize)))
+	if before := c.before.View(); before != "" {
+		left += "\n" + before
+	}
+	if after := c.after.View(); after != "" {
+		right += "\n" + after
+	}
+	b.WriteString(joinColumns(padColumn(left, previewCols), right))
+	b.WriteString("\n\n")
+
+	switch {
+	case c.loading:
+		b.WriteString(progressStyle.Render("⏳ Measuring quality..."))
+	case c.err != nil:
+		b.WriteString(errorStyle.Render(IconError + " Could not compare: " + c.err.Error()))
+	default:
+		b.WriteString(metricsSummary(c.metrics))
+	}
+	return b.String()
+}
+
+// metricsSummary lists the metrics with a plain-language verdict
+func metricsSummary(m core.Metrics) string {
+	psnr := "∞ dB"
+	if !math.IsInf(m.PSNR, 1) {
+		psnr = fmt.Sprintf("%.1f dB", m.PSNR)
+	}
+	lines := []string{
+		descriptionStyle.Render(fmt.Sprintf("PSNR:     %s", psnr)),
+		descriptionStyle.Render(fmt.Sprintf("SSIM:     %.4f (MS-SSIM %.4f)", m.SSIM, m.MSSSIM)),
+		descriptionStyle.Render(fmt.Sprintf("MAE:      %.2f", m.MAE)),
+		descriptionStyle.Render(fmt.Sprintf("Max diff: %d/255", m.MaxDiff)),
+		descriptionStyle.Render(fmt.Sprintf("Changed:  %.1f%% of pixels", m.DiffPercent())),
+	}
+
+	verdict := qualityVerdict(m)
+	switch {
+	case m.SSIM >= 0.95:
+		verdict = successStyle.Render(verdict)
+	case m.SSIM >= 0.90:
+		verdict = warningStyle.Render(verdict)
+	default:
+		verdict = errorStyle.Render(verdict)
+	}
+	return strings.Join(lines, "\n") + "\n\n" + verdict
+}
+
+// qualityVerdict describes how visible the differences are likely to be
+func qualityVerdict(m core.Metrics) string {
+	switch {
+	case m.Identical():
+		return "Identical to the original"
+	case m.SSIM >= 0.98:
+		return "Visually indistinguishable"
+	case m.SSIM >= 0.95:
+		return "Minor differences on close inspection"
+	case m.SSIM >= 0.90:
+		return "Noticeable differences"
+	}
+	return "Obvious loss of quality"
+}
+
+// padColumn pads every line of s to width so a column placed beside it
+// clears previews drawn over blank lines
+func padColumn(s string, width int) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if w := lipgloss.Width(line); w < width {
+			lines[i] = line + strings.Repeat(" ", width-w)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
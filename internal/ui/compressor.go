@@ -1,14 +1,19 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"imagetool/internal/config"
 	"imagetool/internal/core"
+	"imagetool/internal/history"
+	"imagetool/internal/jobs"
 	"imagetool/internal/logging"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -24,8 +29,10 @@ const (
 	CompressStepSelectMethod
 	CompressStepSetPercent
 	CompressStepSetFixedSize
+	CompressStepSetQuality
 	CompressStepConfirm
 	CompressStepCompressing
+	CompressStepCompare
 	CompressStepDone
 )
 
@@ -33,23 +40,39 @@ const (
 type CompressorModel struct {
 	step       CompressStep
 	filePicker *FilePickerModel
+	cfg        *config.Config
+	jobs       *jobs.Queue
+	jobID      int // The submitted compression
+	submitted  int // Job submitted since the app last asked
 
 	// Settings
 	inputFile     string
+	inputDir      string   // Set when a whole folder is compressed
+	inputFiles    []string // Images and PDFs in inputDir
 	outputFile    string
+	written       string // Output the last compression wrote, the only file R removes
+	nameTemplate  nameTemplateEditor
+	collision     core.CollisionPolicy
+	existing      []string // Outputs that already exist
+	unchecked     int      // Folder files whose outputs weren't checked
+	outputErr     error    // An output would replace its input
 	inputSize     int64
 	method        core.CompressMethod
 	targetPercent int
 	targetBytes   int64
+	minSSIM       float64
+	allowWebP     bool // Let the quality search pick WebP over JPG
 
 	// Method selection
 	methods      []string
 	methodCursor int
+	methodNote   string
 
 	// Text inputs
 	percentInput textinput.Model
 	sizeInput    textinput.Model
 	unitInput    textinput.Model
+	qualityInput textinput.Model
 
 	// Fixed size state
 	sizeValue float64
@@ -58,17 +81,26 @@ type CompressorModel struct {
 	// Results
 	result     string
 	isError    bool
+	details    errorDetails
 	outputSize int64
+	quality    int
+	score      float64
+	batch      core.BatchResult
+	preview    previewPane
+	comparison comparisonView
+	manifest   *core.Manifest // Of the folder run, kept so a retry resumes it
 
 	// Navigation
 	done       bool
 	backToMenu bool
+	showLogs   bool
 }
 
 // NewCompressorModel creates a new compressor
-func NewCompressorModel() *CompressorModel {
-	fp := NewFilePickerModel()
+func NewCompressorModel(cfg *config.Config, queue *jobs.Queue) *CompressorModel {
+	fp := NewFilePickerModel(cfg)
 	fp.SetMode(FilePickerAll) // Both images and PDFs
+	fp.SetAllowDirectory(true)
 
 	percentInput := textinput.New()
 	percentInput.Placeholder = fmt.Sprintf("%d", config.DefaultCompressPercent)
@@ -85,16 +117,29 @@ func NewCompressorModel() *CompressorModel {
 	unitInput.CharLimit = 2
 	unitInput.Width = 5
 
+	qualityInput := textinput.New()
+	qualityInput.Placeholder = fmt.Sprintf("%g", config.DefaultMinSSIM)
+	qualityInput.CharLimit = 6
+	qualityInput.Width = 10
+
 	return &CompressorModel{
 		step:          CompressStepSelectFile,
 		filePicker:    fp,
-		methods:       []string{"By Percentage", "Fixed File Size"},
+		cfg:           cfg,
+		jobs:          queue,
+		methods:       []string{"By Percentage", "Fixed File Size", "Quality Floor"},
 		methodCursor:  0,
 		targetPercent: config.DefaultCompressPercent,
+		minSSIM:       config.DefaultMinSSIM,
 		percentInput:  percentInput,
 		sizeInput:     sizeInput,
 		unitInput:     unitInput,
+		qualityInput:  qualityInput,
 		sizeUnit:      "KB",
+		nameTemplate:  newNameTemplateEditor(cfg.Templates.Compress),
+		preview:       newPreviewPane(cfg),
+		comparison:    newComparisonView(cfg),
+		collision:     core.ParseCollisionPolicy(cfg.Collision),
 	}
 }
 
@@ -102,7 +147,23 @@ func NewCompressorModel() *CompressorModel {
 type compressResultMsg struct {
 	message    string
 	isError    bool
+	outputPath string
 	outputSize int64
+	skipped    bool
+	quality    int     // Chosen by the quality floor method
+	score      float64 // SSIM the chosen quality reached
+	batch      core.BatchResult
+	err        error
+}
+
+// SetSize sets the terminal size, which the file picker fits its list to
+func (m *CompressorModel) SetSize(width, height int) {
+	m.filePicker.SetSize(width, height)
+}
+
+// Init starts loading the file picker's preview
+func (m *CompressorModel) Init() tea.Cmd {
+	return m.filePicker.Init()
 }
 
 // Update handles input
@@ -116,13 +177,31 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 			if m.filePicker.IsCancelled() {
 				m.backToMenu = true
 				m.done = true
+			} else if m.filePicker.SelectedIsDir() {
+				files, err := folderFiles(m.filePicker.SelectedFile(), true, true)
+				if err != nil {
+					m.filePicker.Reset()
+					m.filePicker.err = err
+					return m, cmd
+				}
+				m.inputDir = m.filePicker.SelectedFile()
+				m.inputFiles = files
+				m.inputFile = files[0]
+				m.inputSize = 0
+				for _, f := range files {
+					if info, err := os.Stat(f); err == nil {
+						m.inputSize += info.Size()
+					}
+				}
+				m.step = CompressStepSelectMethod
 			} else {
+				m.inputDir = ""
+				m.inputFiles = nil
 				m.inputFile = m.filePicker.SelectedFile()
 				// Get input file size
 				if info, err := os.Stat(m.inputFile); err == nil {
 					m.inputSize = info.Size()
 				}
-				m.buildOutputPath()
 				m.step = CompressStepSelectMethod
 			}
 		}
@@ -145,16 +224,14 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 					m.methodCursor = 0
 				}
 			case key.Matches(msg, keys.Enter):
-				m.method = core.CompressMethod(m.methodCursor)
-				if m.method == core.CompressMethodPercent {
-					m.step = CompressStepSetPercent
-					m.percentInput.Focus()
-					return m, textinput.Blink
-				} else {
-					m.step = CompressStepSetFixedSize
-					m.sizeInput.Focus()
-					return m, textinput.Blink
+				method := core.CompressMethod(m.methodCursor)
+				if method == core.CompressMethodQuality && m.hasPDF() {
+					m.methodNote = "Quality floor works on images only"
+					return m, nil
 				}
+				m.method = method
+				m.methodNote = ""
+				return m, m.editTarget()
 			case key.Matches(msg, keys.Back):
 				m.step = CompressStepSelectFile
 				m.filePicker.Reset()
@@ -186,7 +263,7 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 				}
 				// Calculate target bytes
 				m.targetBytes = m.inputSize * int64(m.targetPercent) / 100
-				m.step = CompressStepConfirm
+				m.enterConfirm()
 				m.percentInput.Blur()
 				return m, nil
 			case "esc":
@@ -219,7 +296,7 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 					default:
 						m.targetBytes = int64(m.sizeValue)
 					}
-					m.step = CompressStepConfirm
+					m.enterConfirm()
 					m.unitInput.Blur()
 					return m, nil
 				case "esc":
@@ -270,7 +347,7 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 						m.sizeValue = parsedSize
 						m.sizeUnit = "KB"
 						m.targetBytes = int64(m.sizeValue * 1024)
-						m.step = CompressStepConfirm
+						m.enterConfirm()
 						m.sizeInput.Blur()
 					}
 					return m, nil
@@ -282,7 +359,7 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 						m.sizeValue = parsedSize
 						m.sizeUnit = "MB"
 						m.targetBytes = int64(m.sizeValue * 1024 * 1024)
-						m.step = CompressStepConfirm
+						m.enterConfirm()
 						m.sizeInput.Blur()
 					}
 					return m, nil
@@ -294,7 +371,7 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 						m.sizeValue = parsedSize
 						m.sizeUnit = "B"
 						m.targetBytes = int64(m.sizeValue)
-						m.step = CompressStepConfirm
+						m.enterConfirm()
 						m.sizeInput.Blur()
 					}
 					return m, nil
@@ -322,7 +399,7 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 					default:
 						m.targetBytes = int64(m.sizeValue)
 					}
-					m.step = CompressStepConfirm
+					m.enterConfirm()
 					m.unitInput.Blur()
 					return m, nil
 				case "esc":
@@ -338,23 +415,66 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 		m.sizeInput, cmd = m.sizeInput.Update(msg)
 		return m, cmd
 
+	case CompressStepSetQuality:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "enter":
+				m.minSSIM = parseMinSSIM(m.qualityInput.Value())
+				m.enterConfirm()
+				m.qualityInput.Blur()
+				return m, nil
+			case "w", "W": // Let the search try WebP too
+				m.allowWebP = !m.allowWebP
+				return m, nil
+			case "esc":
+				m.step = CompressStepSelectMethod
+				m.qualityInput.Blur()
+				return m, nil
+			}
+		}
+		m.qualityInput, cmd = m.qualityInput.Update(msg)
+		return m, cmd
+
 	case CompressStepConfirm:
+		if m.nameTemplate.editing {
+			switch msg := msg.(type) {
+			case tea.KeyMsg:
+				switch msg.String() {
+				case "enter":
+					if m.nameTemplate.apply() == nil {
+						m.buildOutputPath()
+					}
+					return m, nil
+				case "esc":
+					m.nameTemplate.stop()
+					return m, nil
+				}
+			}
+			return m, m.nameTemplate.update(msg)
+		}
+
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "y", "Y", "enter":
+				if m.outputErr != nil {
+					return m, nil
+				}
+				// It runs in the background; the Jobs view reopens the
+				// wizard on it
 				m.step = CompressStepCompressing
-				return m, m.runCompression
+				m.jobID = m.jobs.Submit(m.jobTitle(), m.compressionJob())
+				m.submitted = m.jobID
+				m.backToMenu = true
+				m.done = true
+				return m, nil
+			case "t": // Edit the output name template
+				return m, m.nameTemplate.start()
+			case "c": // Cycle what happens to an existing output
+				m.collision = m.collision.Next()
 			case "n", "N", "esc":
-				if m.method == core.CompressMethodPercent {
-					m.step = CompressStepSetPercent
-					m.percentInput.Focus()
-					return m, textinput.Blink
-				} else {
-					m.step = CompressStepSetFixedSize
-					m.sizeInput.Focus()
-					return m, textinput.Blink
-				}
+				return m, m.editTarget()
 			case "b":
 				m.backToMenu = true
 				m.done = true
@@ -364,17 +484,79 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 
 	case CompressStepCompressing:
 		switch msg := msg.(type) {
+		case jobsChangedMsg:
+			if job, ok := finishedJob(m.jobs, m.jobID); ok {
+				result, ok := job.Outcome.Value.(compressResultMsg)
+				if !ok {
+					result = compressResultMsg{message: "Compression cancelled", isError: true, err: job.Outcome.Err}
+				}
+				return m.Update(result)
+			}
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "x": // Cancel the compression
+				m.jobs.Cancel(m.jobID)
+			case "esc", "m": // Leave it running in the background
+				m.backToMenu = true
+				m.done = true
+			}
 		case compressResultMsg:
 			m.step = CompressStepDone
 			m.result = msg.message
 			m.isError = msg.isError
+			m.details = newErrorDetails(msg.err)
 			m.outputSize = msg.outputSize
-			return m, nil
+			m.quality = msg.quality
+			m.score = msg.score
+			m.batch = msg.batch
+			if msg.outputPath != "" {
+				// The collision policy may have chosen another name
+				m.outputFile = msg.outputPath
+			}
+			m.written = ""
+			if !msg.isError && !msg.skipped {
+				m.written = msg.outputPath
+			}
+			if m.inputDir != "" {
+				return m, m.preview.request(firstOutput(m.batch))
+			}
+			if !msg.isError && !msg.skipped {
+				// Let the user judge the result before keeping it
+				m.step = CompressStepCompare
+				return m, m.comparison.start(m.inputFile, m.outputFile)
+			}
+			return m, m.preview.request(msg.outputPath)
+		}
+		return m, nil
+
+	case CompressStepCompare:
+		switch msg := msg.(type) {
+		case previewMsg, compareMsg:
+			m.comparison.update(msg)
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "y", "Y", "enter": // Keep the output
+				m.comparison.clear()
+				m.step = CompressStepDone
+				return m, m.preview.request(m.outputFile)
+			case "r", "R": // Discard the output and pick another target
+				m.discardOutput()
+				m.comparison.clear()
+				return m, m.editTarget()
+			case "esc", "m": // Keep the output and leave
+				m.comparison.clear()
+				m.backToMenu = true
+				m.done = true
+			case "q":
+				return m, tea.Quit
+			}
 		}
 		return m, nil
 
 	case CompressStepDone:
 		switch msg := msg.(type) {
+		case previewMsg:
+			m.preview.update(msg)
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter", "m":
@@ -384,8 +566,17 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 				m.step = CompressStepSelectFile
 				m.filePicker.Reset()
 				m.inputFile = ""
+				m.inputDir = ""
+				m.inputFiles = nil
 				m.outputFile = ""
 				m.result = ""
+				m.preview.request("")
+			case "d": // Toggle raw error output
+				m.details.toggle()
+			case "l": // View logs after a failure
+				if m.isError {
+					m.showLogs = true
+				}
 			case "q":
 				return m, tea.Quit
 			}
@@ -396,45 +587,215 @@ func (m *CompressorModel) Update(msg tea.Msg) (*CompressorModel, tea.Cmd) {
 	return m, nil
 }
 
-// buildOutputPath creates the output file path
+// editTarget returns to the target step of the chosen method
+func (m *CompressorModel) editTarget() tea.Cmd {
+	switch m.method {
+	case core.CompressMethodPercent:
+		m.step = CompressStepSetPercent
+		m.percentInput.Focus()
+	case core.CompressMethodQuality:
+		m.step = CompressStepSetQuality
+		m.qualityInput.Focus()
+	default:
+		m.step = CompressStepSetFixedSize
+		m.sizeInput.Focus()
+	}
+	return textinput.Blink
+}
+
+// parseMinSSIM reads a quality floor as a fraction ("0.95") or a
+// percentage ("95"), falling back to the default
+func parseMinSSIM(val string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(val), "%"), 64)
+	if err != nil {
+		return config.DefaultMinSSIM
+	}
+	if v > 1 {
+		v /= 100
+	}
+	if v <= 0 || v >= 1 {
+		return config.DefaultMinSSIM
+	}
+	return v
+}
+
+// qualityFormats lists the formats the quality search may choose from
+func (m *CompressorModel) qualityFormats() []core.ImageFormat {
+	if m.allowWebP {
+		return []core.ImageFormat{core.FormatJPG, core.FormatWebP}
+	}
+	return nil
+}
+
+// discardOutput removes an output the user rejected after comparing it.
+// Only the file this compression wrote is removed, never the input.
+func (m *CompressorModel) discardOutput() {
+	output := m.written
+	m.written = ""
+	m.outputFile = ""
+	m.result = ""
+	if output == "" {
+		return
+	}
+	if err := core.CheckNotInput(m.inputFile, output); err != nil {
+		logging.Warn("Kept rejected output", map[string]interface{}{
+			"output": output,
+			"error":  err.Error(),
+		})
+		return
+	}
+	if err := os.Remove(output); err != nil && !os.IsNotExist(err) {
+		logging.Warn("Could not remove rejected output", map[string]interface{}{
+			"output": output,
+			"error":  err.Error(),
+		})
+		return
+	}
+	logging.Info("Rejected compressed output removed", map[string]interface{}{
+		"output": output,
+	})
+}
+
+// enterConfirm shows the summary once the target is set
+func (m *CompressorModel) enterConfirm() {
+	m.buildOutputPath()
+	m.step = CompressStepConfirm
+}
+
+// buildOutputPath creates the output file path from the name template,
+// next to the input. In folder mode it checks the outputs of the first
+// outputCheckLimit files and previews the first.
 func (m *CompressorModel) buildOutputPath() {
-	dir := filepath.Dir(m.inputFile)
-	base := strings.TrimSuffix(filepath.Base(m.inputFile), filepath.Ext(m.inputFile))
-	ext := filepath.Ext(m.inputFile)
+	inputs := []string{m.inputFile}
+	m.unchecked = 0
+	if m.inputDir != "" {
+		inputs, m.unchecked = checkedInputs(m.inputFiles)
+	}
+
+	// Backwards, so the editor is left previewing the first file
+	m.outputErr = nil
+	outputs := make([]string, 0, len(inputs))
+	for i := len(inputs) - 1; i >= 0; i-- {
+		output, err := m.outputPathFor(inputs[i])
+		if err != nil {
+			m.outputErr = err
+		}
+		outputs = append(outputs, output)
+	}
+	m.outputFile = outputs[len(outputs)-1]
+	m.existing = core.ExistingOutputs(outputs)
+}
 
+// outputPathFor returns the output path for one input, leaving the name
+// template editor previewing it. It fails when the template names the
+// input itself.
+func (m *CompressorModel) outputPathFor(input string) (string, error) {
 	// For compression, we output as JPG for images (better compression)
 	// Keep PDF as PDF
-	if strings.ToLower(ext) != ".pdf" {
-		ext = ".jpg"
+	ext := core.CompressOutputFormat(input)
+
+	preset := core.CompressPreset(m.method, m.targetPercent, m.targetBytes, m.minSSIM)
+	m.nameTemplate.setInput(input, ext, preset, 1)
+	name, err := m.nameTemplate.expand(m.nameTemplate.value)
+	if errors.Is(err, core.ErrOutputIsInput) {
+		return input, err
 	}
+	if err != nil {
+		base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		name = base + "_comp." + ext
+	}
+	return filepath.Join(filepath.Dir(input), name), nil
+}
+
+// jobTitle names the compression in the jobs list and history
+func (m *CompressorModel) jobTitle() string {
+	if m.inputDir != "" {
+		return fmt.Sprintf("Compress %s (%d files)", filepath.Base(m.inputDir), len(m.inputFiles))
+	}
+	return "Compress " + filepath.Base(m.inputFile)
+}
 
-	m.outputFile = filepath.Join(dir, base+"_comp"+ext)
+// compressionJob returns the job that runs the compression. It works on a
+// copy of the settings, so the wizard can move on and a retry repeats the
+// same compression.
+func (m *CompressorModel) compressionJob() jobs.Func {
+	settings := *m
+	return func(ctx context.Context, progress jobs.Progress) jobs.Outcome {
+		result := settings.runCompression(ctx, progress)
+		return jobs.Outcome{Message: result.message, Err: result.err, Value: result}
+	}
+}
+
+// historyEntry describes the compression for the history
+func (m *CompressorModel) historyEntry() history.Entry {
+	inputs := []string{m.inputFile}
+	if m.inputDir != "" {
+		inputs = m.inputFiles
+	}
+	var formats []string
+	for _, f := range m.qualityFormats() {
+		formats = append(formats, string(f))
+	}
+	return history.Entry{
+		Operation: history.OpCompress,
+		Title:     m.jobTitle(),
+		Inputs:    inputs,
+		InputDir:  m.inputDir,
+		Options: history.Options{
+			Method:       compressMethodNames[m.method],
+			Percent:      m.targetPercent,
+			TargetBytes:  m.targetBytes,
+			MinSSIM:      m.minSSIM,
+			Formats:      formats,
+			NameTemplate: m.nameTemplate.value,
+			Collision:    m.collision.String(),
+		},
+	}
 }
 
 // runCompression executes compression via core package
-func (m *CompressorModel) runCompression() tea.Msg {
+func (m *CompressorModel) runCompression(ctx context.Context, progress jobs.Progress) compressResultMsg {
+	if m.inputDir != "" {
+		return m.runBatchCompression(ctx, progress)
+	}
+	start := time.Now()
+
 	logging.Info("Starting compression", map[string]interface{}{
 		"input":       m.inputFile,
 		"method":      m.method,
 		"targetBytes": m.targetBytes,
+		"minSSIM":     m.minSSIM,
 	})
 
-	result := core.CompressFile(core.CompressOptions{
+	result := core.CompressFileContext(ctx, core.CompressOptions{
 		InputPath:     m.inputFile,
 		Method:        m.method,
 		TargetPercent: m.targetPercent,
 		TargetBytes:   m.targetBytes,
+		MinSSIM:       m.minSSIM,
+		Formats:       m.qualityFormats(),
 		OutputPath:    m.outputFile,
+		Collision:     m.collision,
+		Limits:        config.CoreLimits(m.cfg),
+		Timeout:       config.CoreTimeout(m.cfg),
 	})
 
+	entry := m.historyEntry()
+	entry.Options.Output = m.outputFile
+	entry.Outputs = resultOutputs(result)
+	entry.OutputSize = result.OutputSize
+	recordHistory(entry, start, result.Success, result.Message)
+
 	if !result.Success {
 		logging.Error("Compression failed", map[string]interface{}{
-			"input": m.inputFile,
-			"error": result.Message,
+			"input":   m.inputFile,
+			"error":   result.Message,
+			"details": result.Error,
 		})
 		return compressResultMsg{
 			message: result.Message,
 			isError: true,
+			err:     result.Error,
 		}
 	}
 
@@ -442,12 +803,77 @@ func (m *CompressorModel) runCompression() tea.Msg {
 		"input":      m.inputFile,
 		"output":     result.OutputPath,
 		"outputSize": result.OutputSize,
+		"quality":    result.Quality,
+		"ssim":       result.Score,
 	})
 
 	return compressResultMsg{
 		message:    result.Message,
 		isError:    false,
+		outputPath: result.OutputPath,
 		outputSize: result.OutputSize,
+		skipped:    result.Skipped,
+		quality:    result.Quality,
+		score:      result.Score,
+	}
+}
+
+// runBatchCompression compresses every image and PDF of the selected folder
+func (m *CompressorModel) runBatchCompression(ctx context.Context, progress jobs.Progress) compressResultMsg {
+	logging.Info("Starting folder compression", map[string]interface{}{
+		"dir":    m.inputDir,
+		"files":  len(m.inputFiles),
+		"method": m.method,
+	})
+
+	start := time.Now()
+	opts := core.BatchCompressOptions{
+		Method:        m.method,
+		TargetPercent: m.targetPercent,
+		TargetBytes:   m.targetBytes,
+		MinSSIM:       m.minSSIM,
+		Formats:       m.qualityFormats(),
+		NameTemplate:  m.nameTemplate.value,
+		Collision:     m.collision,
+		Limits:        config.CoreLimits(m.cfg),
+		Timeout:       config.CoreTimeout(m.cfg),
+	}
+	manifest, err := batchManifest(m.manifest, core.ManifestHeader{
+		Operation: core.BatchOpCompress,
+		Title:     m.jobTitle(),
+		InputDir:  m.inputDir,
+		Inputs:    m.inputFiles,
+		Compress:  &opts,
+	})
+	if err != nil {
+		return compressResultMsg{message: err.Error(), isError: true, err: err}
+	}
+	m.manifest = manifest
+
+	run := opts
+	run.Progress, run.Manifest = progress, m.manifest
+	batch := core.BatchCompress(ctx, m.inputFiles, run)
+	finishManifest(m.manifest)
+
+	logging.Info("Folder compression completed", map[string]interface{}{
+		"dir":        m.inputDir,
+		"compressed": batch.SuccessCount,
+		"skipped":    batch.SkippedCount,
+		"failed":     batch.FailCount,
+	})
+
+	err = batchError(batch)
+	message := batchMessage("Compressed", batch)
+	entry := m.historyEntry()
+	entry.Outputs = batchOutputs(batch)
+	recordHistory(entry, start, err == nil, message)
+
+	return compressResultMsg{
+		message:    message,
+		isError:    err != nil,
+		outputSize: batch.TotalOutputSize,
+		batch:      batch,
+		err:        err,
 	}
 }
 
@@ -469,7 +895,11 @@ func (m *CompressorModel) View() string {
 		b.WriteString(inputLabelStyle.Render("Select compression method:"))
 		b.WriteString("\n\n")
 
-		b.WriteString(descriptionStyle.Render(fmt.Sprintf("Input file: %s (%s)", filepath.Base(m.inputFile), core.FormatSize(m.inputSize))))
+		if m.inputDir != "" {
+			b.WriteString(descriptionStyle.Render(fmt.Sprintf("Input folder: %s (%d files, %s)", m.inputDir, len(m.inputFiles), core.FormatSize(m.inputSize))))
+		} else {
+			b.WriteString(descriptionStyle.Render(fmt.Sprintf("Input file: %s (%s)", filepath.Base(m.inputFile), core.FormatSize(m.inputSize))))
+		}
 		b.WriteString("\n\n")
 
 		for i, method := range m.methods {
@@ -485,15 +915,23 @@ func (m *CompressorModel) View() string {
 			// Description for selected
 			if i == m.methodCursor {
 				desc := ""
-				if i == 0 {
+				switch core.CompressMethod(i) {
+				case core.CompressMethodPercent:
 					desc = "    Compress to a percentage of original size (e.g., 50%)"
-				} else {
+				case core.CompressMethodFixedSize:
 					desc = "    Compress to exact target size (e.g., 100KB)"
+				default:
+					desc = "    Smallest file that still looks like the original (SSIM ≥ 0.95)"
 				}
 				b.WriteString(descriptionStyle.Render(desc))
 				b.WriteString("\n")
 			}
 		}
+		if m.methodNote != "" {
+			b.WriteString("\n")
+			b.WriteString(warningStyle.Render("⚠️  " + m.methodNote))
+			b.WriteString("\n")
+		}
 		b.WriteString("\n")
 		b.WriteString(helpStyle.Render("↑↓ Navigate • Enter Select • Esc Back"))
 
@@ -533,57 +971,153 @@ func (m *CompressorModel) View() string {
 		b.WriteString("\n\n")
 		b.WriteString(helpStyle.Render("Enter to continue • K=KB M=MB • Esc Back"))
 
+	case CompressStepSetQuality:
+		b.WriteString(inputLabelStyle.Render("Minimum SSIM (0-1, or a percentage):"))
+		b.WriteString("\n\n")
+		b.WriteString(m.qualityInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(descriptionStyle.Render("0.98+ looks identical • 0.95 hard to tell apart • 0.90 visible on close inspection"))
+		b.WriteString("\n")
+		formats := "JPG"
+		if m.allowWebP {
+			formats = "JPG or WebP, whichever is smaller"
+		}
+		b.WriteString(descriptionStyle.Render("Output format: " + formats))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Enter to confirm • W Toggle WebP • Esc Back"))
+
 	case CompressStepConfirm:
 		b.WriteString(inputLabelStyle.Render("Compression Summary"))
 		b.WriteString("\n\n")
 
 		methodStr := "Percentage"
 		targetStr := fmt.Sprintf("%d%% of original", m.targetPercent)
-		if m.method == core.CompressMethodFixedSize {
+		switch m.method {
+		case core.CompressMethodFixedSize:
 			methodStr = "Fixed Size"
 			targetStr = fmt.Sprintf("%.2g %s", m.sizeValue, m.sizeUnit)
+		case core.CompressMethodQuality:
+			methodStr = "Quality Floor"
+			targetStr = fmt.Sprintf("Smallest file with SSIM ≥ %g", m.minSSIM)
+			if m.allowWebP {
+				targetStr += " (JPG or WebP)"
+			}
 		}
 
-		summaryBox := boxStyle.Render(
-			fmt.Sprintf("Input:   %s (%s)\n", filepath.Base(m.inputFile), core.FormatSize(m.inputSize)) +
+		var summary string
+		if m.inputDir != "" {
+			if m.method == core.CompressMethodFixedSize {
+				targetStr += " each"
+			}
+			summary = fmt.Sprintf("Folder:  %s (%d files, %s)\n", m.inputDir, len(m.inputFiles), core.FormatSize(m.inputSize)) +
+				fmt.Sprintf("Method:  %s\n", methodStr) +
+				fmt.Sprintf("Target:  %s\n", targetStr) +
+				fmt.Sprintf("Output:  %s, ...\n", filepath.Base(m.outputFile))
+		} else {
+			if m.method != core.CompressMethodQuality {
+				targetStr += fmt.Sprintf(" (%s)", core.FormatSize(m.targetBytes))
+			}
+			summary = fmt.Sprintf("Input:   %s (%s)\n", filepath.Base(m.inputFile), core.FormatSize(m.inputSize)) +
 				fmt.Sprintf("Method:  %s\n", methodStr) +
-				fmt.Sprintf("Target:  %s (%s)\n", targetStr, core.FormatSize(m.targetBytes)) +
-				fmt.Sprintf("Output:  %s", filepath.Base(m.outputFile)),
-		)
+				fmt.Sprintf("Target:  %s\n", targetStr) +
+				fmt.Sprintf("Output:  %s\n", filepath.Base(m.outputFile))
+		}
+		summaryBox := boxStyle.Render(summary + fmt.Sprintf("Exists:  %s", m.collision))
 		b.WriteString(summaryBox)
 		b.WriteString("\n\n")
 
-		if strings.ToLower(filepath.Ext(m.inputFile)) == ".pdf" {
+		if warning := collisionWarning(m.existing, m.unchecked, m.collision); warning != "" {
+			b.WriteString(warningStyle.Render(warning))
+			b.WriteString("\n\n")
+		}
+		if m.outputErr != nil {
+			b.WriteString(errorStyle.Render(IconError + " " + m.outputErr.Error() + ". Press T to change the name template"))
+			b.WriteString("\n\n")
+		}
+
+		if m.hasPDF() {
 			b.WriteString(warningStyle.Render("⚠️  PDF compression may rasterize content"))
 			b.WriteString("\n\n")
 		}
 
+		if m.nameTemplate.editing {
+			b.WriteString(inputLabelStyle.Render("Output name template:"))
+			b.WriteString("\n\n")
+			b.WriteString(m.nameTemplate.View())
+			b.WriteString("\n\n")
+			b.WriteString(helpStyle.Render("Enter Apply • Esc Cancel"))
+			break
+		}
 		b.WriteString(warningStyle.Render("Proceed with compression? (Y/n)"))
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Y/Enter Proceed • N/Esc Back • B Menu"))
+		b.WriteString(helpStyle.Render("Y/Enter Proceed • T Edit Name • C If Exists • N/Esc Back • B Menu"))
 
 	case CompressStepCompressing:
 		b.WriteString("\n")
-		b.WriteString(progressStyle.Render("⏳ Compressing... Please wait"))
-		b.WriteString("\n")
+		b.WriteString(progressStyle.Render(jobProgress(m.jobs, m.jobID, "Compressing")))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Esc/M Menu (keeps running) • X Cancel"))
+
+	case CompressStepCompare:
+		b.WriteString(successStyle.Render(IconSuccess + " " + m.result))
+		b.WriteString("\n\n")
+		b.WriteString(m.comparison.View(m.inputSize, m.outputSize))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Y/Enter Keep Result • R Retry With Another Target • Esc/M Menu (keeps result) • Q Quit"))
 
 	case CompressStepDone:
 		if m.isError {
 			b.WriteString(errorStyle.Render(IconError + " " + m.result))
+			b.WriteString(m.details.View())
 		} else {
 			b.WriteString(successStyle.Render(IconSuccess + " " + m.result))
 			b.WriteString("\n\n")
-			b.WriteString(descriptionStyle.Render(fmt.Sprintf("Original: %s → Compressed: %s", core.FormatSize(m.inputSize), core.FormatSize(m.outputSize))))
-			b.WriteString("\n")
-			b.WriteString(descriptionStyle.Render(fmt.Sprintf("Output: %s", m.outputFile)))
+			if m.inputDir != "" {
+				b.WriteString(descriptionStyle.Render(fmt.Sprintf("Original: %s → Compressed: %s", core.FormatSize(m.batch.TotalInputSize), core.FormatSize(m.outputSize))))
+				b.WriteString("\n")
+				b.WriteString(descriptionStyle.Render(fmt.Sprintf("Output: %s", m.inputDir)))
+			} else {
+				b.WriteString(descriptionStyle.Render(fmt.Sprintf("Original: %s → Compressed: %s", core.FormatSize(m.inputSize), core.FormatSize(m.outputSize))))
+				b.WriteString("\n")
+				if m.method == core.CompressMethodQuality {
+					b.WriteString(descriptionStyle.Render(fmt.Sprintf("Quality: %d • SSIM %.4f (floor %g)", m.quality, m.score, m.minSSIM)))
+					b.WriteString("\n")
+				}
+				b.WriteString(descriptionStyle.Render(fmt.Sprintf("Output: %s", m.outputFile)))
+			}
+		}
+		if failures := batchFailures(m.inputFiles, m.batch); failures != "" {
+			b.WriteString("\n\n")
+			b.WriteString(errorStyle.Render(failures))
+		}
+		if preview := m.preview.View(); preview != "" {
+			b.WriteString("\n\n")
+			b.WriteString(preview)
 		}
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Enter/M Menu • A Compress Another • Q Quit"))
+		if m.isError {
+			b.WriteString(helpStyle.Render("Enter/M Menu • A Compress Another • L View Logs" + m.details.helpKey() + " • Q Quit"))
+		} else {
+			b.WriteString(helpStyle.Render("Enter/M Menu • A Compress Another • Q Quit"))
+		}
 	}
 
 	return b.String()
 }
 
+// hasPDF reports whether any input is a PDF
+func (m *CompressorModel) hasPDF() bool {
+	if m.inputDir == "" {
+		return strings.ToLower(filepath.Ext(m.inputFile)) == ".pdf"
+	}
+	for _, f := range m.inputFiles {
+		if strings.ToLower(filepath.Ext(f)) == ".pdf" {
+			return true
+		}
+	}
+	return false
+}
+
 // IsDone returns true if compression flow is complete
 func (m *CompressorModel) IsDone() bool {
 	return m.done
@@ -593,3 +1127,32 @@ func (m *CompressorModel) IsDone() bool {
 func (m *CompressorModel) BackToMenu() bool {
 	return m.backToMenu
 }
+
+// WantsLogs reports whether the user asked to view logs from the Done
+// step, clearing the request
+func (m *CompressorModel) WantsLogs() bool {
+	wants := m.showLogs
+	m.showLogs = false
+	return wants
+}
+
+// previewPanes returns the previews the wizard may show
+func (m *CompressorModel) previewPanes() []*previewPane {
+	return []*previewPane{&m.preview, &m.filePicker.preview, &m.comparison.before, &m.comparison.after}
+}
+
+// Submitted returns the job submitted since the last call, or 0
+func (m *CompressorModel) Submitted() int {
+	id := m.submitted
+	m.submitted = 0
+	return id
+}
+
+// Follow reopens the wizard on job id, one it submitted or a retry of
+// one, showing its progress and then its result
+func (m *CompressorModel) Follow(id int) {
+	m.jobID = id
+	m.step = CompressStepCompressing
+	m.done = false
+	m.backToMenu = false
+}
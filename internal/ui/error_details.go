@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	"imagetool/internal/core"
+)
+
+// maxDetailLines caps how much raw command output a Done screen shows
+const maxDetailLines = 15
+
+// errorDetails holds the hint and raw output of a failed operation for
+// display on a Done screen
+type errorDetails struct {
+	hint     string
+	output   string
+	command  string
+	expanded bool
+}
+
+// newErrorDetails extracts the hint and output from a core error
+func newErrorDetails(err error) errorDetails {
+	var perr *core.ProcessError
+	if !errors.As(err, &perr) {
+		return errorDetails{}
+	}
+	return errorDetails{hint: perr.Hint, output: perr.Output, command: perr.Command}
+}
+
+// toggle shows or hides the raw output
+func (d *errorDetails) toggle() {
+	if d.hasOutput() {
+		d.expanded = !d.expanded
+	}
+}
+
+// hasOutput reports whether there is raw output to expand
+func (d errorDetails) hasOutput() bool {
+	return d.output != "" || d.command != ""
+}
+
+// View renders the hint and, when expanded, the last lines of raw output
+func (d errorDetails) View() string {
+	var b strings.Builder
+
+	if d.hint != "" {
+		b.WriteString("\n\n")
+		b.WriteString(warningStyle.Render(IconWarning + "  " + d.hint))
+	}
+
+	if d.expanded {
+		lines := strings.Split(d.output, "\n")
+		if len(lines) > maxDetailLines {
+			lines = append([]string{"..."}, lines[len(lines)-maxDetailLines:]...)
+		}
+		b.WriteString("\n\n")
+		b.WriteString(inputLabelStyle.Render("Details:"))
+		b.WriteString("\n")
+		if d.command != "" {
+			lines = append([]string{"$ " + d.command, ""}, lines...)
+		}
+		b.WriteString(boxStyle.Render(strings.TrimSpace(strings.Join(lines, "\n"))))
+	}
+
+	return b.String()
+}
+
+// helpKey returns the help text for the details toggle, or "" without output
+func (d errorDetails) helpKey() string {
+	switch {
+	case !d.hasOutput():
+		return ""
+	case d.expanded:
+		return " • D Hide Details"
+	default:
+		return " • D Show Details"
+	}
+}
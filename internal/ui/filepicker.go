@@ -4,14 +4,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"imagetool/internal/config"
 	"imagetool/internal/core"
 	"imagetool/internal/logging"
 )
@@ -25,44 +27,97 @@ const (
 	FilePickerAll
 )
 
+// parentDirName is the entry that leads to the parent directory
+const parentDirName = ".."
+
+// minPreviewWidth is the terminal width needed to show the preview beside
+// the list
+const minPreviewWidth = 100
+
 // FileEntry represents a file or directory
 type FileEntry struct {
-	Name  string
-	Path  string
-	IsDir bool
-	Size  int64
+	Name     string
+	Path     string
+	IsDir    bool
+	Size     int64
+	ModTime  time.Time
+	Type     core.FileType // Detected from content
+	Mismatch bool          // Extension claims a different type
+}
+
+// place is a folder offered by the "go to" list
+type place struct {
+	label string
+	path  string
 }
 
 // FilePickerModel handles file selection
 type FilePickerModel struct {
 	mode         FilePickerMode
+	cfg          *config.Config // Bookmarks and recent folders, may be nil
 	currentDir   string
-	entries      []FileEntry
+	dirs         []FileEntry // Subdirectories of currentDir
+	files        []FileEntry // Matching files of currentDir
+	entries      []FileEntry // What is shown: sorted and filtered
+	fileCount    int         // Files in entries
+	sortMode     pickerSort
 	cursor       int
+	width        int // Terminal size, 0 until known
+	height       int
 	selectedFile string
+	selectedDir  bool // The selection is a whole directory
+	allowDirs    bool // 's' selects the current directory
 	done         bool
 	cancelled    bool
 	err          error
+	notice       string
 
 	// For manual path input
 	showInput bool
 	pathInput textinput.Model
+
+	// Fuzzy filter over entry names
+	filtering   bool
+	query       string
+	filterInput textinput.Model
+
+	// Drives, home, bookmarks and recent folders
+	showPlaces  bool
+	places      []place
+	placeCursor int
+
+	// Thumbnail of the highlighted file
+	preview previewPane
 }
 
-// NewFilePickerModel creates a new file picker
-func NewFilePickerModel() *FilePickerModel {
+// NewFilePickerModel creates a new file picker. It opens in the last used
+// directory, or next to the executable.
+func NewFilePickerModel(cfg *config.Config) *FilePickerModel {
 	ti := textinput.New()
 	ti.Placeholder = "Enter file path..."
 	ti.CharLimit = 500
 	ti.Width = 60
 
-	// Get executable directory instead of working directory
-	execDir := getExecutableDir()
+	fi := textinput.New()
+	fi.Placeholder = "type to filter..."
+	fi.Prompt = "/"
+	fi.CharLimit = 100
+	fi.Width = 40
+
+	startDir := getExecutableDir()
+	if cfg != nil && cfg.LastDirectory != "" {
+		if info, err := os.Stat(cfg.LastDirectory); err == nil && info.IsDir() {
+			startDir = cfg.LastDirectory
+		}
+	}
 
 	fp := &FilePickerModel{
-		mode:       FilePickerAll,
-		currentDir: execDir,
-		pathInput:  ti,
+		mode:        FilePickerAll,
+		cfg:         cfg,
+		currentDir:  startDir,
+		pathInput:   ti,
+		filterInput: fi,
+		preview:     newPreviewPane(cfg),
 	}
 	fp.loadFiles()
 	return fp
@@ -90,16 +145,94 @@ func (fp *FilePickerModel) SetMode(mode FilePickerMode) {
 	fp.loadFiles()
 }
 
+// SetAllowDirectory lets the user select the current directory with 's'
+// for batch operations
+func (fp *FilePickerModel) SetAllowDirectory(allow bool) {
+	fp.allowDirs = allow
+}
+
+// SetSize sets the terminal size used to fit the list on screen
+func (fp *FilePickerModel) SetSize(width, height int) {
+	fp.width = width
+	fp.height = height
+}
+
+// showPreview reports whether the preview fits beside the list
+func (fp *FilePickerModel) showPreview() bool {
+	return fp.preview.enabled() && fp.width >= minPreviewWidth
+}
+
+// previewPath returns the highlighted file while the list is shown with a
+// preview, or ""
+func (fp *FilePickerModel) previewPath() string {
+	if !fp.showPreview() || fp.showInput || fp.showPlaces || fp.done || len(fp.entries) == 0 {
+		return ""
+	}
+	if entry := fp.entries[fp.cursor]; !entry.IsDir {
+		return entry.Path
+	}
+	return ""
+}
+
+// Init starts loading the preview of the highlighted file
+func (fp *FilePickerModel) Init() tea.Cmd {
+	return fp.preview.request(fp.previewPath())
+}
+
+// pageSize returns how many entries fit on screen
+func (fp *FilePickerModel) pageSize() int {
+	if fp.height <= 0 {
+		return 15
+	}
+	// Wizard and picker headers, status lines and help take about 18 lines
+	if size := fp.height - 18; size > 5 {
+		return size
+	}
+	return 5
+}
+
 // SetDirectory changes the current directory
 func (fp *FilePickerModel) SetDirectory(dir string) {
 	fp.currentDir = dir
 	fp.loadFiles()
 }
 
-// loadFiles reads only matching files from current directory (no subdirectories)
+// changeDir moves to dir, staying put if it can't be read
+func (fp *FilePickerModel) changeDir(dir string) {
+	dir = filepath.Clean(dir)
+	if _, err := os.ReadDir(dir); err != nil {
+		fp.err = fmt.Errorf("cannot open %s: %v", dir, err)
+		logging.Warn("Cannot open directory", map[string]interface{}{"path": dir, "error": err.Error()})
+		return
+	}
+	fp.err = nil
+	fp.notice = ""
+	fp.clearFilter()
+	fp.currentDir = dir
+	fp.loadFiles()
+}
+
+// goUp moves to the parent directory and highlights the one it came from
+func (fp *FilePickerModel) goUp() {
+	parent := filepath.Dir(fp.currentDir)
+	if parent == fp.currentDir {
+		return
+	}
+	left := filepath.Base(fp.currentDir)
+	fp.changeDir(parent)
+	for i, e := range fp.entries {
+		if e.IsDir && e.Name == left {
+			fp.cursor = i
+			break
+		}
+	}
+}
+
+// loadFiles reads subdirectories and matching files from current directory
 func (fp *FilePickerModel) loadFiles() {
-	fp.entries = []FileEntry{}
-	fp.cursor = 0
+	fp.dirs = nil
+	fp.files = nil
+	defer fp.applyView()
 
 	entries, err := os.ReadDir(fp.currentDir)
 	if err != nil {
@@ -107,17 +240,27 @@ func (fp *FilePickerModel) loadFiles() {
 		return
 	}
 
-	// Only collect files that match the filter (no directories)
-	var files []FileEntry
+	var dirs, files []FileEntry
 
 	for _, entry := range entries {
-		// Skip directories - we only want files
-		if entry.IsDir() {
+		// Skip hidden entries and partial outputs of running or crashed jobs
+		if strings.HasPrefix(entry.Name(), ".") || strings.HasPrefix(entry.Name(), core.TempPrefix) {
 			continue
 		}
 
-		// Check if file matches filter
-		if !fp.matchesFilter(entry.Name()) {
+		path := filepath.Join(fp.currentDir, entry.Name())
+		if isDirEntry(entry, path) {
+			de := FileEntry{Name: entry.Name(), Path: path, IsDir: true}
+			if info, err := entry.Info(); err == nil {
+				de.ModTime = info.ModTime()
+			}
+			dirs = append(dirs, de)
+			continue
+		}
+
+		// Check if file content matches filter
+		fileType := core.DetectType(path)
+		if !fp.matchesFilter(fileType) {
 			continue
 		}
 
@@ -127,20 +270,76 @@ func (fp *FilePickerModel) loadFiles() {
 		}
 
 		fe := FileEntry{
-			Name:  entry.Name(),
-			Path:  filepath.Join(fp.currentDir, entry.Name()),
-			IsDir: false,
-			Size:  info.Size(),
+			Name:     entry.Name(),
+			Path:     path,
+			IsDir:    false,
+			Size:     info.Size(),
+			ModTime:  info.ModTime(),
+			Type:     fileType,
+			Mismatch: core.ExtensionMismatch(path, fileType),
 		}
 		files = append(files, fe)
 	}
 
-	// Sort files alphabetically
-	sort.Slice(files, func(i, j int) bool {
-		return strings.ToLower(files[i].Name) < strings.ToLower(files[j].Name)
-	})
+	fp.dirs = dirs
+	fp.files = files
+	sortFiles(fp.dirs, fp.sortMode)
+	sortFiles(fp.files, fp.sortMode)
+}
 
-	fp.entries = files
+// applyView rebuilds the shown entries from the loaded ones: ".." first,
+// then directories, then files, narrowed by the filter
+func (fp *FilePickerModel) applyView() {
+	fp.entries = []FileEntry{}
+	fp.cursor = 0
+
+	if fp.query == "" && filepath.Dir(fp.currentDir) != fp.currentDir {
+		fp.entries = append(fp.entries, FileEntry{Name: parentDirName, Path: filepath.Dir(fp.currentDir), IsDir: true})
+	}
+	fp.entries = append(fp.entries, fuzzyFilter(fp.dirs, fp.query)...)
+	files := fuzzyFilter(fp.files, fp.query)
+	fp.entries = append(fp.entries, files...)
+	fp.fileCount = len(files)
+}
+
+// cycleSort switches to the next sort mode, keeping the highlighted entry
+func (fp *FilePickerModel) cycleSort() {
+	var current string
+	if len(fp.entries) > 0 {
+		current = fp.entries[fp.cursor].Path
+	}
+
+	fp.sortMode = fp.sortMode.next()
+	sortFiles(fp.dirs, fp.sortMode)
+	sortFiles(fp.files, fp.sortMode)
+	fp.applyView()
+
+	for i, e := range fp.entries {
+		if e.Path == current {
+			fp.cursor = i
+			break
+		}
+	}
+}
+
+// clearFilter removes the fuzzy filter
+func (fp *FilePickerModel) clearFilter() {
+	fp.filtering = false
+	fp.query = ""
+	fp.filterInput.SetValue("")
+	fp.filterInput.Blur()
+}
+
+// isDirEntry reports whether an entry is a directory, following symlinks
+func isDirEntry(entry os.DirEntry, path string) bool {
+	if entry.IsDir() {
+		return true
+	}
+	if entry.Type()&os.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
 }
 
 // looksLikePath checks if the input string looks like a file path
@@ -165,25 +364,105 @@ func looksLikePath(s string) bool {
 	return false
 }
 
-// matchesFilter checks if file matches current mode
-func (fp *FilePickerModel) matchesFilter(name string) bool {
-	ext := strings.ToLower(filepath.Ext(name))
-
+// matchesFilter checks if a detected file type matches current mode
+func (fp *FilePickerModel) matchesFilter(fileType core.FileType) bool {
 	switch fp.mode {
 	case FilePickerPDF:
-		return ext == ".pdf"
+		return fileType == core.TypePDF
 	case FilePickerImage:
-		return core.IsImageFile(name)
+		return fileType.IsImage()
 	case FilePickerAll:
-		return core.IsImageFile(name) || core.IsPDFFile(name)
+		return fileType.IsImage() || fileType == core.TypePDF
 	}
 	return true
 }
 
-// Update handles input
+// mismatchWarning describes a file whose extension doesn't match its content
+func mismatchWarning(entry FileEntry) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(entry.Name)), ".")
+	return fmt.Sprintf("%s  %s content with a .%s extension", IconWarning, entry.Type, ext)
+}
+
+// rootPlaces returns the filesystem roots: the existing drives on Windows,
+// "/" elsewhere
+func rootPlaces() []place {
+	if runtime.GOOS != "windows" {
+		return []place{{label: "/", path: "/"}}
+	}
+	var roots []place
+	for d := 'A'; d <= 'Z'; d++ {
+		root := string(d) + `:\`
+		if _, err := os.Stat(root); err == nil {
+			roots = append(roots, place{label: root, path: root})
+		}
+	}
+	return roots
+}
+
+// loadPlaces builds the "go to" list
+func (fp *FilePickerModel) loadPlaces() {
+	fp.places = rootPlaces()
+	if home, err := os.UserHomeDir(); err == nil {
+		fp.places = append(fp.places, place{label: IconHome + " Home", path: home})
+	}
+	if fp.cfg != nil {
+		for _, dir := range fp.cfg.Bookmarks {
+			fp.places = append(fp.places, place{label: IconBookmark + " " + dir, path: dir})
+		}
+		for _, dir := range fp.cfg.RecentDirs {
+			fp.places = append(fp.places, place{label: IconRecent + " " + dir, path: dir})
+		}
+	}
+	fp.placeCursor = 0
+}
+
+// saveConfig persists bookmark and recent folder changes
+func (fp *FilePickerModel) saveConfig() {
+	if fp.cfg == nil {
+		return
+	}
+	if err := fp.cfg.Save(); err != nil {
+		logging.Warn("Could not save config", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// selectPath completes the picker and remembers the folder
+func (fp *FilePickerModel) selectPath(path string, isDir bool) {
+	fp.selectedFile = path
+	fp.selectedDir = isDir
+	fp.done = true
+
+	if fp.cfg != nil {
+		dir := path
+		if !isDir {
+			dir = filepath.Dir(path)
+		}
+		fp.cfg.AddRecentDir(dir)
+		fp.saveConfig()
+	}
+	logging.Debug("Path selected", map[string]interface{}{"path": path, "directory": isDir})
+}
+
+// Update handles input and keeps the preview on the highlighted file
 func (fp *FilePickerModel) Update(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
+	if pm, ok := msg.(previewMsg); ok {
+		fp.preview.update(pm)
+		return fp, nil
+	}
+
+	fp, cmd := fp.update(msg)
+	return fp, tea.Batch(cmd, fp.preview.request(fp.previewPath()))
+}
+
+// update handles input
+func (fp *FilePickerModel) update(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
 	var cmd tea.Cmd
 
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		fp.SetSize(size.Width, size.Height)
+		return fp, nil
+	}
+
 	if fp.showInput {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -199,15 +478,15 @@ func (fp *FilePickerModel) Update(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
 
 				if info, err := os.Stat(path); err == nil {
 					if info.IsDir() {
-						// If directory entered, show error - we need a file
-						fp.err = fmt.Errorf("please enter a file path, not a directory")
-						logging.Warn("User entered directory instead of file", map[string]interface{}{"path": path})
+						// Browse the entered directory
+						fp.showInput = false
+						fp.pathInput.SetValue("")
+						fp.pathInput.Blur()
+						fp.changeDir(path)
 					} else {
-						// Validate file matches the filter
-						if fp.matchesFilter(filepath.Base(path)) {
-							fp.selectedFile = path
-							fp.done = true
-							logging.Debug("File selected", map[string]interface{}{"path": path})
+						// Validate file content matches the filter
+						if fp.matchesFilter(core.DetectType(path)) {
+							fp.selectPath(path, false)
 						} else {
 							fp.err = fmt.Errorf("file type not supported for this operation")
 							logging.Warn("Unsupported file type selected", map[string]interface{}{
@@ -232,6 +511,14 @@ func (fp *FilePickerModel) Update(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
 		return fp, cmd
 	}
 
+	if fp.showPlaces {
+		return fp.updatePlaces(msg)
+	}
+
+	if fp.filtering {
+		return fp.updateFilter(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		keyStr := msg.String()
@@ -267,8 +554,53 @@ func (fp *FilePickerModel) Update(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
 		case key.Matches(msg, keys.Enter):
 			if len(fp.entries) > 0 {
 				entry := fp.entries[fp.cursor]
-				fp.selectedFile = entry.Path
-				fp.done = true
+				switch {
+				case entry.Name == parentDirName:
+					fp.goUp()
+				case entry.IsDir:
+					fp.changeDir(entry.Path)
+				default:
+					fp.selectPath(entry.Path, false)
+				}
+			}
+
+		case keyStr == "backspace": // Up one directory
+			fp.goUp()
+
+		case keyStr == "s" && fp.allowDirs: // Use the current directory
+			if len(fp.files) == 0 {
+				fp.err = fmt.Errorf("no %s files in this directory", fp.getFileTypeDescription())
+				return fp, nil
+			}
+			fp.selectPath(fp.currentDir, true)
+
+		case keyStr == "/": // Fuzzy filter
+			fp.filtering = true
+			fp.filterInput.SetValue(fp.query)
+			fp.filterInput.CursorEnd()
+			fp.filterInput.Focus()
+			return fp, textinput.Blink
+
+		case keyStr == "o": // Next sort mode
+			fp.cycleSort()
+
+		case keyStr == "esc" && fp.query != "":
+			fp.clearFilter()
+			fp.applyView()
+
+		case keyStr == "g": // Drives, home, bookmarks and recent folders
+			fp.loadPlaces()
+			fp.showPlaces = true
+			fp.err = nil
+
+		case keyStr == "b": // Bookmark the current directory
+			if fp.cfg != nil {
+				if fp.cfg.ToggleBookmark(fp.currentDir) {
+					fp.notice = "Bookmarked " + fp.currentDir
+				} else {
+					fp.notice = "Removed bookmark " + fp.currentDir
+				}
+				fp.saveConfig()
 			}
 
 		case keyStr == "p": // Manual path input
@@ -286,6 +618,77 @@ func (fp *FilePickerModel) Update(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
 	return fp, nil
 }
 
+// updateFilter handles typing a filter. The list narrows as you type and
+// ↑↓ still move; Enter keeps the filter, Esc removes it.
+func (fp *FilePickerModel) updateFilter(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			fp.filtering = false
+			fp.filterInput.Blur()
+			return fp, nil
+		case "esc":
+			fp.clearFilter()
+			fp.applyView()
+			return fp, nil
+		case "up":
+			if fp.cursor > 0 {
+				fp.cursor--
+			}
+			return fp, nil
+		case "down":
+			if fp.cursor < len(fp.entries)-1 {
+				fp.cursor++
+			}
+			return fp, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	fp.filterInput, cmd = fp.filterInput.Update(msg)
+	if query := strings.TrimSpace(fp.filterInput.Value()); query != fp.query {
+		fp.query = query
+		fp.applyView()
+	}
+	return fp, cmd
+}
+
+// updatePlaces handles input in the "go to" list
+func (fp *FilePickerModel) updatePlaces(msg tea.Msg) (*FilePickerModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return fp, nil
+	}
+
+	switch {
+	case key.Matches(keyMsg, keys.Up):
+		if fp.placeCursor > 0 {
+			fp.placeCursor--
+		} else if len(fp.places) > 0 {
+			fp.placeCursor = len(fp.places) - 1
+		}
+	case key.Matches(keyMsg, keys.Down):
+		if fp.placeCursor < len(fp.places)-1 {
+			fp.placeCursor++
+		} else {
+			fp.placeCursor = 0
+		}
+	case key.Matches(keyMsg, keys.Enter):
+		if len(fp.places) > 0 {
+			fp.showPlaces = false
+			fp.changeDir(fp.places[fp.placeCursor].path)
+		}
+	case key.Matches(keyMsg, keys.Back), keyMsg.String() == "g":
+		fp.showPlaces = false
+	}
+	return fp, nil
+}
+
+// previewPanes returns the picker's preview
+func (fp *FilePickerModel) previewPanes() []*previewPane {
+	return []*previewPane{&fp.preview}
+}
+
 // View renders the file picker
 func (fp *FilePickerModel) View() string {
 	var b strings.Builder
@@ -298,6 +701,9 @@ func (fp *FilePickerModel) View() string {
 
 	// Current directory info
 	dirLine := inputLabelStyle.Render("Directory: ") + fp.currentDir
+	if fp.cfg != nil && fp.cfg.IsBookmarked(fp.currentDir) {
+		dirLine += " " + IconBookmark
+	}
 	b.WriteString(dirLine)
 	b.WriteString("\n\n")
 
@@ -305,6 +711,9 @@ func (fp *FilePickerModel) View() string {
 	if fp.err != nil {
 		b.WriteString(errorStyle.Render("Error: " + fp.err.Error()))
 		b.WriteString("\n\n")
+	} else if fp.notice != "" {
+		b.WriteString(successStyle.Render(fp.notice))
+		b.WriteString("\n\n")
 	}
 
 	// Manual input mode
@@ -312,75 +721,152 @@ func (fp *FilePickerModel) View() string {
 		b.WriteString(inputLabelStyle.Render("Enter file path: "))
 		b.WriteString(fp.pathInput.View())
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Enter full path to file • Enter to confirm • Esc to cancel"))
+		b.WriteString(helpStyle.Render("Enter full path to a file or directory • Enter to confirm • Esc to cancel"))
+		return b.String()
+	}
+
+	if fp.showPlaces {
+		b.WriteString(fp.viewPlaces())
 		return b.String()
 	}
 
 	// File list - show count like batch file
 	fileTypeDesc := fp.getFileTypeDescription()
-	if len(fp.entries) == 0 {
+	switch {
+	case fp.query != "" && len(fp.entries) == 0:
+		b.WriteString(warningStyle.Render(fmt.Sprintf("Nothing matches %q.", fp.query)))
+	case fp.query != "":
+		countMsg := fmt.Sprintf("%d of %d %s file(s) match %q:", fp.fileCount, len(fp.files), fileTypeDesc, fp.query)
+		b.WriteString(lipgloss.NewStyle().Foreground(primaryColor).Bold(true).Render(countMsg))
+	case fp.fileCount == 0:
 		b.WriteString(warningStyle.Render(fmt.Sprintf("No %s file(s) found in this directory.", fileTypeDesc)))
-		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Press 'p' to enter a file path manually • Esc to go back"))
-	} else {
-		// Show file count
-		countMsg := fmt.Sprintf("Found %d %s file(s):", len(fp.entries), fileTypeDesc)
+	default:
+		countMsg := fmt.Sprintf("Found %d %s file(s):", fp.fileCount, fileTypeDesc)
 		b.WriteString(lipgloss.NewStyle().Foreground(primaryColor).Bold(true).Render(countMsg))
+	}
+	b.WriteString(lipgloss.NewStyle().Foreground(subtleColor).Render("  sorted by " + fp.sortMode.String()))
+	b.WriteString("\n\n")
+
+	if fp.filtering {
+		b.WriteString(fp.filterInput.View())
 		b.WriteString("\n\n")
+	}
 
-		// Show limited entries with scroll
-		visibleCount := 15
-		start := 0
-		if fp.cursor >= visibleCount {
-			start = fp.cursor - visibleCount + 1
-		}
-		end := start + visibleCount
-		if end > len(fp.entries) {
-			end = len(fp.entries)
-		}
+	// Show limited entries with scroll
+	list := &strings.Builder{}
+	visibleCount := fp.pageSize()
+	start := 0
+	if fp.cursor >= visibleCount {
+		start = fp.cursor - visibleCount + 1
+	}
+	end := start + visibleCount
+	if end > len(fp.entries) {
+		end = len(fp.entries)
+	}
+
+	dirCount := len(fp.entries) - fp.fileCount
+	for i := start; i < end; i++ {
+		entry := fp.entries[i]
+		cursor := "  "
+		style := fileItemStyle
 
-		for i := start; i < end; i++ {
-			entry := fp.entries[i]
-			cursor := "  "
-			style := fileItemStyle
+		if i == fp.cursor {
+			cursor = IconPointer + " "
+			style = selectedFileStyle
+		}
 
-			if i == fp.cursor {
-				cursor = IconPointer + " "
-				style = selectedFileStyle
+		if entry.IsDir {
+			name := entry.Name
+			if name != parentDirName {
+				name += string(filepath.Separator)
+			}
+			if i != fp.cursor {
+				style = dirStyle
 			}
+			list.WriteString(style.Render(cursor + "    " + IconFolder + " " + name))
+			list.WriteString("\n")
+			continue
+		}
 
-			// File number
-			numStr := fmt.Sprintf("%2d. ", i+1)
+		// File number
+		numStr := fmt.Sprintf("%2d. ", i-dirCount+1)
 
-			icon := IconFile
-			if strings.HasSuffix(strings.ToLower(entry.Name), ".pdf") {
-				icon = IconPDF
-			} else {
-				icon = IconImage
-			}
+		icon := IconImage
+		if entry.Type == core.TypePDF {
+			icon = IconPDF
+		}
 
-			// Format file size
-			sizeStr := fmt.Sprintf(" (%s)", core.FormatSize(entry.Size))
+		// Format file size
+		sizeStr := fmt.Sprintf(" (%s)", core.FormatSize(entry.Size))
 
-			line := style.Render(cursor + numStr + icon + " " + entry.Name + sizeStr)
-			b.WriteString(line)
-			b.WriteString("\n")
+		line := style.Render(cursor + numStr + icon + " " + entry.Name + sizeStr)
+		if entry.Mismatch {
+			line += warningStyle.Render(" " + IconWarning)
 		}
+		list.WriteString(line)
+		list.WriteString("\n")
 
-		// Scroll indicator
-		if len(fp.entries) > visibleCount {
-			scrollInfo := lipgloss.NewStyle().Foreground(subtleColor).Render(fmt.Sprintf("\n  Showing %d-%d of %d items", start+1, end, len(fp.entries)))
-			b.WriteString(scrollInfo)
+		// Explain the mismatch for the highlighted file
+		if entry.Mismatch && i == fp.cursor {
+			list.WriteString(warningStyle.Render("       " + mismatchWarning(entry)))
+			list.WriteString("\n")
 		}
+	}
 
-		// Help
-		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("↑↓ Navigate • Enter Select • p Enter path manually • Esc Back"))
+	// Scroll indicator
+	if len(fp.entries) > visibleCount {
+		scrollInfo := lipgloss.NewStyle().Foreground(subtleColor).Render(fmt.Sprintf("\n  Showing %d-%d of %d items", start+1, end, len(fp.entries)))
+		list.WriteString(scrollInfo)
+	}
+
+	if fp.showPreview() {
+		b.WriteString(joinColumns(list.String(), fp.preview.View()))
+	} else {
+		b.WriteString(list.String())
+	}
+
+	// Help
+	b.WriteString("\n\n")
+	if fp.filtering {
+		b.WriteString(helpStyle.Render("Type to filter • ↑↓ Navigate • Enter Keep filter • Esc Clear"))
+		return b.String()
+	}
+	help := "↑↓ Navigate • Enter Open/Select • Backspace Up • / Filter • o Sort • g Go to • b Bookmark • p Enter path"
+	if fp.allowDirs {
+		help += " • s Use this directory"
 	}
+	if fp.query != "" {
+		help += " • Esc Clear filter"
+	} else {
+		help += " • Esc Back"
+	}
+	b.WriteString(helpStyle.Render(help))
 
 	return b.String()
 }
 
+// viewPlaces renders the drives, home, bookmarks and recent folders
+func (fp *FilePickerModel) viewPlaces() string {
+	var b strings.Builder
+	b.WriteString(inputLabelStyle.Render("Go to:"))
+	b.WriteString("\n\n")
+
+	for i, p := range fp.places {
+		cursor := "  "
+		style := fileItemStyle
+		if i == fp.placeCursor {
+			cursor = IconPointer + " "
+			style = selectedFileStyle
+		}
+		b.WriteString(style.Render(cursor + p.label))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("↑↓ Navigate • Enter Open • Esc Back"))
+	return b.String()
+}
+
 // getFileTypeDescription returns description based on mode
 func (fp *FilePickerModel) getFileTypeDescription() string {
 	switch fp.mode {
@@ -394,11 +880,17 @@ func (fp *FilePickerModel) getFileTypeDescription() string {
 	return "file"
 }
 
-// SelectedFile returns the selected file path
+// SelectedFile returns the selected file path, or the directory when
+// SelectedIsDir is true
 func (fp *FilePickerModel) SelectedFile() string {
 	return fp.selectedFile
 }
 
+// SelectedIsDir returns true if a whole directory was selected
+func (fp *FilePickerModel) SelectedIsDir() bool {
+	return fp.selectedDir
+}
+
 // IsDone returns true if selection is complete
 func (fp *FilePickerModel) IsDone() bool {
 	return fp.done
@@ -412,7 +904,12 @@ func (fp *FilePickerModel) IsCancelled() bool {
 // Reset resets the file picker state
 func (fp *FilePickerModel) Reset() {
 	fp.selectedFile = ""
+	fp.selectedDir = false
 	fp.done = false
 	fp.cancelled = false
 	fp.cursor = 0
+	fp.err = nil
+	fp.notice = ""
+	fp.clearFilter()
+	fp.applyView()
 }
@@ -1,13 +1,19 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"imagetool/internal/config"
 	"imagetool/internal/core"
+	"imagetool/internal/deps"
+	"imagetool/internal/history"
+	"imagetool/internal/jobs"
 	"imagetool/internal/logging"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -30,32 +36,53 @@ const (
 type FormatConverterModel struct {
 	step       FormatStep
 	filePicker *FilePickerModel
+	cfg        *config.Config
+	jobs       *jobs.Queue
+	jobID      int // The submitted conversion
+	submitted  int // Job submitted since the app last asked
 
 	// Settings
 	inputFile    string
+	inputDir     string   // Set when a whole folder is converted
+	inputFiles   []string // Images in inputDir
 	outputFormat string
 	outputFile   string
+	nameTemplate nameTemplateEditor
+	collision    core.CollisionPolicy
+	existing     []string // Outputs that already exist
+	unchecked    int      // Folder files whose outputs weren't checked
+	outputErr    error    // An output would replace its input
 
 	// Format selection
 	formats      []string
 	formatCursor int
 	customFormat bool
 	customInput  textinput.Model
+	depResult    deps.CheckResult
+	formatErr    string
 
 	// Results
 	result   string
 	isError  bool
+	details  errorDetails
 	fileSize int64
+	batch    core.BatchResult
+	preview  previewPane
+	manifest *core.Manifest // Of the folder run, kept so a retry resumes it
 
 	// Navigation
 	done       bool
 	backToMenu bool
+	showLogs   bool
 }
 
-// NewFormatConverterModel creates a new format converter
-func NewFormatConverterModel() *FormatConverterModel {
-	fp := NewFilePickerModel()
+// NewFormatConverterModel creates a new format converter. Output formats
+// that the detected ImageMagick cannot write are shown as unavailable.
+// Conversions run as jobs on queue.
+func NewFormatConverterModel(cfg *config.Config, depResult deps.CheckResult, queue *jobs.Queue) *FormatConverterModel {
+	fp := NewFilePickerModel(cfg)
 	fp.SetMode(FilePickerImage)
+	fp.SetAllowDirectory(true)
 
 	customInput := textinput.New()
 	customInput.Placeholder = "avif, webp, heic..."
@@ -69,17 +96,45 @@ func NewFormatConverterModel() *FormatConverterModel {
 	return &FormatConverterModel{
 		step:         FormatStepSelectFile,
 		filePicker:   fp,
+		cfg:          cfg,
+		jobs:         queue,
 		formats:      formats,
 		formatCursor: 0,
 		customInput:  customInput,
+		depResult:    depResult,
+		nameTemplate: newNameTemplateEditor(cfg.Templates.Convert),
+		preview:      newPreviewPane(cfg),
+		collision:    core.ParseCollisionPolicy(cfg.Collision),
 	}
 }
 
+// formatAvailable reports whether ImageMagick can write the format, with
+// the reason when it can't
+func (m *FormatConverterModel) formatAvailable(format string) (bool, string) {
+	if format == "custom" {
+		return true, ""
+	}
+	return m.depResult.CanWriteFormat(format)
+}
+
 // formatConversionResultMsg contains conversion results
 type formatConversionResultMsg struct {
-	message  string
-	isError  bool
-	fileSize int64
+	message    string
+	isError    bool
+	outputPath string
+	fileSize   int64
+	batch      core.BatchResult
+	err        error
+}
+
+// SetSize sets the terminal size, which the file picker fits its list to
+func (m *FormatConverterModel) SetSize(width, height int) {
+	m.filePicker.SetSize(width, height)
+}
+
+// Init starts loading the file picker's preview
+func (m *FormatConverterModel) Init() tea.Cmd {
+	return m.filePicker.Init()
 }
 
 // Update handles input
@@ -93,7 +148,20 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 			if m.filePicker.IsCancelled() {
 				m.backToMenu = true
 				m.done = true
+			} else if m.filePicker.SelectedIsDir() {
+				files, err := folderFiles(m.filePicker.SelectedFile(), true, false)
+				if err != nil {
+					m.filePicker.Reset()
+					m.filePicker.err = err
+					return m, cmd
+				}
+				m.inputDir = m.filePicker.SelectedFile()
+				m.inputFiles = files
+				m.inputFile = files[0]
+				m.step = FormatStepSelectFormat
 			} else {
+				m.inputDir = ""
+				m.inputFiles = nil
 				m.inputFile = m.filePicker.SelectedFile()
 				m.step = FormatStepSelectFormat
 			}
@@ -109,7 +177,13 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 				case "enter":
 					val := strings.TrimSpace(m.customInput.Value())
 					if val != "" {
-						m.outputFormat = strings.TrimPrefix(val, ".")
+						val = strings.TrimPrefix(val, ".")
+						if ok, reason := m.formatAvailable(val); !ok {
+							m.formatErr = reason
+							return m, nil
+						}
+						m.formatErr = ""
+						m.outputFormat = val
 						m.customFormat = false
 						m.customInput.Blur()
 						m.buildOutputPath()
@@ -120,6 +194,7 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 					m.customFormat = false
 					m.customInput.Blur()
 					m.customInput.SetValue("")
+					m.formatErr = ""
 					return m, nil
 				}
 			}
@@ -132,12 +207,14 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 		case tea.KeyMsg:
 			switch {
 			case key.Matches(msg, keys.Up):
+				m.formatErr = ""
 				if m.formatCursor > 0 {
 					m.formatCursor--
 				} else {
 					m.formatCursor = len(m.formats) - 1
 				}
 			case key.Matches(msg, keys.Down):
+				m.formatErr = ""
 				if m.formatCursor < len(m.formats)-1 {
 					m.formatCursor++
 				} else {
@@ -145,6 +222,10 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 				}
 			case key.Matches(msg, keys.Enter):
 				selected := m.formats[m.formatCursor]
+				if ok, reason := m.formatAvailable(selected); !ok {
+					m.formatErr = reason
+					return m, nil
+				}
 				if selected == "custom" {
 					m.customFormat = true
 					m.customInput.Focus()
@@ -162,12 +243,42 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 		return m, nil
 
 	case FormatStepConfirm:
+		if m.nameTemplate.editing {
+			switch msg := msg.(type) {
+			case tea.KeyMsg:
+				switch msg.String() {
+				case "enter":
+					if m.nameTemplate.apply() == nil {
+						m.buildOutputPath()
+					}
+					return m, nil
+				case "esc":
+					m.nameTemplate.stop()
+					return m, nil
+				}
+			}
+			return m, m.nameTemplate.update(msg)
+		}
+
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "y", "Y", "enter":
+				if m.outputErr != nil {
+					return m, nil
+				}
+				// It runs in the background; the Jobs view reopens the
+				// wizard on it
 				m.step = FormatStepConverting
-				return m, m.runConversion
+				m.jobID = m.jobs.Submit(m.jobTitle(), m.conversionJob())
+				m.submitted = m.jobID
+				m.backToMenu = true
+				m.done = true
+				return m, nil
+			case "t": // Edit the output name template
+				return m, m.nameTemplate.start()
+			case "c": // Cycle what happens to an existing output
+				m.collision = m.collision.Next()
 			case "n", "N", "esc":
 				m.step = FormatStepSelectFormat
 			case "b":
@@ -179,17 +290,44 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 
 	case FormatStepConverting:
 		switch msg := msg.(type) {
+		case jobsChangedMsg:
+			if job, ok := finishedJob(m.jobs, m.jobID); ok {
+				result, ok := job.Outcome.Value.(formatConversionResultMsg)
+				if !ok {
+					result = formatConversionResultMsg{message: "Conversion cancelled", isError: true, err: job.Outcome.Err}
+				}
+				return m.Update(result)
+			}
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "x": // Cancel the conversion
+				m.jobs.Cancel(m.jobID)
+			case "esc", "m": // Leave it running in the background
+				m.backToMenu = true
+				m.done = true
+			}
 		case formatConversionResultMsg:
 			m.step = FormatStepDone
 			m.result = msg.message
 			m.isError = msg.isError
+			m.details = newErrorDetails(msg.err)
 			m.fileSize = msg.fileSize
-			return m, nil
+			m.batch = msg.batch
+			if msg.outputPath != "" {
+				// The collision policy may have chosen another name
+				m.outputFile = msg.outputPath
+			}
+			if m.inputDir != "" {
+				return m, m.preview.request(firstOutput(m.batch))
+			}
+			return m, m.preview.request(msg.outputPath)
 		}
 		return m, nil
 
 	case FormatStepDone:
 		switch msg := msg.(type) {
+		case previewMsg:
+			m.preview.update(msg)
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter", "m":
@@ -199,8 +337,17 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 				m.step = FormatStepSelectFile
 				m.filePicker.Reset()
 				m.inputFile = ""
+				m.inputDir = ""
+				m.inputFiles = nil
 				m.outputFile = ""
 				m.result = ""
+				m.preview.request("")
+			case "d": // Toggle raw error output
+				m.details.toggle()
+			case "l": // View logs after a failure
+				if m.isError {
+					m.showLogs = true
+				}
 			case "q":
 				return m, tea.Quit
 			}
@@ -211,34 +358,121 @@ func (m *FormatConverterModel) Update(msg tea.Msg) (*FormatConverterModel, tea.C
 	return m, nil
 }
 
-// buildOutputPath creates the output file path
+// buildOutputPath creates the output file path from the name template,
+// next to the input. In folder mode it checks the outputs of the first
+// outputCheckLimit files and previews the first.
 func (m *FormatConverterModel) buildOutputPath() {
-	dir := filepath.Dir(m.inputFile)
-	base := strings.TrimSuffix(filepath.Base(m.inputFile), filepath.Ext(m.inputFile))
-	m.outputFile = filepath.Join(dir, base+"_conv."+m.outputFormat)
+	inputs := []string{m.inputFile}
+	m.unchecked = 0
+	if m.inputDir != "" {
+		inputs, m.unchecked = checkedInputs(m.inputFiles)
+	}
+
+	// Backwards, so the editor is left previewing the first file
+	m.outputErr = nil
+	outputs := make([]string, 0, len(inputs))
+	for i := len(inputs) - 1; i >= 0; i-- {
+		output, err := m.outputPathFor(inputs[i])
+		if err != nil {
+			m.outputErr = err
+		}
+		outputs = append(outputs, output)
+	}
+	m.outputFile = outputs[len(outputs)-1]
+	m.existing = core.ExistingOutputs(outputs)
+}
+
+// outputPathFor returns the output path for one input, leaving the name
+// template editor previewing it. It fails when the template names the
+// input itself.
+func (m *FormatConverterModel) outputPathFor(input string) (string, error) {
+	m.nameTemplate.setInput(input, m.outputFormat, core.ConvertPreset(core.ImageFormat(m.outputFormat)), 1)
+	name, err := m.nameTemplate.expand(m.nameTemplate.value)
+	if errors.Is(err, core.ErrOutputIsInput) {
+		return input, err
+	}
+	if err != nil {
+		base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		name = base + "_conv." + m.outputFormat
+	}
+	return filepath.Join(filepath.Dir(input), name), nil
+}
+
+// jobTitle names the conversion in the jobs list and history
+func (m *FormatConverterModel) jobTitle() string {
+	if m.inputDir != "" {
+		return fmt.Sprintf("Convert %s (%d files) to %s", filepath.Base(m.inputDir), len(m.inputFiles), strings.ToUpper(m.outputFormat))
+	}
+	return fmt.Sprintf("Convert %s to %s", filepath.Base(m.inputFile), strings.ToUpper(m.outputFormat))
+}
+
+// conversionJob returns the job that runs the conversion. It works on a
+// copy of the settings, so the wizard can move on and a retry repeats the
+// same conversion.
+func (m *FormatConverterModel) conversionJob() jobs.Func {
+	settings := *m
+	return func(ctx context.Context, progress jobs.Progress) jobs.Outcome {
+		result := settings.runConversion(ctx, progress)
+		return jobs.Outcome{Message: result.message, Err: result.err, Value: result}
+	}
+}
+
+// historyEntry describes the conversion for the history
+func (m *FormatConverterModel) historyEntry() history.Entry {
+	inputs := []string{m.inputFile}
+	if m.inputDir != "" {
+		inputs = m.inputFiles
+	}
+	return history.Entry{
+		Operation: history.OpConvert,
+		Title:     m.jobTitle(),
+		Inputs:    inputs,
+		InputDir:  m.inputDir,
+		Options: history.Options{
+			Format:       m.outputFormat,
+			NameTemplate: m.nameTemplate.value,
+			Collision:    m.collision.String(),
+		},
+	}
 }
 
 // runConversion executes the ImageMagick command via core package
-func (m *FormatConverterModel) runConversion() tea.Msg {
+func (m *FormatConverterModel) runConversion(ctx context.Context, progress jobs.Progress) formatConversionResultMsg {
+	if m.inputDir != "" {
+		return m.runBatchConversion(ctx, progress)
+	}
+	start := time.Now()
+
 	logging.Info("Starting format conversion", map[string]interface{}{
 		"input":  m.inputFile,
 		"format": m.outputFormat,
 	})
 
-	result := core.ConvertImage(core.ConvertImageOptions{
+	result := core.ConvertImageContext(ctx, core.ConvertImageOptions{
 		InputPath:    m.inputFile,
 		OutputFormat: core.ImageFormat(m.outputFormat),
 		OutputPath:   m.outputFile,
+		Collision:    m.collision,
+		Limits:       config.CoreLimits(m.cfg),
+		Timeout:      config.CoreTimeout(m.cfg),
 	})
 
+	entry := m.historyEntry()
+	entry.Options.Output = m.outputFile
+	entry.Outputs = resultOutputs(result)
+	entry.OutputSize = result.OutputSize
+	recordHistory(entry, start, result.Success, result.Message)
+
 	if !result.Success {
 		logging.Error("Format conversion failed", map[string]interface{}{
-			"input": m.inputFile,
-			"error": result.Message,
+			"input":   m.inputFile,
+			"error":   result.Message,
+			"details": result.Error,
 		})
 		return formatConversionResultMsg{
 			message: result.Message,
 			isError: true,
+			err:     result.Error,
 		}
 	}
 
@@ -249,9 +483,65 @@ func (m *FormatConverterModel) runConversion() tea.Msg {
 	})
 
 	return formatConversionResultMsg{
-		message:  "Image converted successfully",
-		isError:  false,
-		fileSize: result.OutputSize,
+		message:    result.Message,
+		isError:    false,
+		outputPath: result.OutputPath,
+		fileSize:   result.OutputSize,
+	}
+}
+
+// runBatchConversion converts every image of the selected folder
+func (m *FormatConverterModel) runBatchConversion(ctx context.Context, progress jobs.Progress) formatConversionResultMsg {
+	logging.Info("Starting folder conversion", map[string]interface{}{
+		"dir":    m.inputDir,
+		"files":  len(m.inputFiles),
+		"format": m.outputFormat,
+	})
+
+	start := time.Now()
+	opts := core.BatchConvertOptions{
+		OutputFormat: core.ImageFormat(m.outputFormat),
+		NameTemplate: m.nameTemplate.value,
+		Collision:    m.collision,
+		Limits:       config.CoreLimits(m.cfg),
+		Timeout:      config.CoreTimeout(m.cfg),
+	}
+	manifest, err := batchManifest(m.manifest, core.ManifestHeader{
+		Operation: core.BatchOpConvert,
+		Title:     m.jobTitle(),
+		InputDir:  m.inputDir,
+		Inputs:    m.inputFiles,
+		Convert:   &opts,
+	})
+	if err != nil {
+		return formatConversionResultMsg{message: err.Error(), isError: true, err: err}
+	}
+	m.manifest = manifest
+
+	run := opts
+	run.Progress, run.Manifest = progress, m.manifest
+	batch := core.BatchConvert(ctx, m.inputFiles, run)
+	finishManifest(m.manifest)
+
+	logging.Info("Folder conversion completed", map[string]interface{}{
+		"dir":       m.inputDir,
+		"converted": batch.SuccessCount,
+		"skipped":   batch.SkippedCount,
+		"failed":    batch.FailCount,
+	})
+
+	err = batchError(batch)
+	message := batchMessage("Converted", batch)
+	entry := m.historyEntry()
+	entry.Outputs = batchOutputs(batch)
+	recordHistory(entry, start, err == nil, message)
+
+	return formatConversionResultMsg{
+		message:  message,
+		isError:  err != nil,
+		fileSize: batch.TotalOutputSize,
+		batch:    batch,
+		err:      err,
 	}
 }
 
@@ -277,6 +567,10 @@ func (m *FormatConverterModel) View() string {
 			b.WriteString("\n\n")
 			b.WriteString(descriptionStyle.Render("Examples: avif, webp, heic, ico, svg"))
 			b.WriteString("\n\n")
+			if m.formatErr != "" {
+				b.WriteString(errorStyle.Render(m.formatErr))
+				b.WriteString("\n\n")
+			}
 			b.WriteString(helpStyle.Render("Enter to confirm • Esc Back"))
 		} else {
 			b.WriteString(inputLabelStyle.Render("Select output format:"))
@@ -294,10 +588,18 @@ func (m *FormatConverterModel) View() string {
 				if format == "custom" {
 					display = "Custom (enter any format)"
 				}
+				if ok, _ := m.formatAvailable(format); !ok {
+					style = disabledItemStyle
+					display += " (not supported)"
+				}
 				b.WriteString(style.Render(cursor + display))
 				b.WriteString("\n")
 			}
 			b.WriteString("\n")
+			if m.formatErr != "" {
+				b.WriteString(errorStyle.Render(m.formatErr))
+				b.WriteString("\n")
+			}
 			b.WriteString(helpStyle.Render("↑↓ Navigate • Enter Select • Esc Back"))
 		}
 
@@ -311,32 +613,72 @@ func (m *FormatConverterModel) View() string {
 			inputSize = info.Size()
 		}
 
-		summaryBox := boxStyle.Render(
-			fmt.Sprintf("Input:   %s (%s)\n", filepath.Base(m.inputFile), core.FormatSize(inputSize)) +
+		var summary string
+		if m.inputDir != "" {
+			summary = fmt.Sprintf("Folder:  %s (%d images)\n", m.inputDir, len(m.inputFiles)) +
+				fmt.Sprintf("Format:  → %s\n", strings.ToUpper(m.outputFormat)) +
+				fmt.Sprintf("Output:  %s, ...\n", filepath.Base(m.outputFile))
+		} else {
+			summary = fmt.Sprintf("Input:   %s (%s)\n", filepath.Base(m.inputFile), core.FormatSize(inputSize)) +
 				fmt.Sprintf("Format:  %s → %s\n", strings.ToUpper(filepath.Ext(m.inputFile)[1:]), strings.ToUpper(m.outputFormat)) +
-				fmt.Sprintf("Output:  %s", filepath.Base(m.outputFile)),
-		)
+				fmt.Sprintf("Output:  %s\n", filepath.Base(m.outputFile))
+		}
+		summaryBox := boxStyle.Render(summary + fmt.Sprintf("Exists:  %s", m.collision))
 		b.WriteString(summaryBox)
 		b.WriteString("\n\n")
+		if warning := collisionWarning(m.existing, m.unchecked, m.collision); warning != "" {
+			b.WriteString(warningStyle.Render(warning))
+			b.WriteString("\n\n")
+		}
+		if m.outputErr != nil {
+			b.WriteString(errorStyle.Render(IconError + " " + m.outputErr.Error() + ". Press T to change the name template"))
+			b.WriteString("\n\n")
+		}
+		if m.nameTemplate.editing {
+			b.WriteString(inputLabelStyle.Render("Output name template:"))
+			b.WriteString("\n\n")
+			b.WriteString(m.nameTemplate.View())
+			b.WriteString("\n\n")
+			b.WriteString(helpStyle.Render("Enter Apply • Esc Cancel"))
+			break
+		}
 		b.WriteString(warningStyle.Render("Proceed with conversion? (Y/n)"))
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Y/Enter Proceed • N/Esc Back • B Menu"))
+		b.WriteString(helpStyle.Render("Y/Enter Proceed • T Edit Name • C If Exists • N/Esc Back • B Menu"))
 
 	case FormatStepConverting:
 		b.WriteString("\n")
-		b.WriteString(progressStyle.Render("⏳ Converting... Please wait"))
-		b.WriteString("\n")
+		b.WriteString(progressStyle.Render(jobProgress(m.jobs, m.jobID, "Converting")))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Esc/M Menu (keeps running) • X Cancel"))
 
 	case FormatStepDone:
 		if m.isError {
 			b.WriteString(errorStyle.Render(IconError + " " + m.result))
+			b.WriteString(m.details.View())
 		} else {
 			b.WriteString(successStyle.Render(IconSuccess + " " + m.result))
 			b.WriteString("\n\n")
-			b.WriteString(descriptionStyle.Render(fmt.Sprintf("Output: %s (%s)", m.outputFile, core.FormatSize(m.fileSize))))
+			if m.inputDir != "" {
+				b.WriteString(descriptionStyle.Render(fmt.Sprintf("Output: %s (%s total)", m.inputDir, core.FormatSize(m.fileSize))))
+			} else {
+				b.WriteString(descriptionStyle.Render(fmt.Sprintf("Output: %s (%s)", m.outputFile, core.FormatSize(m.fileSize))))
+			}
+		}
+		if failures := batchFailures(m.inputFiles, m.batch); failures != "" {
+			b.WriteString("\n\n")
+			b.WriteString(errorStyle.Render(failures))
+		}
+		if preview := m.preview.View(); preview != "" {
+			b.WriteString("\n\n")
+			b.WriteString(preview)
 		}
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Enter/M Menu • A Convert Another • Q Quit"))
+		if m.isError {
+			b.WriteString(helpStyle.Render("Enter/M Menu • A Convert Another • L View Logs" + m.details.helpKey() + " • Q Quit"))
+		} else {
+			b.WriteString(helpStyle.Render("Enter/M Menu • A Convert Another • Q Quit"))
+		}
 	}
 
 	return b.String()
@@ -351,3 +693,32 @@ func (m *FormatConverterModel) IsDone() bool {
 func (m *FormatConverterModel) BackToMenu() bool {
 	return m.backToMenu
 }
+
+// WantsLogs reports whether the user asked to view logs from the Done
+// step, clearing the request
+func (m *FormatConverterModel) WantsLogs() bool {
+	wants := m.showLogs
+	m.showLogs = false
+	return wants
+}
+
+// previewPanes returns the previews the wizard may show
+func (m *FormatConverterModel) previewPanes() []*previewPane {
+	return []*previewPane{&m.preview, &m.filePicker.preview}
+}
+
+// Submitted returns the job submitted since the last call, or 0
+func (m *FormatConverterModel) Submitted() int {
+	id := m.submitted
+	m.submitted = 0
+	return id
+}
+
+// Follow reopens the wizard on job id, one it submitted or a retry of
+// one, showing its progress and then its result
+func (m *FormatConverterModel) Follow(id int) {
+	m.jobID = id
+	m.step = FormatStepConverting
+	m.done = false
+	m.backToMenu = false
+}
@@ -0,0 +1,438 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"imagetool/internal/config"
+	"imagetool/internal/core"
+	"imagetool/internal/deps"
+	"imagetool/internal/history"
+	"imagetool/internal/jobs"
+	"imagetool/internal/logging"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// compressMethodNames are the compression methods as the history stores them
+var compressMethodNames = map[core.CompressMethod]string{
+	core.CompressMethodPercent:   "percent",
+	core.CompressMethodFixedSize: "size",
+	core.CompressMethodQuality:   "quality",
+}
+
+// parseCompressMethod returns the compression method stored as name
+func parseCompressMethod(name string) core.CompressMethod {
+	for method, n := range compressMethodNames {
+		if n == name {
+			return method
+		}
+	}
+	return core.CompressMethodPercent
+}
+
+// recordHistory completes an entry with how the operation went and adds
+// it to the history
+func recordHistory(e history.Entry, start time.Time, success bool, message string) {
+	e.Duration = time.Since(start)
+	e.Success = success
+	e.Message = message
+	e.InputSize = totalSize(e.Inputs)
+	if e.OutputSize == 0 {
+		e.OutputSize = totalSize(e.Outputs)
+	}
+	if _, err := history.Record(e); err != nil {
+		logging.Warn("Could not record history", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// resultOutputs lists the files a result wrote; skipped and up-to-date
+// outputs existed before and are not listed
+func resultOutputs(r core.Result) []string {
+	switch {
+	case !r.Success || r.Skipped || r.UpToDate:
+		return nil
+	case len(r.OutputPaths) > 0:
+		return r.OutputPaths
+	case r.OutputPath != "":
+		return []string{r.OutputPath}
+	}
+	return nil
+}
+
+// batchOutputs lists the files a batch run wrote
+func batchOutputs(b core.BatchResult) []string {
+	var outputs []string
+	for _, r := range b.Results {
+		outputs = append(outputs, resultOutputs(r)...)
+	}
+	return outputs
+}
+
+// totalSize adds up the sizes of the files that still exist
+func totalSize(paths []string) int64 {
+	var size int64
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// rerunJob submits a history entry's operation again with the same
+// settings and returns the wizard that submitted it, whose Submitted gives
+// the new job's ID. Folder runs skip inputs that no longer exist.
+func rerunJob(cfg *config.Config, queue *jobs.Queue, e history.Entry) (jobWizard, error) {
+	var inputs []string
+	for _, in := range e.Inputs {
+		if _, err := os.Stat(in); err == nil {
+			inputs = append(inputs, in)
+		} else if e.InputDir == "" {
+			return nil, fmt.Errorf("%s is no longer available", in)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("none of the inputs are available any more")
+	}
+	opts := e.Options
+
+	switch e.Operation {
+	case history.OpConvert:
+		m := NewFormatConverterModel(cfg, deps.CheckResult{}, queue)
+		m.inputFile, m.inputDir, m.inputFiles = inputs[0], e.InputDir, inputs
+		m.outputFormat = opts.Format
+		m.outputFile = opts.Output
+		m.nameTemplate.value = opts.NameTemplate
+		m.collision = core.ParseCollisionPolicy(opts.Collision)
+		m.submitted = queue.Submit(m.jobTitle(), m.conversionJob())
+		return m, nil
+
+	case history.OpPDF:
+		m := NewPDFConverterModel(cfg, queue)
+		m.inputFile = inputs[0]
+		m.outputFormat = opts.Format
+		m.density, m.quality = opts.Density, opts.Quality
+		m.outputDir = opts.Output
+		m.nameTemplate.value = opts.NameTemplate
+		m.collision = core.ParseCollisionPolicy(opts.Collision)
+		m.submitted = queue.Submit(m.jobTitle(), m.conversionJob())
+		return m, nil
+
+	case history.OpCompress:
+		m := NewCompressorModel(cfg, queue)
+		m.inputFile, m.inputDir, m.inputFiles = inputs[0], e.InputDir, inputs
+		m.outputFile = opts.Output
+		m.method = parseCompressMethod(opts.Method)
+		m.targetPercent, m.targetBytes, m.minSSIM = opts.Percent, opts.TargetBytes, opts.MinSSIM
+		for _, f := range opts.Formats {
+			if f == string(core.FormatWebP) {
+				m.allowWebP = true
+			}
+		}
+		m.nameTemplate.value = opts.NameTemplate
+		m.collision = core.ParseCollisionPolicy(opts.Collision)
+		m.submitted = queue.Submit(m.jobTitle(), m.compressionJob())
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", e.Operation)
+}
+
+// outputFolder returns the folder holding an entry's outputs
+func outputFolder(e history.Entry) string {
+	switch {
+	case e.Operation == history.OpPDF && e.Options.Output != "":
+		return e.Options.Output
+	case len(e.Outputs) > 0:
+		return filepath.Dir(e.Outputs[0])
+	case e.Options.Output != "":
+		return filepath.Dir(e.Options.Output)
+	}
+	return e.InputDir
+}
+
+// HistoryModel lists past operations and lets the user re-run, open or
+// undo them
+type HistoryModel struct {
+	cfg     *config.Config
+	jobs    *jobs.Queue
+	entries []history.Entry // Newest first
+	cursor  int
+	height  int
+	notice  string
+	isError bool
+	undoing int       // Entry waiting for the undo to be confirmed
+	rerun   jobWizard // Wizard of the last re-run, for the Jobs view
+	rerunID int       // Job the re-run was submitted as
+
+	done bool
+}
+
+// NewHistoryModel creates a history view whose re-runs are submitted to
+// queue
+func NewHistoryModel(cfg *config.Config, queue *jobs.Queue) *HistoryModel {
+	return &HistoryModel{cfg: cfg, jobs: queue, entries: history.Entries()}
+}
+
+// SetSize sets the terminal size used for paging
+func (m *HistoryModel) SetSize(width, height int) {
+	m.height = height
+}
+
+// pageSize returns how many entries fit on screen
+func (m *HistoryModel) pageSize() int {
+	if m.height <= 0 {
+		return 10
+	}
+	// Header, details of the selected entry, help and the status bar take
+	// roughly 16 lines
+	if size := m.height - 16; size > 3 {
+		return size
+	}
+	return 3
+}
+
+// setNotice shows the result of the last action
+func (m *HistoryModel) setNotice(notice string, isError bool) {
+	m.notice = notice
+	m.isError = isError
+}
+
+// Update handles input
+func (m *HistoryModel) Update(msg tea.Msg) (*HistoryModel, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.SetSize(size.Width, size.Height)
+		return m, nil
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	undoing := m.undoing
+	m.undoing = 0
+	m.setNotice("", false)
+
+	switch {
+	case key.Matches(keyMsg, keys.Up):
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case key.Matches(keyMsg, keys.Down):
+		if m.cursor < len(m.entries)-1 {
+			m.cursor++
+		}
+	case key.Matches(keyMsg, keys.Back), keyMsg.String() == "q":
+		m.done = true
+	case len(m.entries) == 0:
+	case keyMsg.String() == "r":
+		e := m.entries[m.cursor]
+		if w, err := rerunJob(m.cfg, m.jobs, e); err != nil {
+			m.setNotice("Can't re-run: "+err.Error(), true)
+		} else {
+			id := w.Submitted()
+			m.rerun, m.rerunID = w, id
+			m.setNotice(fmt.Sprintf("Re-running as job #%d; follow it in Jobs", id), false)
+			logging.Info("Re-running from history", map[string]interface{}{
+				"entry": e.ID,
+				"job":   id,
+			})
+		}
+	case keyMsg.String() == "o":
+		if dir := outputFolder(m.entries[m.cursor]); dir == "" {
+			m.setNotice("This operation has no output folder", true)
+		} else if err := core.OpenFolder(dir); err != nil {
+			m.setNotice("Can't open the folder: "+err.Error(), true)
+		} else {
+			m.setNotice("Opened "+dir, false)
+		}
+	case keyMsg.String() == "u":
+		e := m.entries[m.cursor]
+		if e.Undone || len(e.Outputs) == 0 {
+			m.setNotice("Nothing to undo", true)
+			break
+		}
+		if undoing != e.ID {
+			// Deleting files asks for a second press
+			m.undoing = e.ID
+			m.setNotice(fmt.Sprintf("Press U again to delete %d output file(s); the originals are kept", len(e.Outputs)), true)
+			break
+		}
+		m.undo(e)
+	}
+	return m, nil
+}
+
+// undo deletes an entry's outputs
+func (m *HistoryModel) undo(e history.Entry) {
+	removed, kept, err := history.Undo(e.ID)
+	logging.Info("Undid operation from history", map[string]interface{}{
+		"entry":   e.ID,
+		"removed": removed,
+		"kept":    kept,
+	})
+	if err != nil {
+		logging.Error("Undo failed", map[string]interface{}{
+			"entry": e.ID,
+			"error": err.Error(),
+		})
+		m.setNotice(fmt.Sprintf("Removed %d file(s), then failed: %v", removed, err), true)
+	} else if kept > 0 {
+		m.setNotice(fmt.Sprintf("Removed %d output file(s), kept %d changed since", removed, kept), true)
+	} else {
+		m.setNotice(fmt.Sprintf("Removed %d output file(s)", removed), false)
+	}
+
+	// Re-runs may have added entries since; stay on the undone one
+	m.entries = history.Entries()
+	for i, entry := range m.entries {
+		if entry.ID == e.ID {
+			m.cursor = i
+		}
+	}
+}
+
+// historyStatus renders an entry's result mark
+func historyStatus(e history.Entry) string {
+	switch {
+	case e.Undone:
+		return warningStyle.Render("↶")
+	case e.Success:
+		return depOKStyle.Render(IconCheck)
+	}
+	return depErrorStyle.Render(IconCross)
+}
+
+// historyOptions summarizes the settings an entry ran with
+func historyOptions(e history.Entry) string {
+	o := e.Options
+	var parts []string
+	switch e.Operation {
+	case history.OpConvert:
+		parts = append(parts, "to "+strings.ToUpper(o.Format))
+	case history.OpPDF:
+		parts = append(parts, fmt.Sprintf("%s at %d DPI, quality %d", strings.ToUpper(o.Format), o.Density, o.Quality))
+	case history.OpCompress:
+		switch parseCompressMethod(o.Method) {
+		case core.CompressMethodFixedSize:
+			parts = append(parts, "target "+core.FormatSize(o.TargetBytes))
+		case core.CompressMethodQuality:
+			parts = append(parts, fmt.Sprintf("SSIM floor %g", o.MinSSIM))
+			if len(o.Formats) > 0 {
+				parts = append(parts, strings.ToUpper(strings.Join(o.Formats, "/")))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("%d%% of original", o.Percent))
+		}
+	}
+	if o.NameTemplate != "" {
+		parts = append(parts, "name "+o.NameTemplate)
+	}
+	if o.Collision != "" {
+		parts = append(parts, "if exists: "+o.Collision)
+	}
+	return strings.Join(parts, " • ")
+}
+
+// View renders the history
+func (m *HistoryModel) View() string {
+	var b strings.Builder
+
+	header := headerStyle.Render(" " + IconHistory + " History ")
+	b.WriteString("\n")
+	b.WriteString(header)
+	b.WriteString("\n\n")
+
+	if len(m.entries) == 0 {
+		b.WriteString(descriptionStyle.Render("Nothing recorded yet. Finished conversions and compressions appear here."))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Esc Back"))
+		return b.String()
+	}
+
+	// Keep the cursor on screen
+	start := 0
+	if size := m.pageSize(); m.cursor >= size {
+		start = m.cursor - size + 1
+	}
+	end := min(start+m.pageSize(), len(m.entries))
+
+	for i := start; i < end; i++ {
+		e := m.entries[i]
+		pointer, style := "  ", menuItemStyle
+		if i == m.cursor {
+			pointer, style = IconPointer+" ", selectedItemStyle
+		}
+		b.WriteString(style.Render(pointer + e.Time.Format("01-02 15:04") + "  "))
+		b.WriteString(historyStatus(e))
+		b.WriteString(style.Render(" " + e.Title))
+		b.WriteString("\n")
+	}
+	if len(m.entries) > end-start {
+		b.WriteString(lipgloss.NewStyle().Foreground(subtleColor).Render(
+			fmt.Sprintf("\n  Showing %d-%d of %d", start+1, end, len(m.entries))))
+		b.WriteString("\n")
+	}
+
+	// Details of the selected entry
+	e := m.entries[m.cursor]
+	b.WriteString("\n")
+	message := e.Message
+	if e.Undone {
+		message += " (undone)"
+	}
+	if e.Success {
+		b.WriteString(successStyle.Render(message))
+	} else {
+		b.WriteString(errorStyle.Render(message))
+	}
+	b.WriteString("\n")
+	b.WriteString(descriptionStyle.Render("Settings: " + historyOptions(e)))
+	b.WriteString("\n")
+	sizes := fmt.Sprintf("Size: %s → %s • Took %s", core.FormatSize(e.InputSize), core.FormatSize(e.OutputSize), e.Duration.Round(time.Second))
+	b.WriteString(descriptionStyle.Render(sizes))
+	b.WriteString("\n")
+	switch len(e.Outputs) {
+	case 0:
+	case 1:
+		b.WriteString(descriptionStyle.Render("Output: " + e.Outputs[0]))
+		b.WriteString("\n")
+	default:
+		b.WriteString(descriptionStyle.Render(fmt.Sprintf("Outputs: %d files in %s", len(e.Outputs), outputFolder(e))))
+		b.WriteString("\n")
+	}
+
+	if m.notice != "" {
+		b.WriteString("\n")
+		if m.isError {
+			b.WriteString(warningStyle.Render(m.notice))
+		} else {
+			b.WriteString(successStyle.Render(m.notice))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("↑↓ Select • R Re-run • O Open Output Folder • U Undo • Esc Back"))
+
+	return b.String()
+}
+
+// Rerun returns the job re-run since the last call and the wizard that
+// submitted it, or 0 and nil
+func (m *HistoryModel) Rerun() (int, jobWizard) {
+	id, w := m.rerunID, m.rerun
+	m.rerunID, m.rerun = 0, nil
+	return id, w
+}
+
+// IsDone returns true when the user leaves the history view
+func (m *HistoryModel) IsDone() bool {
+	return m.done
+}
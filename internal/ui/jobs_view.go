@@ -0,0 +1,275 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"imagetool/internal/jobs"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// jobsChangedMsg reports that jobs were added or changed state or progress
+type jobsChangedMsg struct{}
+
+// waitForJobs waits for the next change of the queue's jobs
+func waitForJobs(q *jobs.Queue) tea.Cmd {
+	return func() tea.Msg {
+		<-q.Changes()
+		return jobsChangedMsg{}
+	}
+}
+
+// finishedJob returns the job with id once it has finished
+func finishedJob(q *jobs.Queue, id int) (jobs.Job, bool) {
+	job, ok := q.Get(id)
+	if !ok || !job.Status.Finished() {
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// jobProgress describes a submitted job while the wizard waits for it,
+// e.g. "⏳ Converting... 3/10 files"
+func jobProgress(q *jobs.Queue, id int, verb string) string {
+	job, ok := q.Get(id)
+	switch {
+	case !ok:
+		return "⏳ " + verb + "..."
+	case job.Status == jobs.StatusQueued:
+		if ahead := q.Ahead(id); ahead > 0 {
+			return fmt.Sprintf("⏳ Queued behind %d other job(s)", ahead)
+		}
+		return "⏳ Queued, waiting for a running job to finish"
+	case job.Total > 0:
+		return fmt.Sprintf("⏳ %s... %d/%d files", verb, job.Done, job.Total)
+	}
+	return "⏳ " + verb + "... Please wait"
+}
+
+// jobsStatusLine summarizes the jobs for the status bar, e.g. "Jobs: 1
+// running • 2 queued • 3 done". It is empty when there are no jobs.
+func jobsStatusLine(c jobs.Counts) string {
+	if c.Total() == 0 {
+		return ""
+	}
+
+	var parts []string
+	for _, p := range []struct {
+		n     int
+		label string
+	}{
+		{c.Running, "running"},
+		{c.Queued, "queued"},
+		{c.Done, "done"},
+		{c.Failed, "failed"},
+		{c.Cancelled, "cancelled"},
+	} {
+		if p.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", p.n, p.label))
+		}
+	}
+
+	style := descriptionStyle
+	if c.Failed > 0 {
+		style = warningStyle
+	}
+	return style.Render(IconJobs + " Jobs: " + strings.Join(parts, " • "))
+}
+
+// JobsModel lists background jobs and lets the user cancel or retry them
+type JobsModel struct {
+	jobs   *jobs.Queue
+	cursor int
+	height int
+	notice string
+	opened int // Job to show the result of
+
+	done bool
+}
+
+// NewJobsModel creates a jobs view for queue
+func NewJobsModel(queue *jobs.Queue) *JobsModel {
+	return &JobsModel{jobs: queue}
+}
+
+// SetSize sets the terminal size used for paging
+func (m *JobsModel) SetSize(width, height int) {
+	m.height = height
+}
+
+// pageSize returns how many jobs fit on screen
+func (m *JobsModel) pageSize() int {
+	if m.height <= 0 {
+		return 10
+	}
+	// Header, counts, help and the status bar take roughly 10 lines, and
+	// each job two
+	if size := (m.height - 10) / 2; size > 3 {
+		return size
+	}
+	return 3
+}
+
+// Update handles input
+func (m *JobsModel) Update(msg tea.Msg) (*JobsModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	list := m.jobs.Jobs()
+	if m.cursor >= len(list) {
+		m.cursor = max(len(list)-1, 0)
+	}
+	m.notice = ""
+
+	switch {
+	case key.Matches(keyMsg, keys.Up):
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case key.Matches(keyMsg, keys.Down):
+		if m.cursor < len(list)-1 {
+			m.cursor++
+		}
+	case key.Matches(keyMsg, keys.Enter):
+		if len(list) > 0 {
+			m.opened = list[m.cursor].ID
+		}
+	case keyMsg.String() == "x":
+		if len(list) > 0 && m.jobs.Cancel(list[m.cursor].ID) {
+			m.notice = "Cancelling " + list[m.cursor].Title
+		}
+	case keyMsg.String() == "r":
+		if len(list) == 0 {
+			break
+		}
+		if _, ok := m.jobs.Retry(list[m.cursor].ID); ok {
+			m.notice = "Queued " + list[m.cursor].Title + " again"
+			m.cursor = len(list) // The retry is added at the end
+		} else {
+			m.notice = "Only failed or cancelled jobs can be retried"
+		}
+	case keyMsg.String() == "d":
+		if removed := m.jobs.ClearFinished(); removed > 0 {
+			m.notice = fmt.Sprintf("Cleared %d finished job(s)", removed)
+			m.cursor = 0
+		}
+	case key.Matches(keyMsg, keys.Back), keyMsg.String() == "q":
+		m.done = true
+	}
+	return m, nil
+}
+
+// jobStatusStyle returns the style for a job status
+func jobStatusStyle(s jobs.Status) lipgloss.Style {
+	switch s {
+	case jobs.StatusRunning:
+		return progressStyle
+	case jobs.StatusDone:
+		return depOKStyle
+	case jobs.StatusFailed:
+		return depErrorStyle
+	case jobs.StatusCancelled:
+		return warningStyle
+	}
+	return lipgloss.NewStyle().Foreground(subtleColor)
+}
+
+// jobDetail describes a job's progress or result
+func jobDetail(job jobs.Job) string {
+	switch job.Status {
+	case jobs.StatusQueued:
+		return "Waiting since " + job.Submitted.Format("15:04:05")
+	case jobs.StatusRunning:
+		elapsed := time.Since(job.Started).Round(time.Second)
+		if job.Total > 0 {
+			return fmt.Sprintf("%d/%d files • %s", job.Done, job.Total, elapsed)
+		}
+		return "Running for " + elapsed.String()
+	}
+
+	detail := job.Outcome.Message
+	if detail == "" && job.Outcome.Err != nil {
+		detail = job.Outcome.Err.Error()
+	}
+	if job.Started.IsZero() {
+		return detail // Cancelled before it ran
+	}
+	return fmt.Sprintf("%s (%s)", detail, job.Finished.Sub(job.Started).Round(time.Second))
+}
+
+// View renders the jobs list
+func (m *JobsModel) View() string {
+	var b strings.Builder
+
+	header := headerStyle.Render(" " + IconJobs + " Jobs ")
+	b.WriteString("\n")
+	b.WriteString(header)
+	b.WriteString("\n\n")
+
+	list := m.jobs.Jobs()
+	if len(list) == 0 {
+		b.WriteString(descriptionStyle.Render("No jobs yet. Conversions and compressions started from the menu are listed here."))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Esc Back"))
+		return b.String()
+	}
+	cursor := min(m.cursor, len(list)-1)
+
+	// Keep the cursor on screen
+	start := 0
+	if size := m.pageSize(); cursor >= size {
+		start = cursor - size + 1
+	}
+	end := min(start+m.pageSize(), len(list))
+
+	for i := start; i < end; i++ {
+		job := list[i]
+		pointer, style := "  ", menuItemStyle
+		if i == cursor {
+			pointer, style = IconPointer+" ", selectedItemStyle
+		}
+		title := job.Title
+		if job.RetryOf != 0 {
+			title += fmt.Sprintf(" (retry of #%d)", job.RetryOf)
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%s#%d %s", pointer, job.ID, title)))
+		b.WriteString(" ")
+		b.WriteString(jobStatusStyle(job.Status).Render("[" + job.Status.String() + "]"))
+		b.WriteString("\n")
+		b.WriteString(descriptionStyle.Render("      " + jobDetail(job)))
+		b.WriteString("\n")
+	}
+	if len(list) > end-start {
+		b.WriteString(lipgloss.NewStyle().Foreground(subtleColor).Render(
+			fmt.Sprintf("\n  Showing %d-%d of %d", start+1, end, len(list))))
+		b.WriteString("\n")
+	}
+
+	if m.notice != "" {
+		b.WriteString("\n")
+		b.WriteString(successStyle.Render(m.notice))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("↑↓ Select • Enter Open • X Cancel • R Retry • D Clear Finished • Esc Back"))
+
+	return b.String()
+}
+
+// Opened returns the job the user asked to open since the last call, or 0
+func (m *JobsModel) Opened() int {
+	id := m.opened
+	m.opened = 0
+	return id
+}
+
+// IsDone returns true when the user leaves the jobs view
+func (m *JobsModel) IsDone() bool {
+	return m.done
+}
@@ -0,0 +1,325 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"imagetool/internal/logging"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LogTab selects what the log viewer shows
+type LogTab int
+
+const (
+	LogTabEntries LogTab = iota
+	LogTabSessionErrors
+)
+
+// maxLogEntries caps how many recent entries the viewer loads
+const maxLogEntries = 2000
+
+// LogViewerModel pages through recent log entries
+type LogViewerModel struct {
+	tab      LogTab
+	entries  []logging.LogEntry
+	filtered []logging.LogEntry
+	err      error
+
+	// Filtering
+	minLevel    logging.Level
+	searching   bool
+	searchInput textinput.Model
+	query       string
+
+	// Scrolling (offset counts from the newest entry)
+	offset int
+	height int
+
+	done bool
+}
+
+// NewLogViewerModel creates a log viewer and loads recent entries
+func NewLogViewerModel() *LogViewerModel {
+	si := textinput.New()
+	si.Placeholder = "search text..."
+	si.CharLimit = 100
+	si.Width = 40
+
+	m := &LogViewerModel{
+		minLevel:    logging.LevelDebug,
+		searchInput: si,
+	}
+	m.reload()
+	return m
+}
+
+// SetSize sets the terminal size used for paging
+func (m *LogViewerModel) SetSize(width, height int) {
+	m.height = height
+}
+
+// reload reads recent entries from the log directory
+func (m *LogViewerModel) reload() {
+	m.err = nil
+	dir := logging.Dir()
+	if dir == "" {
+		m.err = fmt.Errorf("logging is not initialized")
+		m.entries = nil
+	} else {
+		m.entries, m.err = logging.ReadRecent(dir, maxLogEntries)
+	}
+	m.applyFilter()
+}
+
+// applyFilter rebuilds the visible list from level and search filters
+func (m *LogViewerModel) applyFilter() {
+	query := strings.ToLower(m.query)
+	m.filtered = m.filtered[:0]
+	for _, e := range m.entries {
+		if e.Level < m.minLevel {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(formatLogEntry(e)), query) {
+			continue
+		}
+		m.filtered = append(m.filtered, e)
+	}
+	m.offset = 0
+}
+
+// pageSize returns how many entries fit on screen
+func (m *LogViewerModel) pageSize() int {
+	if m.height <= 0 {
+		return 15
+	}
+	// Header, filter bar and help take roughly 12 lines
+	if size := m.height - 12; size > 5 {
+		return size
+	}
+	return 5
+}
+
+// Update handles input
+func (m *LogViewerModel) Update(msg tea.Msg) (*LogViewerModel, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.SetSize(size.Width, size.Height)
+		return m, nil
+	}
+
+	if m.searching {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				m.query = strings.TrimSpace(m.searchInput.Value())
+				m.searching = false
+				m.searchInput.Blur()
+				m.applyFilter()
+				return m, nil
+			case "esc":
+				m.searching = false
+				m.searchInput.Blur()
+				m.searchInput.SetValue(m.query)
+				return m, nil
+			}
+		}
+		var cmd tea.Cmd
+		m.searchInput, cmd = m.searchInput.Update(msg)
+		return m, cmd
+	}
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	maxOffset := len(m.filtered) - m.pageSize()
+	if maxOffset < 0 {
+		maxOffset = 0
+	}
+
+	switch {
+	case key.Matches(keyMsg, keys.Up):
+		if m.offset < maxOffset {
+			m.offset++
+		}
+	case key.Matches(keyMsg, keys.Down):
+		if m.offset > 0 {
+			m.offset--
+		}
+	case keyMsg.String() == "pgup":
+		m.offset += m.pageSize()
+		if m.offset > maxOffset {
+			m.offset = maxOffset
+		}
+	case keyMsg.String() == "pgdown":
+		m.offset -= m.pageSize()
+		if m.offset < 0 {
+			m.offset = 0
+		}
+	case keyMsg.String() == "home":
+		m.offset = maxOffset
+	case keyMsg.String() == "end":
+		m.offset = 0
+	case keyMsg.String() == "tab":
+		if m.tab == LogTabEntries {
+			m.tab = LogTabSessionErrors
+		} else {
+			m.tab = LogTabEntries
+		}
+	case keyMsg.String() == "l":
+		m.minLevel = (m.minLevel + 1) % (logging.LevelError + 1)
+		m.applyFilter()
+	case keyMsg.String() == "/":
+		m.searching = true
+		m.searchInput.SetValue(m.query)
+		m.searchInput.Focus()
+		return m, textinput.Blink
+	case keyMsg.String() == "c":
+		m.query = ""
+		m.searchInput.SetValue("")
+		m.applyFilter()
+	case keyMsg.String() == "r":
+		m.reload()
+	case key.Matches(keyMsg, keys.Back), keyMsg.String() == "q":
+		m.done = true
+	}
+
+	return m, nil
+}
+
+// formatLogEntry renders an entry as one plain line
+func formatLogEntry(e logging.LogEntry) string {
+	line := fmt.Sprintf("%s %-5s %s", e.Time.Format("01-02 15:04:05"), e.Level.String(), e.Message)
+	if len(e.Context) > 0 {
+		keys := make([]string, 0, len(e.Context))
+		for k := range e.Context {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line += fmt.Sprintf(" %s=%v", k, e.Context[k])
+		}
+	}
+	return line
+}
+
+// logLevelStyle returns the style for a log level
+func logLevelStyle(level logging.Level) lipgloss.Style {
+	switch level {
+	case logging.LevelError:
+		return depErrorStyle
+	case logging.LevelWarn:
+		return warningStyle
+	case logging.LevelDebug:
+		return lipgloss.NewStyle().Foreground(subtleColor)
+	}
+	return lipgloss.NewStyle()
+}
+
+// View renders the log viewer
+func (m *LogViewerModel) View() string {
+	var b strings.Builder
+
+	header := headerStyle.Render(" " + IconLogs + " Logs ")
+	b.WriteString("\n")
+	b.WriteString(header)
+	b.WriteString("\n\n")
+
+	// Tabs
+	entriesTab, errorsTab := menuItemStyle, menuItemStyle
+	if m.tab == LogTabEntries {
+		entriesTab = selectedItemStyle
+	} else {
+		errorsTab = selectedItemStyle
+	}
+	sessionErrors := logging.GetErrors()
+	b.WriteString(entriesTab.Render("Recent entries"))
+	b.WriteString(errorsTab.Render(fmt.Sprintf("Session errors (%d)", len(sessionErrors))))
+	b.WriteString("\n\n")
+
+	if m.tab == LogTabSessionErrors {
+		b.WriteString(m.viewSessionErrors(sessionErrors))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Tab Recent entries • Esc Back"))
+		return b.String()
+	}
+
+	// Filter bar
+	filter := fmt.Sprintf("Level: %s+", m.minLevel.String())
+	if m.query != "" {
+		filter += fmt.Sprintf(" • Search: %q", m.query)
+	}
+	filter += fmt.Sprintf(" • %d of %d entries", len(m.filtered), len(m.entries))
+	b.WriteString(inputLabelStyle.Render(filter))
+	b.WriteString("\n")
+	if dir := logging.Dir(); dir != "" {
+		b.WriteString(descriptionStyle.Render("Log folder: " + dir))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	if m.searching {
+		b.WriteString(inputLabelStyle.Render("Search: "))
+		b.WriteString(m.searchInput.View())
+		b.WriteString("\n\n")
+	}
+
+	if m.err != nil {
+		b.WriteString(errorStyle.Render("Error: " + m.err.Error()))
+		b.WriteString("\n\n")
+	}
+
+	if len(m.filtered) == 0 {
+		b.WriteString(warningStyle.Render("No log entries match the current filters."))
+		b.WriteString("\n")
+	} else {
+		// Newest entries at the bottom; offset scrolls back in time
+		end := len(m.filtered) - m.offset
+		start := end - m.pageSize()
+		if start < 0 {
+			start = 0
+		}
+		for _, e := range m.filtered[start:end] {
+			b.WriteString(logLevelStyle(e.Level).Render(formatLogEntry(e)))
+			b.WriteString("\n")
+		}
+		b.WriteString(lipgloss.NewStyle().Foreground(subtleColor).Render(
+			fmt.Sprintf("\n  Showing %d-%d of %d", start+1, end, len(m.filtered))))
+	}
+
+	b.WriteString("\n\n")
+	if m.searching {
+		b.WriteString(helpStyle.Render("Enter Apply search • Esc Cancel"))
+	} else {
+		b.WriteString(helpStyle.Render("↑↓ Scroll • PgUp/PgDn Page • L Level • / Search • C Clear • R Reload • Tab Session errors • Esc Back"))
+	}
+
+	return b.String()
+}
+
+// viewSessionErrors renders errors collected during this session
+func (m *LogViewerModel) viewSessionErrors(errs []logging.LogEntry) string {
+	if len(errs) == 0 {
+		return successStyle.Render(IconCheck + " No errors logged this session")
+	}
+
+	var b strings.Builder
+	b.WriteString(errorStyle.Render(strings.TrimSpace(logging.GetErrorSummary())))
+	b.WriteString("\n\n")
+	for i, e := range errs {
+		b.WriteString(logLevelStyle(e.Level).Render(fmt.Sprintf("  %d. %s", i+1, formatLogEntry(e))))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// IsDone returns true when the user leaves the log viewer
+func (m *LogViewerModel) IsDone() bool {
+	return m.done
+}
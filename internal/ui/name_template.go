@@ -0,0 +1,182 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"imagetool/internal/core"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// templateTokensHelp lists the tokens available in name templates
+const templateTokensHelp = "Tokens: {name} {ext} {format} {page:03} {width} {height} {date} {hash8} {preset}"
+
+// nameTemplateEditor edits an output name template and previews the file
+// name it produces for the current input
+type nameTemplateEditor struct {
+	input   textinput.Model
+	value   string // Applied template
+	editing bool
+	pages   bool // PDF output: {page} is added if missing
+
+	data   *core.TemplateData
+	scale  float64 // Output pixels per probed pixel, e.g. density/72 for PDFs
+	probed bool    // Dimensions have been looked up
+}
+
+// newNameTemplateEditor creates an editor holding the given template
+func newNameTemplateEditor(value string) nameTemplateEditor {
+	ti := textinput.New()
+	ti.Placeholder = value
+	ti.CharLimit = 120
+	ti.Width = 40
+
+	return nameTemplateEditor{input: ti, value: value, scale: 1}
+}
+
+// setInput sets the file and settings the preview is computed for
+func (e *nameTemplateEditor) setInput(path, format, preset string, scale float64) {
+	e.data = core.NewTemplateData(path, format, preset)
+	e.scale = scale
+	e.probed = false
+}
+
+// template returns the applied template as the core expands it
+func (e *nameTemplateEditor) template() string {
+	if e.pages {
+		return core.PageTemplate(e.value)
+	}
+	return e.value
+}
+
+// start begins editing the applied template
+func (e *nameTemplateEditor) start() tea.Cmd {
+	e.editing = true
+	e.input.SetValue(e.value)
+	e.input.CursorEnd()
+	e.input.Focus()
+	return textinput.Blink
+}
+
+// stop ends editing without applying the input
+func (e *nameTemplateEditor) stop() {
+	e.editing = false
+	e.input.Blur()
+}
+
+// apply stores the input as the template if it is valid. An empty input
+// keeps the current template.
+func (e *nameTemplateEditor) apply() error {
+	val := strings.TrimSpace(e.input.Value())
+	if val == "" {
+		val = e.value
+	}
+	if _, err := e.expand(val); err != nil {
+		return err
+	}
+	e.value = val
+	e.stop()
+	return nil
+}
+
+// update passes a message to the text input while editing
+func (e *nameTemplateEditor) update(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	e.input, cmd = e.input.Update(msg)
+	return cmd
+}
+
+// expand returns the file name tmpl produces for the current input,
+// looking up the input's dimensions the first time they're needed. A name
+// that would replace the input next to it is an error.
+func (e *nameTemplateEditor) expand(tmpl string) (string, error) {
+	if err := core.ValidateTemplate(tmpl); err != nil {
+		return "", err
+	}
+	if e.pages {
+		tmpl = core.PageTemplate(tmpl)
+	}
+	if e.data == nil {
+		return "", nil
+	}
+
+	usesDims := core.TemplateUses(tmpl, core.TokenWidth) || core.TemplateUses(tmpl, core.TokenHeight)
+	if usesDims && !e.probed {
+		e.probed = true
+		if w, h, err := core.ImageDimensions(e.data.InputPath); err == nil {
+			e.data.Width = int(float64(w) * e.scale)
+			e.data.Height = int(float64(h) * e.scale)
+		}
+	}
+
+	name, err := e.data.Expand(tmpl)
+	if err != nil {
+		return "", err
+	}
+	name += "." + e.data.Format
+	if err := core.CheckNotInput(e.data.InputPath, filepath.Join(filepath.Dir(e.data.InputPath), name)); err != nil {
+		return "", fmt.Errorf("%w: %s", core.ErrOutputIsInput, name)
+	}
+	return name, nil
+}
+
+// preview renders the file name(s) the template being edited produces
+func (e *nameTemplateEditor) preview() string {
+	tmpl := e.value
+	if e.editing {
+		tmpl = strings.TrimSpace(e.input.Value())
+		if tmpl == "" {
+			tmpl = e.value
+		}
+	}
+
+	first, err := e.expand(tmpl)
+	if err != nil {
+		return errorStyle.Render("Template error: " + err.Error())
+	}
+	if !e.pages || e.data == nil {
+		return descriptionStyle.Render("Output: " + first)
+	}
+
+	page := e.data.Page
+	e.data.Page = page + 1
+	second, _ := e.expand(tmpl)
+	e.data.Page = page
+	return descriptionStyle.Render("Output: " + first + ", " + second + ", ...")
+}
+
+// View renders the text input with the live preview and token help
+func (e *nameTemplateEditor) View() string {
+	var b strings.Builder
+	b.WriteString(e.input.View())
+	b.WriteString("\n\n")
+	b.WriteString(e.preview())
+	b.WriteString("\n")
+	b.WriteString(descriptionStyle.Render(templateTokensHelp))
+	return b.String()
+}
+
+// pagePaths returns the files the applied template produces in dir for
+// the first pages of a PDF, or nil if it can't be expanded
+func (e *nameTemplateEditor) pagePaths(dir string, pages int) []string {
+	if e.data == nil {
+		return nil
+	}
+
+	page := e.data.Page
+	defer func() { e.data.Page = page }()
+
+	paths := make([]string, 0, pages)
+	for i := 0; i < pages; i++ {
+		e.data.Page = i
+		name, err := e.expand(e.value)
+		if err != nil {
+			return nil
+		}
+		paths = append(paths, filepath.Join(dir, name))
+	}
+	return paths
+}
@@ -1,12 +1,18 @@
 package ui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"imagetool/internal/config"
 	"imagetool/internal/core"
+	"imagetool/internal/history"
+	"imagetool/internal/jobs"
 	"imagetool/internal/logging"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -22,7 +28,7 @@ const (
 	PDFStepSelectFormat
 	PDFStepSetDensity
 	PDFStepSetQuality
-	PDFStepSetPrefix
+	PDFStepSetTemplate
 	PDFStepConfirm
 	PDFStepConverting
 	PDFStepDone
@@ -32,14 +38,20 @@ const (
 type PDFConverterModel struct {
 	step       PDFStep
 	filePicker *FilePickerModel
+	cfg        *config.Config
+	jobs       *jobs.Queue
+	jobID      int // The submitted conversion
+	submitted  int // Job submitted since the app last asked
 
 	// Settings
 	inputFile    string
 	outputFormat string
 	density      int
 	quality      int
-	prefix       string
 	outputDir    string
+	pageCount    int // Estimated, 0 if unknown
+	collision    core.CollisionPolicy
+	existing     []string // Page outputs that already exist
 
 	// Format selection
 	formats      []string
@@ -48,21 +60,25 @@ type PDFConverterModel struct {
 	// Text inputs
 	densityInput textinput.Model
 	qualityInput textinput.Model
-	prefixInput  textinput.Model
+	nameTemplate nameTemplateEditor
 
 	// Results
 	result      string
 	isError     bool
+	details     errorDetails
 	outputFiles []string
+	preview     previewPane // First page
 
 	// Navigation
 	done       bool
 	backToMenu bool
+	showLogs   bool
 }
 
-// NewPDFConverterModel creates a new PDF converter
-func NewPDFConverterModel() *PDFConverterModel {
-	fp := NewFilePickerModel()
+// NewPDFConverterModel creates a new PDF converter whose conversions run
+// as jobs on queue
+func NewPDFConverterModel(cfg *config.Config, queue *jobs.Queue) *PDFConverterModel {
+	fp := NewFilePickerModel(cfg)
 	fp.SetMode(FilePickerPDF)
 
 	densityInput := textinput.New()
@@ -75,23 +91,24 @@ func NewPDFConverterModel() *PDFConverterModel {
 	qualityInput.CharLimit = 3
 	qualityInput.Width = 10
 
-	prefixInput := textinput.New()
-	prefixInput.Placeholder = config.DefaultPrefix
-	prefixInput.CharLimit = 50
-	prefixInput.Width = 30
+	nameTemplate := newNameTemplateEditor(cfg.Templates.PDF)
+	nameTemplate.pages = true
 
 	return &PDFConverterModel{
 		step:         PDFStepSelectFile,
 		filePicker:   fp,
+		cfg:          cfg,
+		jobs:         queue,
 		formats:      config.SupportedPDFOutputFormats,
 		formatCursor: 0,
 		outputFormat: config.DefaultOutputFormat,
 		density:      config.DefaultDensity,
 		quality:      config.DefaultQuality,
-		prefix:       config.DefaultPrefix,
 		densityInput: densityInput,
 		qualityInput: qualityInput,
-		prefixInput:  prefixInput,
+		nameTemplate: nameTemplate,
+		collision:    core.ParseCollisionPolicy(cfg.Collision),
+		preview:      newPreviewPane(cfg),
 	}
 }
 
@@ -100,6 +117,17 @@ type conversionResultMsg struct {
 	message string
 	isError bool
 	files   []string
+	err     error
+}
+
+// SetSize sets the terminal size, which the file picker fits its list to
+func (m *PDFConverterModel) SetSize(width, height int) {
+	m.filePicker.SetSize(width, height)
+}
+
+// Init starts loading the file picker's preview
+func (m *PDFConverterModel) Init() tea.Cmd {
+	return m.filePicker.Init()
 }
 
 // Update handles input
@@ -115,6 +143,7 @@ func (m *PDFConverterModel) Update(msg tea.Msg) (*PDFConverterModel, tea.Cmd) {
 				m.done = true
 			} else {
 				m.inputFile = m.filePicker.SelectedFile()
+				m.pageCount = core.PDFPageCount(m.inputFile)
 				m.step = PDFStepSelectFormat
 				// Set default output directory
 				m.outputDir = filepath.Join(filepath.Dir(m.inputFile),
@@ -208,10 +237,12 @@ func (m *PDFConverterModel) Update(msg tea.Msg) (*PDFConverterModel, tea.Cmd) {
 						m.quality = 100
 					}
 				}
-				m.step = PDFStepSetPrefix
+				m.step = PDFStepSetTemplate
 				m.qualityInput.Blur()
-				m.prefixInput.Focus()
-				return m, textinput.Blink
+				// Pixel sizes scale with the density the pages are rendered at
+				m.nameTemplate.setInput(m.inputFile, m.outputFormat,
+					core.PDFPreset(m.density, m.quality), float64(m.density)/72)
+				return m, m.nameTemplate.start()
 			case "esc":
 				m.step = PDFStepSetDensity
 				m.qualityInput.Blur()
@@ -222,41 +253,44 @@ func (m *PDFConverterModel) Update(msg tea.Msg) (*PDFConverterModel, tea.Cmd) {
 		m.qualityInput, cmd = m.qualityInput.Update(msg)
 		return m, cmd
 
-	case PDFStepSetPrefix:
+	case PDFStepSetTemplate:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter":
-				val := m.prefixInput.Value()
-				if val == "" {
-					m.prefix = config.DefaultPrefix
-				} else {
-					m.prefix = val
+				// Invalid templates stay in the editor with the error previewed
+				if m.nameTemplate.apply() == nil {
+					m.findExisting()
+					m.step = PDFStepConfirm
 				}
-				m.step = PDFStepConfirm
-				m.prefixInput.Blur()
 				return m, nil
 			case "esc":
 				m.step = PDFStepSetQuality
-				m.prefixInput.Blur()
+				m.nameTemplate.stop()
 				m.qualityInput.Focus()
 				return m, textinput.Blink
 			}
 		}
-		m.prefixInput, cmd = m.prefixInput.Update(msg)
-		return m, cmd
+		return m, m.nameTemplate.update(msg)
 
 	case PDFStepConfirm:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "y", "Y", "enter":
+				// It runs in the background; the Jobs view reopens the
+				// wizard on it
 				m.step = PDFStepConverting
-				return m, m.runConversion
+				m.jobID = m.jobs.Submit(m.jobTitle(), m.conversionJob())
+				m.submitted = m.jobID
+				m.backToMenu = true
+				m.done = true
+				return m, nil
 			case "n", "N", "esc":
-				m.step = PDFStepSetPrefix
-				m.prefixInput.Focus()
-				return m, textinput.Blink
+				m.step = PDFStepSetTemplate
+				return m, m.nameTemplate.start()
+			case "c": // Cycle what happens to existing pages
+				m.collision = m.collision.Next()
 			case "b":
 				m.backToMenu = true
 				m.done = true
@@ -266,17 +300,39 @@ func (m *PDFConverterModel) Update(msg tea.Msg) (*PDFConverterModel, tea.Cmd) {
 
 	case PDFStepConverting:
 		switch msg := msg.(type) {
+		case jobsChangedMsg:
+			if job, ok := finishedJob(m.jobs, m.jobID); ok {
+				result, ok := job.Outcome.Value.(conversionResultMsg)
+				if !ok {
+					result = conversionResultMsg{message: "Conversion cancelled", isError: true, err: job.Outcome.Err}
+				}
+				return m.Update(result)
+			}
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "x": // Cancel the conversion
+				m.jobs.Cancel(m.jobID)
+			case "esc", "m": // Leave it running in the background
+				m.backToMenu = true
+				m.done = true
+			}
 		case conversionResultMsg:
 			m.step = PDFStepDone
 			m.result = msg.message
 			m.isError = msg.isError
+			m.details = newErrorDetails(msg.err)
 			m.outputFiles = msg.files
+			if len(msg.files) > 0 {
+				return m, m.preview.request(msg.files[0])
+			}
 			return m, nil
 		}
 		return m, nil
 
 	case PDFStepDone:
 		switch msg := msg.(type) {
+		case previewMsg:
+			m.preview.update(msg)
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter", "m":
@@ -286,6 +342,12 @@ func (m *PDFConverterModel) Update(msg tea.Msg) (*PDFConverterModel, tea.Cmd) {
 				if m.outputDir != "" {
 					core.OpenFolder(m.outputDir)
 				}
+			case "d": // Toggle raw error output
+				m.details.toggle()
+			case "l": // View logs after a failure
+				if m.isError {
+					m.showLogs = true
+				}
 			case "q":
 				return m, tea.Quit
 			}
@@ -296,8 +358,33 @@ func (m *PDFConverterModel) Update(msg tea.Msg) (*PDFConverterModel, tea.Cmd) {
 	return m, nil
 }
 
+// findExisting looks for page outputs from an earlier run
+func (m *PDFConverterModel) findExisting() {
+	pages := m.pageCount
+	if pages < 1 {
+		pages = 1
+	}
+	m.existing = core.ExistingOutputs(m.nameTemplate.pagePaths(m.outputDir, pages))
+}
+
+// jobTitle names the conversion in the jobs list and history
+func (m *PDFConverterModel) jobTitle() string {
+	return fmt.Sprintf("Convert %s to %s", filepath.Base(m.inputFile), strings.ToUpper(m.outputFormat))
+}
+
+// conversionJob returns the job that runs the conversion. It works on a
+// copy of the settings, so the wizard can move on and a retry repeats the
+// same conversion.
+func (m *PDFConverterModel) conversionJob() jobs.Func {
+	settings := *m
+	return func(ctx context.Context, progress jobs.Progress) jobs.Outcome {
+		result := settings.runConversion(ctx)
+		return jobs.Outcome{Message: result.message, Err: result.err, Value: result}
+	}
+}
+
 // runConversion executes the conversion via core package
-func (m *PDFConverterModel) runConversion() tea.Msg {
+func (m *PDFConverterModel) runConversion(ctx context.Context) conversionResultMsg {
 	logging.Info("Starting PDF conversion", map[string]interface{}{
 		"input":   m.inputFile,
 		"format":  m.outputFormat,
@@ -305,19 +392,28 @@ func (m *PDFConverterModel) runConversion() tea.Msg {
 		"quality": m.quality,
 	})
 
-	result := core.ConvertPDFToImages(core.ConvertPDFOptions{
+	// Undo may only remove the output folder if this run creates it
+	_, statErr := os.Stat(m.outputDir)
+	createdDir := m.outputDir != "" && errors.Is(statErr, os.ErrNotExist)
+
+	start := time.Now()
+	result := core.ConvertPDFToImagesContext(ctx, core.ConvertPDFOptions{
 		InputPath:    m.inputFile,
 		OutputFormat: core.ImageFormat(m.outputFormat),
 		OutputDir:    m.outputDir,
 		Density:      m.density,
 		Quality:      m.quality,
-		Prefix:       m.prefix,
+		NameTemplate: m.nameTemplate.template(),
+		Collision:    m.collision,
+		Limits:       config.CoreLimits(m.cfg),
+		Timeout:      config.CoreTimeout(m.cfg),
 	})
 
 	if !result.Success {
 		logging.Error("PDF conversion failed", map[string]interface{}{
-			"input": m.inputFile,
-			"error": result.Message,
+			"input":   m.inputFile,
+			"error":   result.Message,
+			"details": result.Error,
 		})
 	} else {
 		logging.Info("PDF conversion completed", map[string]interface{}{
@@ -327,10 +423,28 @@ func (m *PDFConverterModel) runConversion() tea.Msg {
 		})
 	}
 
+	entry := history.Entry{
+		Operation: history.OpPDF,
+		Title:     m.jobTitle(),
+		Inputs:    []string{m.inputFile},
+		Options: history.Options{
+			Format:       m.outputFormat,
+			Density:      m.density,
+			Quality:      m.quality,
+			NameTemplate: m.nameTemplate.value,
+			Collision:    m.collision.String(),
+			Output:       m.outputDir,
+		},
+		Outputs:       resultOutputs(result),
+		CreatedOutput: createdDir,
+	}
+	recordHistory(entry, start, result.Success, result.Message)
+
 	return conversionResultMsg{
 		message: result.Message,
 		isError: !result.Success,
 		files:   result.OutputPaths,
+		err:     result.Error,
 	}
 }
 
@@ -383,13 +497,10 @@ func (m *PDFConverterModel) View() string {
 		b.WriteString("\n\n")
 		b.WriteString(helpStyle.Render("Enter to confirm • Esc Back"))
 
-	case PDFStepSetPrefix:
-		b.WriteString(inputLabelStyle.Render("Set filename prefix:"))
-		b.WriteString("\n\n")
-		b.WriteString(m.prefixInput.View())
+	case PDFStepSetTemplate:
+		b.WriteString(inputLabelStyle.Render("Set output name template:"))
 		b.WriteString("\n\n")
-		b.WriteString(descriptionStyle.Render(fmt.Sprintf("Output: %s0.%s, %s1.%s, ... Default: %s",
-			m.prefix, m.outputFormat, m.prefix, m.outputFormat, config.DefaultPrefix)))
+		b.WriteString(m.nameTemplate.View())
 		b.WriteString("\n\n")
 		b.WriteString(helpStyle.Render("Enter to confirm • Esc Back"))
 
@@ -402,30 +513,45 @@ func (m *PDFConverterModel) View() string {
 				fmt.Sprintf("Format:   %s\n", strings.ToUpper(m.outputFormat)) +
 				fmt.Sprintf("Density:  %d DPI\n", m.density) +
 				fmt.Sprintf("Quality:  %d\n", m.quality) +
-				fmt.Sprintf("Prefix:   %s\n", m.prefix) +
-				fmt.Sprintf("Output:   %s", m.outputDir),
+				fmt.Sprintf("Names:    %s\n", m.nameTemplate.template()) +
+				fmt.Sprintf("Output:   %s\n", m.outputDir) +
+				fmt.Sprintf("Exists:   %s", m.collision),
 		)
 		b.WriteString(summaryBox)
 		b.WriteString("\n\n")
+		if warning := collisionWarning(m.existing, 0, m.collision); warning != "" {
+			b.WriteString(warningStyle.Render(warning))
+			b.WriteString("\n\n")
+		}
 		b.WriteString(warningStyle.Render("Proceed with conversion? (Y/n)"))
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Y/Enter Proceed • N/Esc Back • B Menu"))
+		b.WriteString(helpStyle.Render("Y/Enter Proceed • C If Exists • N/Esc Back • B Menu"))
 
 	case PDFStepConverting:
 		b.WriteString("\n")
-		b.WriteString(progressStyle.Render("⏳ Converting... Please wait"))
-		b.WriteString("\n")
+		b.WriteString(progressStyle.Render(jobProgress(m.jobs, m.jobID, "Converting")))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Esc/M Menu (keeps running) • X Cancel"))
 
 	case PDFStepDone:
 		if m.isError {
 			b.WriteString(errorStyle.Render(IconError + " " + m.result))
+			b.WriteString(m.details.View())
 		} else {
 			b.WriteString(successStyle.Render(IconSuccess + " " + m.result))
 			b.WriteString("\n\n")
 			b.WriteString(descriptionStyle.Render("Output folder: " + m.outputDir))
+			if preview := m.preview.View(); preview != "" {
+				b.WriteString("\n\n")
+				b.WriteString(preview)
+			}
 		}
 		b.WriteString("\n\n")
-		b.WriteString(helpStyle.Render("Enter/M Menu • O Open Folder • Q Quit"))
+		if m.isError {
+			b.WriteString(helpStyle.Render("Enter/M Menu • O Open Folder • L View Logs" + m.details.helpKey() + " • Q Quit"))
+		} else {
+			b.WriteString(helpStyle.Render("Enter/M Menu • O Open Folder • Q Quit"))
+		}
 	}
 
 	return b.String()
@@ -440,3 +566,32 @@ func (m *PDFConverterModel) IsDone() bool {
 func (m *PDFConverterModel) BackToMenu() bool {
 	return m.backToMenu
 }
+
+// WantsLogs reports whether the user asked to view logs from the Done
+// step, clearing the request
+func (m *PDFConverterModel) WantsLogs() bool {
+	wants := m.showLogs
+	m.showLogs = false
+	return wants
+}
+
+// previewPanes returns the previews the wizard may show
+func (m *PDFConverterModel) previewPanes() []*previewPane {
+	return []*previewPane{&m.preview, &m.filePicker.preview}
+}
+
+// Submitted returns the job submitted since the last call, or 0
+func (m *PDFConverterModel) Submitted() int {
+	id := m.submitted
+	m.submitted = 0
+	return id
+}
+
+// Follow reopens the wizard on job id, one it submitted or a retry of
+// one, showing its progress and then its result
+func (m *PDFConverterModel) Follow(id int) {
+	m.jobID = id
+	m.step = PDFStepConverting
+	m.done = false
+	m.backToMenu = false
+}
@@ -0,0 +1,508 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"imagetool/internal/config"
+	"imagetool/internal/core"
+)
+
+// graphicsProtocol is how image previews are drawn
+type graphicsProtocol int
+
+const (
+	graphicsOff    graphicsProtocol = iota
+	graphicsBlocks                  // Unicode half blocks with ANSI colors
+	graphicsSixel
+	graphicsKitty
+)
+
+const (
+	// previewThumbSize is the largest side of preview thumbnails in pixels
+	previewThumbSize = 256
+
+	// previewCols and previewRows bound the preview in terminal cells
+	previewCols = 36
+	previewRows = 16
+
+	// Sixel draws in pixels; assume a common cell size
+	cellWidthPx  = 10
+	cellHeightPx = 20
+
+	// kittyImageID identifies the first preview to the Kitty protocol so
+	// it can be replaced and deleted; other slots follow it
+	kittyImageID = 4171
+
+	// previewSlots is how many previews a screen can show at once
+	previewSlots = 2
+
+	// previewCacheSize bounds the decoded thumbnails kept in memory
+	previewCacheSize = 64
+
+	// graphicsDrawDelay waits for the frame to be flushed before a Kitty
+	// or Sixel image is drawn over it
+	graphicsDrawDelay = 50 * time.Millisecond
+)
+
+// previewMarkerPattern matches the marker of a Kitty or Sixel preview.
+// The renderer truncates long lines, which would cut an image escape
+// short, so a preview's View holds only this marker, which layout code
+// measures as zero width. App removes it from the frame and draws the
+// image there once the frame is on screen.
+var previewMarkerPattern = regexp.MustCompile("\x1b\\[8;([0-9]+);28m")
+
+// previewMarker returns the marker of the preview with id
+func previewMarker(id int) string {
+	return fmt.Sprintf("\x1b[8;%d;28m", id)
+}
+
+// lastPreviewID numbers the preview panes, so a marker names its pane
+var lastPreviewID atomic.Int64
+
+// kittyDelete removes the Kitty preview in slot and frees its data
+func kittyDelete(slot int) string {
+	return fmt.Sprintf("\x1b_Ga=d,d=I,i=%d,q=2\x1b\\", kittyImageID+slot)
+}
+
+// detectGraphics returns the protocol for the preview setting, checking
+// the terminal for "auto"
+func detectGraphics(setting string) graphicsProtocol {
+	switch setting {
+	case "off":
+		return graphicsOff
+	case "blocks":
+		return graphicsBlocks
+	case "sixel":
+		return graphicsSixel
+	case "kitty":
+		return graphicsKitty
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case term == "xterm-kitty", os.Getenv("KITTY_WINDOW_ID") != "",
+		os.Getenv("TERM_PROGRAM") == "WezTerm", os.Getenv("TERM_PROGRAM") == "ghostty":
+		return graphicsKitty
+	case strings.Contains(term, "sixel"), strings.HasPrefix(term, "foot"), term == "mlterm",
+		os.Getenv("TERM_PROGRAM") == "mintty":
+		return graphicsSixel
+	}
+	return graphicsBlocks
+}
+
+// previewMsg carries a loaded thumbnail
+type previewMsg struct {
+	path string
+	img  image.Image
+	png  []byte
+	err  error
+}
+
+// previewImage is a decoded thumbnail
+type previewImage struct {
+	img image.Image
+	png []byte
+}
+
+// previewPane shows a thumbnail of one file
+type previewPane struct {
+	protocol graphicsProtocol
+	id       int // Named by the pane's marker
+	slot     int // Tells previews on the same screen apart
+	path     string
+	img      image.Image
+	err      error
+	rendered string // Half-block text, or the Kitty/Sixel escape
+	rows     int
+	cache    map[string]previewImage
+}
+
+// newPreviewPane creates a pane using the configured protocol
+func newPreviewPane(cfg *config.Config) previewPane {
+	setting := config.DefaultPreview
+	if cfg != nil {
+		setting = cfg.Preview
+	}
+	return previewPane{
+		id:       int(lastPreviewID.Add(1)),
+		protocol: detectGraphics(setting),
+		cache:    make(map[string]previewImage),
+	}
+}
+
+// enabled reports whether previews are shown at all
+func (p *previewPane) enabled() bool {
+	return p.protocol != graphicsOff
+}
+
+// request shows the file at path, or nothing for "". The thumbnail loads
+// in the background and arrives as a previewMsg.
+func (p *previewPane) request(path string) tea.Cmd {
+	if !p.enabled() || path == p.path {
+		return nil
+	}
+	p.path = path
+	p.img = nil
+	p.err = nil
+	p.rendered = ""
+	if path == "" {
+		return nil
+	}
+
+	if cached, ok := p.cache[path]; ok {
+		return func() tea.Msg {
+			return previewMsg{path: path, img: cached.img, png: cached.png}
+		}
+	}
+	return loadPreview(path)
+}
+
+// loadPreview makes or reuses the thumbnail of path and decodes it
+func loadPreview(path string) tea.Cmd {
+	return func() tea.Msg {
+		thumb, err := core.Thumbnail(context.Background(), path, previewThumbSize)
+		if err != nil {
+			return previewMsg{path: path, err: err}
+		}
+		data, err := os.ReadFile(thumb)
+		if err != nil {
+			return previewMsg{path: path, err: err}
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		return previewMsg{path: path, img: img, png: data, err: err}
+	}
+}
+
+// update takes a loaded thumbnail if it is for the current file
+func (p *previewPane) update(msg previewMsg) {
+	if msg.path != p.path {
+		return
+	}
+	p.err = msg.err
+	if msg.err != nil {
+		return
+	}
+
+	if len(p.cache) >= previewCacheSize {
+		p.cache = make(map[string]previewImage)
+	}
+	p.cache[msg.path] = previewImage{img: msg.img, png: msg.png}
+	p.img = msg.img
+
+	bounds := p.img.Bounds()
+	cols, rows := fitCells(bounds.Dx(), bounds.Dy(), previewCols, previewRows)
+	p.rows = rows
+	switch p.protocol {
+	case graphicsKitty:
+		p.rendered = kittyImage(msg.png, p.slot, cols, rows)
+	case graphicsSixel:
+		p.rendered = sixelImage(resizeImage(p.img, cols*cellWidthPx, rows*cellHeightPx))
+	default:
+		p.rendered = halfBlocks(resizeImage(p.img, cols, rows*2))
+	}
+}
+
+// View renders the preview. Kitty and Sixel images are drawn by App over
+// the blank area returned here.
+func (p *previewPane) View() string {
+	switch {
+	case !p.enabled() || p.path == "":
+		return ""
+	case p.err != nil:
+		return descriptionStyle.Render("No preview available")
+	case p.img == nil:
+		return descriptionStyle.Render("Loading preview...")
+	case p.protocol == graphicsBlocks:
+		return p.rendered
+	}
+
+	lines := make([]string, p.rows)
+	lines[0] = previewMarker(p.id)
+	return strings.Join(lines, "\n")
+}
+
+// fitCells returns the cells an image of w×h pixels fills within
+// cols×rows, keeping its aspect ratio. A cell is about twice as tall as
+// it is wide.
+func fitCells(w, h, cols, rows int) (int, int) {
+	if w <= 0 || h <= 0 {
+		return 1, 1
+	}
+	scale := float64(cols) / float64(w)
+	if s := float64(rows*2) / float64(h); s < scale {
+		scale = s
+	}
+	c := int(float64(w)*scale + 0.5)
+	r := int(float64(h)*scale/2 + 0.5)
+	if c < 1 {
+		c = 1
+	}
+	if r < 1 {
+		r = 1
+	}
+	return c, r
+}
+
+// resizeImage scales img to w×h with nearest-neighbour sampling
+func resizeImage(img image.Image, w, h int) *image.RGBA {
+	src := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := src.Min.Y + y*src.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := src.Min.X + x*src.Dx()/w
+			dst.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// halfBlocks renders two pixel rows per line with "▀": the foreground is
+// the upper pixel and the background the lower one
+func halfBlocks(img *image.RGBA) string {
+	bounds := img.Bounds()
+	lines := make([]string, 0, bounds.Dy()/2)
+	for y := 0; y+1 < bounds.Dy(); y += 2 {
+		var line strings.Builder
+		for x := 0; x < bounds.Dx(); x++ {
+			line.WriteString(lipgloss.NewStyle().
+				Foreground(hexColor(img.RGBAAt(x, y))).
+				Background(hexColor(img.RGBAAt(x, y+1))).
+				Render("▀"))
+		}
+		lines = append(lines, line.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hexColor converts a pixel to a lipgloss color
+func hexColor(c color.RGBA) lipgloss.Color {
+	return lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))
+}
+
+// kittyImage returns the Kitty graphics escape that replaces the preview
+// in slot with a PNG scaled to cols×rows cells, leaving the cursor in place
+func kittyImage(data []byte, slot, cols, rows int) string {
+	const chunkSize = 4096
+	payload := base64.StdEncoding.EncodeToString(data)
+
+	var b strings.Builder
+	b.WriteString(kittyDelete(slot))
+	for first := true; first || payload != ""; first = false {
+		chunk := payload
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		payload = payload[len(chunk):]
+
+		more := 0
+		if payload != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(&b, "\x1b_Ga=T,f=100,i=%d,c=%d,r=%d,C=1,q=2,m=%d;%s\x1b\\", kittyImageID+slot, cols, rows, more, chunk)
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return b.String()
+}
+
+// sixelImage encodes img as Sixel using a 6×6×6 color cube
+func sixelImage(img *image.RGBA) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Map every pixel to its palette index once
+	index := make([]byte, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.RGBAAt(x, y)
+			index[y*w+x] = byte(cubeLevel(c.R)*36 + cubeLevel(c.G)*6 + cubeLevel(c.B))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1bPq")
+	fmt.Fprintf(&b, "\"1;1;%d;%d", w, h)
+	for i := 0; i < 216; i++ {
+		// Sixel colors are percentages
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+
+	row := make([]byte, w)
+	for band := 0; band < h; band += 6 {
+		// Colors used in this band of six pixel rows
+		var used [216]bool
+		for y := band; y < band+6 && y < h; y++ {
+			for x := 0; x < w; x++ {
+				used[index[y*w+x]] = true
+			}
+		}
+
+		first := true
+		for c := 0; c < 216; c++ {
+			if !used[c] {
+				continue
+			}
+			for x := 0; x < w; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && band+dy < h; dy++ {
+					if index[(band+dy)*w+x] == byte(c) {
+						bits |= 1 << dy
+					}
+				}
+				row[x] = '?' + bits
+			}
+			if !first {
+				b.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(&b, "#%d", c)
+			writeSixelRuns(&b, row)
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+	return b.String()
+}
+
+// cubeLevel maps a channel to one of six color cube levels
+func cubeLevel(v uint8) int {
+	return (int(v)*5 + 127) / 255
+}
+
+// writeSixelRuns writes sixel characters with runs compressed as !<n><c>
+func writeSixelRuns(b *strings.Builder, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(b, "!%d%c", n, row[i])
+		} else {
+			b.Write(row[i:j])
+		}
+		i = j
+	}
+}
+
+// joinColumns places right beside left. Only left is measured, so right
+// may hold a preview marker.
+func joinColumns(left, right string) string {
+	l := strings.Split(left, "\n")
+	r := strings.Split(right, "\n")
+
+	width := 0
+	for _, line := range l {
+		if w := lipgloss.Width(line); w > width {
+			width = w
+		}
+	}
+
+	n := len(l)
+	if len(r) > n {
+		n = len(r)
+	}
+	lines := make([]string, n)
+	for i := range lines {
+		var line string
+		if i < len(l) {
+			line = l[i]
+		}
+		if i < len(r) && r[i] != "" {
+			line += strings.Repeat(" ", width-lipgloss.Width(line)+3) + r[i]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// graphicsPlacement is where App draws a Kitty or Sixel preview
+type graphicsPlacement struct {
+	row, col int // 1-based screen position
+	slot     int
+	seq      string
+}
+
+// findGraphics removes the preview markers from a frame and returns it
+// with where the images of the marked panes go
+func findGraphics(frame string, panes []*previewPane) (string, []graphicsPlacement) {
+	if !strings.Contains(frame, "\x1b[8;") {
+		return frame, nil
+	}
+
+	var placements []graphicsPlacement
+	lines := strings.Split(frame, "\n")
+	for i, line := range lines {
+		for {
+			loc := previewMarkerPattern.FindStringSubmatchIndex(line)
+			if loc == nil {
+				break
+			}
+			id, _ := strconv.Atoi(line[loc[2]:loc[3]])
+			for _, p := range panes {
+				if p.id == id && p.rendered != "" {
+					placements = append(placements, graphicsPlacement{
+						row:  i + 1,
+						col:  lipgloss.Width(line[:loc[0]]) + 1,
+						slot: p.slot,
+						seq:  p.rendered,
+					})
+				}
+			}
+			line = line[:loc[0]] + line[loc[1]:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n"), placements
+}
+
+// samePlacements reports whether two frames place the same previews
+func samePlacements(a, b []graphicsPlacement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasSlot reports whether a preview in slot is placed
+func hasSlot(placements []graphicsPlacement, slot int) bool {
+	for _, p := range placements {
+		if p.slot == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// drawGraphicsMsg asks App to draw the previews of the last frame
+type drawGraphicsMsg struct{}
+
+// scheduleGraphics draws the previews once the next frame is on screen
+func scheduleGraphics() tea.Cmd {
+	return tea.Tick(graphicsDrawDelay, func(time.Time) tea.Msg {
+		return drawGraphicsMsg{}
+	})
+}
@@ -0,0 +1,122 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"imagetool/internal/core"
+	"imagetool/internal/history"
+	"imagetool/internal/jobs"
+	"imagetool/internal/logging"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pendingBatchesMsg lists folder runs an earlier session left unfinished
+type pendingBatchesMsg struct {
+	manifests []*core.Manifest
+}
+
+// loadPendingBatches looks for manifests of unfinished folder runs
+func loadPendingBatches() tea.Msg {
+	manifests, err := core.PendingManifests()
+	if err != nil {
+		logging.Warn("Could not look for unfinished batches", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return pendingBatchesMsg{manifests: manifests}
+}
+
+// manifestHistoryEntry describes a resumed folder run for the history
+func manifestHistoryEntry(h core.ManifestHeader) history.Entry {
+	e := history.Entry{
+		Title:    h.Title + " (resumed)",
+		Inputs:   h.Inputs,
+		InputDir: h.InputDir,
+	}
+	switch {
+	case h.Convert != nil:
+		e.Operation = history.OpConvert
+		e.Options = history.Options{
+			Format:       string(h.Convert.OutputFormat),
+			NameTemplate: h.Convert.NameTemplate,
+			Collision:    h.Convert.Collision.String(),
+		}
+	case h.Compress != nil:
+		e.Operation = history.OpCompress
+		var formats []string
+		for _, f := range h.Compress.Formats {
+			formats = append(formats, string(f))
+		}
+		e.Options = history.Options{
+			Method:       compressMethodNames[h.Compress.Method],
+			Percent:      h.Compress.TargetPercent,
+			TargetBytes:  h.Compress.TargetBytes,
+			MinSSIM:      h.Compress.MinSSIM,
+			Formats:      formats,
+			NameTemplate: h.Compress.NameTemplate,
+			Collision:    h.Compress.Collision.String(),
+		}
+	}
+	return e
+}
+
+// resumeJob returns the job that resumes a folder run from its manifest
+func resumeJob(path string) jobs.Func {
+	return func(ctx context.Context, progress jobs.Progress) jobs.Outcome {
+		manifest, err := core.OpenManifest(path)
+		if err != nil {
+			return jobs.Outcome{Message: "Can't resume: " + err.Error(), Err: err}
+		}
+		h := manifest.Header()
+		logging.Info("Resuming folder run", map[string]interface{}{
+			"manifest": path,
+			"title":    h.Title,
+		})
+
+		start := time.Now()
+		batch, err := core.ResumeBatch(ctx, manifest, progress)
+		if err != nil {
+			logging.Warn("Could not update batch manifest", map[string]interface{}{
+				"manifest": path,
+				"error":    err.Error(),
+			})
+		}
+
+		verb := "Converted"
+		if h.Compress != nil {
+			verb = "Compressed"
+		}
+		message := batchMessage(verb, batch)
+		batchErr := batchError(batch)
+		entry := manifestHistoryEntry(h)
+		entry.Outputs = batchOutputs(batch)
+		recordHistory(entry, start, batchErr == nil, message)
+
+		return jobs.Outcome{Message: message, Err: batchErr}
+	}
+}
+
+// resumePrompt describes the first unfinished folder run
+func resumePrompt(m *core.Manifest) string {
+	var b strings.Builder
+	h := m.Header()
+	finished, failed, total := m.Progress()
+
+	b.WriteString(warningStyle.Render(IconWarning + "  An earlier folder run did not finish"))
+	b.WriteString("\n")
+	b.WriteString(descriptionStyle.Render("    " + h.Title + " • started " + h.Created.Format("2006-01-02 15:04")))
+	b.WriteString("\n")
+	status := fmt.Sprintf("    %d of %d files finished", finished, total)
+	if failed > 0 {
+		status += fmt.Sprintf(", %d failed", failed)
+	}
+	b.WriteString(descriptionStyle.Render(status))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("    Y Resume (skips finished files, retries failed ones) • D Discard • N Later"))
+	b.WriteString("\n\n")
+	return b.String()
+}
@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+)
+
+// pickerSort is the order of entries in the file picker
+type pickerSort int
+
+const (
+	sortByName pickerSort = iota
+	sortBySize
+	sortByModified
+	sortByType
+)
+
+// String returns the label shown in the picker
+func (s pickerSort) String() string {
+	switch s {
+	case sortBySize:
+		return "size"
+	case sortByModified:
+		return "modified"
+	case sortByType:
+		return "type"
+	}
+	return "name"
+}
+
+// next returns the sort mode after s, wrapping around
+func (s pickerSort) next() pickerSort {
+	return (s + 1) % (sortByType + 1)
+}
+
+// sortFiles orders files by the mode. Size and modified time put the
+// largest and newest first; ties fall back to the name.
+func sortFiles(files []FileEntry, mode pickerSort) {
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		switch mode {
+		case sortBySize:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		case sortByModified:
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.After(b.ModTime)
+			}
+		case sortByType:
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+		}
+		return naturalLess(a.Name, b.Name)
+	})
+}
+
+// naturalLess compares names ignoring case, with runs of digits compared
+// by value so "scan2" sorts before "scan10"
+func naturalLess(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, nb := leadingDigits(a), leadingDigits(b)
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(ta) != len(tb) {
+				return len(ta) < len(tb)
+			}
+			if ta != tb {
+				return ta < tb
+			}
+			// Equal values: fewer leading zeros first
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			a, b = a[len(na):], b[len(nb):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// leadingDigits returns the run of digits s starts with
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+// fuzzyScore reports whether the runes of query appear in name in order,
+// ignoring case, and scores the match. Consecutive matches and matches at
+// the start of a word score higher.
+func fuzzyScore(name, query string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+
+	n := []rune(strings.ToLower(name))
+	q := []rune(strings.ToLower(query))
+	score, qi, prev := 0, 0, -2
+	for i := 0; i < len(n) && qi < len(q); i++ {
+		if n[i] != q[qi] {
+			continue
+		}
+		score++
+		if i == prev+1 {
+			score += 3
+		}
+		if i == 0 || strings.ContainsRune(" _-.", n[i-1]) {
+			score += 2
+		}
+		prev = i
+		qi++
+	}
+	if qi < len(q) {
+		return 0, false
+	}
+	return score, true
+}
+
+// fuzzyFilter keeps the entries whose names match query, best matches
+// first. Entries that score the same keep their order.
+func fuzzyFilter(entries []FileEntry, query string) []FileEntry {
+	if query == "" {
+		return entries
+	}
+
+	type match struct {
+		entry FileEntry
+		score int
+	}
+	var matches []match
+	for _, e := range entries {
+		if score, ok := fuzzyScore(e.Name, query); ok {
+			matches = append(matches, match{e, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	filtered := make([]FileEntry, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.entry
+	}
+	return filtered
+}
@@ -0,0 +1,89 @@
+package ui
+
+import "testing"
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		// Digit runs compare by value
+		{"scan2", "scan10", true},
+		{"scan10", "scan2", false},
+		{"a1b2", "a1b10", true},
+		{"file9a", "file10", true},
+		{"file10", "file9a", false},
+		{"99999999999999999999", "100000000000000000000", true},
+		// Equal values put fewer leading zeros first
+		{"img7", "img007", true},
+		{"img007", "img7", false},
+		// Case is ignored
+		{"Scan2", "scan10", true},
+		{"a", "B", true},
+		{"B", "a", false},
+		// Ties are not less either way
+		{"photo", "photo", false},
+		{"Photo", "photo", false},
+		{"photo", "Photo", false},
+		{"", "", false},
+		// Prefixes and digits against letters
+		{"a", "ab", true},
+		{"ab", "a", false},
+		{"", "a", true},
+		{"1", "x", true},
+		{"x", "1", false},
+	}
+	for _, tt := range tests {
+		if got := naturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalLess(%q, %q) = %v; want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		name, query string
+		score       int
+		ok          bool
+	}{
+		{"photo.png", "", 0, true},
+		// Consecutive matches at the start of a word score highest
+		{"photo.png", "pho", 11, true},
+		{"my_photo.png", "pho", 11, true},
+		{"a-b", "ab", 6, true},
+		{"xpxhxo", "pho", 3, true},
+		// Case is ignored
+		{"PHOTO.PNG", "pho", 11, true},
+		{"photo.png", "PHO", 11, true},
+		// Runes, not bytes, are matched
+		{"été", "té", 5, true},
+		// Every rune must appear, in order
+		{"photo.png", "phx", 0, false},
+		{"photo.png", "ohp", 0, false},
+		{"ab", "abc", 0, false},
+		{"", "a", 0, false},
+	}
+	for _, tt := range tests {
+		score, ok := fuzzyScore(tt.name, tt.query)
+		if score != tt.score || ok != tt.ok {
+			t.Errorf("fuzzyScore(%q, %q) = %d, %v; want %d, %v", tt.name, tt.query, score, ok, tt.score, tt.ok)
+		}
+	}
+}
+
+func TestFuzzyFilterKeepsOrderOfTies(t *testing.T) {
+	entries := []FileEntry{{Name: "xpxhxo"}, {Name: "photo.png"}, {Name: "notes.txt"}, {Name: "my_photo.png"}}
+	var got []string
+	for _, e := range fuzzyFilter(entries, "pho") {
+		got = append(got, e.Name)
+	}
+	want := []string{"photo.png", "my_photo.png", "xpxhxo"}
+	if len(got) != len(want) {
+		t.Fatalf("fuzzyFilter = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("fuzzyFilter = %v; want %v", got, want)
+		}
+	}
+}
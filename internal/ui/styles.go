@@ -41,6 +41,12 @@ var (
 				Foreground(primaryColor).
 				Bold(true)
 
+	// Unavailable option style
+	disabledItemStyle = lipgloss.NewStyle().
+				PaddingLeft(2).
+				Foreground(subtleColor).
+				Strikethrough(true)
+
 	// Description style
 	descriptionStyle = lipgloss.NewStyle().
 				Foreground(subtleColor).
@@ -115,9 +121,16 @@ const (
 	IconCompress = "📦"
 	IconConvert  = "🔄"
 	IconSettings = "⚙️"
+	IconLogs     = "📜"
+	IconJobs     = "📋"
+	IconHistory  = "📚"
+	IconDiagnose = "🩺"
 	IconExit     = "❌"
 	IconSuccess  = "✅"
 	IconError    = "❌"
 	IconWarning  = "⚠️"
 	IconSpinner  = "◐"
+	IconHome     = "🏠"
+	IconBookmark = "★"
+	IconRecent   = "🕘"
 )
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// Terminal is the program's output. The renderer's frames and the Kitty
+// and Sixel previews App draws over them are written through it one at a
+// time, so a preview never lands inside a frame. Pass it to the program
+// with tea.WithOutput and call WatchSize, since the program only tracks
+// the size of an *os.File output itself.
+type Terminal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewTerminal returns an output writing to file, usually os.Stdout
+func NewTerminal(file *os.File) *Terminal {
+	return &Terminal{file: file}
+}
+
+// Write writes a frame
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.file.Write(p)
+}
+
+// Read reads from the file. With Fd it lets termenv detect the terminal's
+// color support.
+func (t *Terminal) Read(p []byte) (int, error) {
+	return t.file.Read(p)
+}
+
+// Fd returns the file's descriptor
+func (t *Terminal) Fd() uintptr {
+	return t.file.Fd()
+}
+
+// draw writes each image escape at its place between frames, restoring
+// the cursor so the renderer is not disturbed
+func (t *Terminal) draw(placements []graphicsPlacement) {
+	var b strings.Builder
+	b.WriteString("\x1b7")
+	for _, p := range placements {
+		fmt.Fprintf(&b, "\x1b[%d;%dH%s", p.row, p.col, p.seq)
+	}
+	b.WriteString("\x1b8")
+	t.Write([]byte(b.String()))
+}
+
+// sendSize sends the terminal's size to the program
+func (t *Terminal) sendSize(p *tea.Program) {
+	if !term.IsTerminal(int(t.Fd())) {
+		return
+	}
+	if width, height, err := term.GetSize(int(t.Fd())); err == nil {
+		p.Send(tea.WindowSizeMsg{Width: width, Height: height})
+	}
+}
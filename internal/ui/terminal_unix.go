@@ -0,0 +1,35 @@
+//go:build !windows
+
+package ui
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WatchSize sends the terminal's size to the program now and whenever the
+// terminal is resized, until the returned stop function is called
+func (t *Terminal) WatchSize(p *tea.Program) (stop func()) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		t.sendSize(p)
+		for {
+			select {
+			case <-sig:
+				t.sendSize(p)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WatchSize sends the terminal's size to the program. Windows has no
+// resize signal, so later resizes are not seen, as with the program's own
+// size tracking.
+func (t *Terminal) WatchSize(p *tea.Program) (stop func()) {
+	go t.sendSize(p)
+	return func() {}
+}